@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"strconv"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// SourceInterfaces is an ABI inferred from the standard interfaces a contract
+// reports through ERC-165.
+const SourceInterfaces = "interfaces"
+
+// SourceFacets is the merged ABI of a diamond's facets.
+const SourceFacets = "facets"
+
+// candidateConfidence is how much an ABI from each source can be trusted to
+// describe the contract: verified ABIs and the compiler's metadata for the
+// exact bytecode are exact, bytecode clones almost
+// certainly are, decompiled ABIs miss names and types, and interfaces only
+// cover the standard part of the contract.
+var candidateConfidence = map[string]float64{
+	SourceEtherscan:  1.0,
+	SourceSourcify:   1.0,
+	SourceIPFS:       1.0,
+	SourceBytecode:   0.9,
+	SourceHeimdall:   0.5,
+	SourceInterfaces: 0.3,
+}
+
+// ABICandidate is an ABI one of the sources offers for a contract.
+type ABICandidate struct {
+	Source       string      `json:"source" enum:"etherscan,sourcify,ipfs,bytecode,heimdall,interfaces,facets"`
+	Confidence   float64     `json:"confidence" description:"1 for verified ABIs and ABIs from the contract's metadata on IPFS, 0.9 for bytecode clones, 0.5 for decompiled ABIs, 0.3 for ABIs inferred from ERC-165 interfaces"`
+	ABI          interface{} `json:"abi" description:"The ABI, a JSON-encoded string or, with format=json, an array"`
+	ContractName string      `json:"contractName,omitempty"`
+	// Interfaces lists the interfaces an ABI inferred from them covers.
+	Interfaces []string `json:"interfaces,omitempty"`
+}
+
+// abiCandidates asks every source for the ABI of the contract item describes,
+// the implementation for proxies, instead of stopping at the first one that
+// has it. The candidates are sorted by decreasing confidence. Heimdall is only
+// asked when decompilation is allowed and no source has a verified ABI, and
+// only the explorer and Sourcify when only verified ABIs may be served. A
+// diamond's ABI merges those of its facets and is its only candidate.
+func (af *ABIFetcher) abiCandidates(ctx context.Context, chainId string, address string, rpcURL string, item StorageItem, opts FetchOptions) ([]ABICandidate, error) {
+	var candidates []ABICandidate
+	add := func(candidate ABICandidate, abi string) bool {
+		normalized, err := normalizeABI(abi)
+		if err != nil {
+			af.log(ctx).Debug("skipping invalid candidate ABI", "source", candidate.Source, "error", err.Error())
+			return false
+		}
+		candidate.ABI = normalized
+		if structured, ok := structuredABI(normalized); ok && opts.StructuredABI {
+			candidate.ABI = structured
+		}
+		if candidate.Confidence == 0 {
+			candidate.Confidence = candidateConfidence[candidate.Source]
+		}
+		candidates = append(candidates, candidate)
+		return true
+	}
+
+	if len(item.Facets) > 0 {
+		confidence := candidateConfidence[SourceEtherscan]
+		if item.IsDecompiled {
+			confidence = candidateConfidence[SourceHeimdall]
+		}
+		add(ABICandidate{Source: SourceFacets, Confidence: confidence}, item.ABI)
+		return candidates, nil
+	}
+
+	target := address
+	if implementation, ok := item.Implementation.(string); ok {
+		target = implementation
+	}
+	client, err := af.dial(ctx, rpcURL)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+	reader := af.newReader(client, opts.Block)
+
+	verified := false
+	chainIdInt, _ := strconv.Atoi(chainId)
+	if api, ok := af.etherscanAPIs[chainIdInt]; ok {
+		if abi, metadata, err := getExplorerABI(ctx, api, target); err == nil {
+			verified = add(ABICandidate{Source: SourceEtherscan, ContractName: metadata.ContractName}, abi)
+		}
+	}
+	if abi, metadata, err := af.sourcify.GetABI(ctx, chainIdInt, target); err == nil {
+		verified = add(ABICandidate{Source: SourceSourcify, ContractName: metadata.ContractName}, abi) || verified
+	}
+	if af.verifiedOnly(opts) {
+		// The other sources' ABIs are not verified and must not be served.
+		return candidates, nil
+	}
+	if code, err := reader.CodeAt(ctx, common.HexToAddress(target), nil); err == nil {
+		if af.config.IPFSGatewayURL != "" {
+			if abi, metadata, err := getIPFSABI(ctx, af.config.IPFSGatewayURL, code); err == nil {
+				verified = add(ABICandidate{Source: SourceIPFS, ContractName: metadata.ContractName}, abi) || verified
+			}
+		}
+		if known, ok := af.knownABIs.Lookup(code); ok {
+			add(ABICandidate{Source: SourceBytecode, ContractName: known.Name}, string(known.ABI))
+		}
+	}
+	if item.IsDecompiled {
+		add(ABICandidate{Source: SourceHeimdall}, item.ABI)
+	} else if !verified && af.decompile(opts) {
+		if abi, err := af.getHeimdallABI(ctx, target, rpcURL); err == nil {
+			add(ABICandidate{Source: SourceHeimdall}, abi)
+		}
+	}
+
+	var interfaces []string
+	inferred := "[]"
+	for _, name := range detectInterfaces(ctx, reader, common.HexToAddress(target)) {
+		if merged, err := mergeABIs(inferred, interfaceABIs[name]); err == nil {
+			inferred = merged
+			interfaces = append(interfaces, name)
+		}
+	}
+	if len(interfaces) > 0 {
+		add(ABICandidate{Source: SourceInterfaces, Interfaces: interfaces}, inferred)
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Confidence > candidates[j].Confidence
+	})
+	return candidates, nil
+}
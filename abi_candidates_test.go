@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInterfaceABIsMatchInterfaceIDs(t *testing.T) {
+	for _, known := range knownInterfaces {
+		abi, ok := interfaceABIs[known.Name]
+		if !assert.True(t, ok, known.Name) {
+			continue
+		}
+		_, err := normalizeABI(abi)
+		assert.NoError(t, err, known.Name)
+		// ERC-4906 only adds events, so its ID is not derived from its ABI.
+		if known.Name == "ERC4906" {
+			continue
+		}
+		id, err := computeInterfaceID(abi)
+		assert.NoError(t, err)
+		assert.Equal(t, known.ID, id, known.Name)
+	}
+}
+
+// erc20Node is a node serving contracts that report ERC-165 and ERC-20
+// through supportsInterface.
+func erc20Node(t *testing.T) *fakeNode {
+	handlers := contractNodeHandlers()
+	handlers["eth_call"] = func(params []json.RawMessage) (interface{}, error) {
+		switch hexutil.Encode(rpcParam[rpcCall](params, 0).calldata()) {
+		case supportsInterfaceCall("0x01ffc9a7"), supportsInterfaceCall("0x36372b07"):
+			return common.BigToHash(common.Big1), nil
+		case supportsInterfaceCall("0xffffffff"):
+			return common.Hash{}, nil
+		default:
+			return nil, errExecutionReverted
+		}
+	}
+	return newFakeNode(t, handlers)
+}
+
+func TestFetchABICandidates(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	node := erc20Node(t)
+	var decompilations atomic.Int32
+	heimdall := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		decompilations.Add(1)
+		fmt.Fprint(w, `[{"type":"function","name":"decompiled","inputs":[],"outputs":[],"stateMutability":"view"}]`)
+	}))
+	defer heimdall.Close()
+
+	config := DefaultFetcherConfig()
+	config.HeimdallURL = heimdall.URL
+	verified := "0x1000000000000000000000000000000000000001"
+	unverified := "0x2000000000000000000000000000000000000002"
+	explorer := staticChainAPI{
+		verified: `[{"type":"function","name":"verified","inputs":[],"outputs":[],"stateMutability":"view"}]`,
+	}
+	fetcher := newTestFetcher(t, config, map[int]ChainAPI{1: explorer})
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("GET", "/", nil)
+
+	response, err := fetcher.FetchABI(c, "1", verified, node.URL, FetchOptions{Candidates: true})
+	assert.NoError(t, err)
+	assert.Contains(t, response["abi"], "verified", "the best ABI is still served")
+	candidates := response["candidates"].([]ABICandidate)
+	if assert.Len(t, candidates, 3) {
+		// The explorer's ABI was learned for the bytecode while fetching.
+		assert.Equal(t, SourceEtherscan, candidates[0].Source)
+		assert.Equal(t, 1.0, candidates[0].Confidence)
+		assert.Contains(t, candidates[0].ABI, "verified")
+		assert.Equal(t, SourceBytecode, candidates[1].Source)
+		assert.Equal(t, 0.9, candidates[1].Confidence)
+		assert.Equal(t, SourceInterfaces, candidates[2].Source)
+		assert.Equal(t, 0.3, candidates[2].Confidence)
+		assert.Equal(t, []string{"ERC165", "ERC20"}, candidates[2].Interfaces)
+		assert.Contains(t, candidates[2].ABI, "transferFrom")
+	}
+	assert.Zero(t, decompilations.Load(), "contracts with a verified ABI are not decompiled")
+
+	response, err = fetcher.FetchABI(c, "1", unverified, node.URL, FetchOptions{Candidates: true, StructuredABI: true})
+	assert.NoError(t, err)
+	candidates = response["candidates"].([]ABICandidate)
+	if assert.Len(t, candidates, 2) {
+		assert.Equal(t, SourceHeimdall, candidates[0].Source)
+		assert.Equal(t, 0.5, candidates[0].Confidence)
+		assert.IsType(t, json.RawMessage{}, candidates[0].ABI)
+		assert.Equal(t, SourceInterfaces, candidates[1].Source)
+	}
+	assert.Equal(t, int32(1), decompilations.Load(), "the cached decompiled ABI is reused")
+
+	response, err = fetcher.FetchABI(c, "1", verified, node.URL, FetchOptions{})
+	assert.NoError(t, err)
+	assert.NotContains(t, response, "candidates")
+}
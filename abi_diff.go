@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/gin-gonic/gin"
+)
+
+// ABIChange is a function or event that differs between two ABIs. Functions
+// are matched by selector and events by topic0, so a changed entry kept its
+// signature but not its parameter names, indexing, outputs or mutability.
+type ABIChange struct {
+	Type      string `json:"type"`
+	Signature string `json:"signature"`
+	// Selector is the function selector or the event's topic0.
+	Selector string `json:"selector"`
+	// From and To describe a changed entry in the old and the new ABI.
+	From string `json:"from,omitempty"`
+	To   string `json:"to,omitempty"`
+}
+
+// ABIDiff lists what changed from one ABI to another.
+type ABIDiff struct {
+	Added   []ABIChange `json:"added"`
+	Removed []ABIChange `json:"removed"`
+	Changed []ABIChange `json:"changed"`
+}
+
+// abiEntry is a function or event of a parsed ABI.
+type abiEntry struct {
+	change ABIChange
+	// description is the entry in full, including what its signature leaves
+	// out.
+	description string
+}
+
+// abiEntries indexes the functions and events of a parsed ABI by selector.
+func abiEntries(parsed abi.ABI) map[string]abiEntry {
+	entries := make(map[string]abiEntry)
+	for _, method := range parsed.Methods {
+		selector := hexutil.Encode(method.ID)
+		entries[selector] = abiEntry{
+			change:      ABIChange{Type: "function", Signature: method.Sig, Selector: selector},
+			description: method.String(),
+		}
+	}
+	for _, event := range parsed.Events {
+		selector := event.ID.Hex()
+		entries[selector] = abiEntry{
+			change:      ABIChange{Type: "event", Signature: event.Sig, Selector: selector},
+			description: event.String(),
+		}
+	}
+	return entries
+}
+
+// diffABIs compares the functions and events of two ABIs by selector.
+func diffABIs(from string, to string) (ABIDiff, error) {
+	oldABI, err := abi.JSON(strings.NewReader(from))
+	if err != nil {
+		return ABIDiff{}, fmt.Errorf("invalid ABI: %v", err)
+	}
+	newABI, err := abi.JSON(strings.NewReader(to))
+	if err != nil {
+		return ABIDiff{}, fmt.Errorf("invalid ABI: %v", err)
+	}
+	oldEntries, newEntries := abiEntries(oldABI), abiEntries(newABI)
+
+	diff := ABIDiff{Added: []ABIChange{}, Removed: []ABIChange{}, Changed: []ABIChange{}}
+	for selector, entry := range newEntries {
+		old, ok := oldEntries[selector]
+		switch {
+		case !ok:
+			diff.Added = append(diff.Added, entry.change)
+		case old.description != entry.description:
+			change := entry.change
+			change.From = old.description
+			change.To = entry.description
+			diff.Changed = append(diff.Changed, change)
+		}
+	}
+	for selector, entry := range oldEntries {
+		if _, ok := newEntries[selector]; !ok {
+			diff.Removed = append(diff.Removed, entry.change)
+		}
+	}
+	for _, changes := range [][]ABIChange{diff.Added, diff.Removed, diff.Changed} {
+		sort.Slice(changes, func(i, j int) bool {
+			if changes[i].Type != changes[j].Type {
+				return changes[i].Type > changes[j].Type
+			}
+			return changes[i].Signature < changes[j].Signature
+		})
+	}
+	return diff, nil
+}
+
+// FetchABIDiff resolves the implementation of address at the blocks from and
+// to, fetches both ABIs and reports what changed between them, e.g. across a
+// proxy upgrade.
+func (af *ABIFetcher) FetchABIDiff(c *gin.Context, chainId string, address string, rpcURL string, from *big.Int, to *big.Int) (gin.H, error) {
+	ctx := c.Request.Context()
+	if rpcURL == "" {
+		rpcURL = af.defaultRPCURL(chainId)
+	}
+	address, err := af.resolveAddress(ctx, chainId, address, rpcURL)
+	if err != nil {
+		return nil, err
+	}
+
+	fromItem, _, err := af.lookupABI(ctx, chainId, address, rpcURL, FetchOptions{Block: from})
+	if err != nil {
+		return nil, err
+	}
+	toItem, _, err := af.lookupABI(ctx, chainId, address, rpcURL, FetchOptions{Block: to})
+	if err != nil {
+		return nil, err
+	}
+	diff, err := diffABIs(fromItem.ABI, toItem.ABI)
+	if err != nil {
+		return nil, err
+	}
+
+	return gin.H{
+		"address": address,
+		"from":    abiDiffSide(from, fromItem),
+		"to":      abiDiffSide(to, toItem),
+		"added":   diff.Added,
+		"removed": diff.Removed,
+		"changed": diff.Changed,
+	}, nil
+}
+
+// abiDiffSide describes the contract an ABI diff compares at block.
+func abiDiffSide(block *big.Int, item StorageItem) gin.H {
+	return gin.H{
+		"block":          block.String(),
+		"implementation": item.Implementation,
+		"proxyType":      item.ProxyType,
+		"isDecompiled":   item.IsDecompiled,
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	abiBeforeUpgrade = `[
+		{"type":"function","name":"transfer","inputs":[{"name":"to","type":"address"},{"name":"amount","type":"uint256"}],"outputs":[{"name":"","type":"bool"}],"stateMutability":"nonpayable"},
+		{"type":"function","name":"balanceOf","inputs":[{"name":"owner","type":"address"}],"outputs":[{"name":"","type":"uint256"}],"stateMutability":"view"},
+		{"type":"function","name":"pause","inputs":[],"outputs":[],"stateMutability":"nonpayable"},
+		{"type":"event","name":"Transfer","inputs":[{"name":"from","type":"address","indexed":true},{"name":"to","type":"address","indexed":true},{"name":"value","type":"uint256","indexed":false}],"anonymous":false}
+	]`
+	abiAfterUpgrade = `[
+		{"type":"function","name":"transfer","inputs":[{"name":"to","type":"address"},{"name":"amount","type":"uint256"}],"outputs":[{"name":"","type":"bool"}],"stateMutability":"nonpayable"},
+		{"type":"function","name":"balanceOf","inputs":[{"name":"owner","type":"address"}],"outputs":[{"name":"","type":"uint256"}],"stateMutability":"nonpayable"},
+		{"type":"function","name":"mint","inputs":[{"name":"to","type":"address"},{"name":"amount","type":"uint256"}],"outputs":[],"stateMutability":"nonpayable"},
+		{"type":"event","name":"Transfer","inputs":[{"name":"from","type":"address","indexed":true},{"name":"to","type":"address","indexed":true},{"name":"value","type":"uint256","indexed":true}],"anonymous":false},
+		{"type":"event","name":"Paused","inputs":[],"anonymous":false}
+	]`
+)
+
+func TestDiffABIs(t *testing.T) {
+	diff, err := diffABIs(abiBeforeUpgrade, abiAfterUpgrade)
+	assert.NoError(t, err)
+
+	assert.Equal(t, []ABIChange{
+		{Type: "function", Signature: "mint(address,uint256)", Selector: "0x40c10f19"},
+		{Type: "event", Signature: "Paused()", Selector: "0x9e87fac88ff661f02d44f95383c817fece4bce600a3dab7a54406878b965e752"},
+	}, diff.Added)
+	assert.Equal(t, []ABIChange{{Type: "function", Signature: "pause()", Selector: "0x8456cb59"}}, diff.Removed)
+	if assert.Len(t, diff.Changed, 2) {
+		assert.Equal(t, "balanceOf(address)", diff.Changed[0].Signature)
+		assert.Equal(t, "0x70a08231", diff.Changed[0].Selector)
+		assert.Contains(t, diff.Changed[0].From, "view")
+		assert.NotContains(t, diff.Changed[0].To, "view")
+		assert.Equal(t, "event", diff.Changed[1].Type)
+		assert.Equal(t, "Transfer(address,address,uint256)", diff.Changed[1].Signature)
+	}
+
+	same, err := diffABIs(abiBeforeUpgrade, abiBeforeUpgrade)
+	assert.NoError(t, err)
+	assert.Equal(t, ABIDiff{Added: []ABIChange{}, Removed: []ABIChange{}, Changed: []ABIChange{}}, same)
+
+	_, err = diffABIs(abiBeforeUpgrade, "Max rate limit reached")
+	assert.Error(t, err)
+}
+
+func TestGetABIDiff(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	previous := abiFetcher
+	defer func() { abiFetcher = previous }()
+	storage := NewABIStorage(time.Hour, 0)
+	abiFetcher = NewABIFetcher(storage, map[int]ChainAPI{}, DefaultFetcherConfig())
+
+	router := gin.New()
+	router.GET("/abi/diff/:chainId/:address/*rpcUrl", getABIDiff)
+
+	proxy := "0x1000000000000000000000000000000000000001"
+	storage.Set(abiCacheKey("1", proxy, big.NewInt(100)), StorageItem{
+		ABI: abiBeforeUpgrade, IsProxy: true, ProxyType: "Eip1967Direct", Implementation: "0x2000000000000000000000000000000000000002",
+	})
+	storage.Set(abiCacheKey("1", proxy, big.NewInt(200)), StorageItem{
+		ABI: abiAfterUpgrade, IsProxy: true, ProxyType: "Eip1967Direct", Implementation: "0x3000000000000000000000000000000000000003",
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/abi/diff/1/"+proxy+"/rpc.example?from=100&to=200", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response struct {
+		From    map[string]interface{} `json:"from"`
+		To      map[string]interface{} `json:"to"`
+		Added   []ABIChange            `json:"added"`
+		Removed []ABIChange            `json:"removed"`
+		Changed []ABIChange            `json:"changed"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "100", response.From["block"])
+	assert.Equal(t, "0x2000000000000000000000000000000000000002", response.From["implementation"])
+	assert.Equal(t, "0x3000000000000000000000000000000000000003", response.To["implementation"])
+	assert.Len(t, response.Added, 2)
+	assert.Len(t, response.Removed, 1)
+	assert.Len(t, response.Changed, 2)
+
+	for _, query := range []string{"", "?from=100", "?from=100&to=latest", "?from=-1&to=200"} {
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest("GET", "/abi/diff/1/"+proxy+"/rpc.example"+query, nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+		assert.Contains(t, w.Body.String(), CodeInvalidBlock, query)
+	}
+}
@@ -2,98 +2,970 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
+	"math/big"
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/sync/singleflight"
 )
 
 type ABIFetcher struct {
-	storage       *ABIStorage
-	etherscanAPIs map[int]ChainAPI
+	config         FetcherConfig
+	storage        StorageBackend
+	etherscanAPIs  map[int]ChainAPI
+	sourcify       *SourcifyAPI
+	bytecodeCache  *ttlCache[[]byte]
+	ensCache       *ENSCache
+	stats          *Stats
+	postProcessors []ABIPostProcessor
+	historyCache   *ttlCache[gin.H]
+	knownABIs      *KnownABIs
+	// lookups coalesces concurrent fetches of the same uncached contract.
+	lookups singleflight.Group
+	// decompilations coalesces concurrent Heimdall requests for the same
+	// contract, which the lookups cannot when they differ in their cache
+	// key, e.g. the block they are pinned to.
+	decompilations singleflight.Group
+	// warmJobs tracks finished cache warming jobs by ID and runningWarmJobs
+	// those still running, which must not expire; warmSlots bounds the
+	// fetches all of them run at once.
+	warmJobs        *ttlCache[*WarmJob]
+	runningWarmJobs sync.Map
+	warmSlots       chan struct{}
+	// jobsCtx is the lifetime of background jobs such as cache warming.
+	jobsCtx context.Context
+	logger  Logger
 }
 
-func NewABIFetcher(storage *ABIStorage, etherscanAPIs map[int]ChainAPI) *ABIFetcher {
+// FetchOptions holds the per-request switches that shape the ABI response.
+type FetchOptions struct {
+	// PostProcessors holds the names of the ABIPostProcessors to apply.
+	PostProcessors     map[string]bool
+	IncludeAdminOwner  bool
+	IncludeInterfaceID bool
+	IncludeSelectors   bool
+	// Raw returns the ABI exactly as the source delivered it, skipping all
+	// processing.
+	Raw bool
+	// Force skips the cache read and refetches the ABI, overwriting the
+	// cached entry.
+	Force bool
+	// StructuredABI embeds the ABI as a JSON array instead of a string.
+	StructuredABI bool
+	// MergeProxyABI merges the proxy's own ABI into its implementation's so
+	// admin and upgrade functions are included.
+	MergeProxyABI bool
+	// SkipDecompilation only serves verified ABIs, failing with
+	// *VerifiedABINotFoundError instead of decompiling.
+	SkipDecompilation bool
+	// VerifiedOnly only serves ABIs verified on the explorer or Sourcify,
+	// failing with *UnverifiedABIError instead of serving a decompiled ABI
+	// or one matched by bytecode or metadata hash.
+	VerifiedOnly bool
+	// Block pins proxy detection to the state at that block, so proxies
+	// resolve to the implementation they pointed to then. Nil means latest.
+	Block *big.Int
+	// DetectInterfaces asks the contract which standard interfaces it
+	// implements through ERC-165.
+	DetectInterfaces bool
+	// IncludeCreation adds who deployed the contract and in which
+	// transaction, as far as the explorer knows.
+	IncludeCreation bool
+	// Candidates adds the ABI every source offers for the contract, each with
+	// a confidence score, so that clients can choose among them.
+	Candidates bool
+}
+
+func NewABIFetcher(storage StorageBackend, etherscanAPIs map[int]ChainAPI, config FetcherConfig) *ABIFetcher {
+	logger := config.Logger
+	if logger == nil {
+		logger = NewSlogLogger(slog.Default())
+	}
 	return &ABIFetcher{
-		storage:       storage,
-		etherscanAPIs: etherscanAPIs,
+		config:         config,
+		storage:        storage,
+		etherscanAPIs:  etherscanAPIs,
+		sourcify:       &SourcifyAPI{BaseURL: config.SourcifyURL},
+		bytecodeCache:  newTTLCache[[]byte](config.BytecodeCacheTTL, config.CacheMaxEntries),
+		ensCache:       NewENSCache(config.ENSCacheTTL, config.CacheMaxEntries),
+		stats:          &Stats{},
+		postProcessors: defaultPostProcessors(),
+		historyCache:   newTTLCache[gin.H](config.HistoryCacheTTL, config.CacheMaxEntries),
+		knownABIs:      loadKnownABIs(config.KnownABIsFile),
+		warmJobs:       newTTLCache[*WarmJob](config.WarmJobTTL, config.CacheMaxEntries),
+		warmSlots:      make(chan struct{}, max(config.WarmConcurrency, 1)),
+		jobsCtx:        context.Background(),
+		logger:         logger,
 	}
 }
 
-func (af *ABIFetcher) FetchABI(c *gin.Context, chainId string, address string, rpcURL string) (gin.H, error) {
-	if _, err := strconv.Atoi(chainId); err != nil {
-		return nil, &InvalidInputError{message: "Invalid chainId: must be a number"}
+// log returns the logger of the request ctx belongs to, or the fetcher's
+// logger outside of requests.
+func (af *ABIFetcher) log(ctx context.Context) Logger {
+	return requestLogger(ctx, af.logger)
+}
+
+// StopJobsOn makes background jobs such as cache warming stop once ctx is
+// done, e.g. when the server shuts down. It must be called before the
+// fetcher serves requests.
+func (af *ABIFetcher) StopJobsOn(ctx context.Context) {
+	af.jobsCtx = ctx
+}
+
+func (af *ABIFetcher) FetchABI(c *gin.Context, chainId string, address string, rpcURL string, opts FetchOptions) (response gin.H, err error) {
+	start := time.Now()
+	ctx, span := tracer.Start(c.Request.Context(), "FetchABI", trace.WithAttributes(
+		attribute.String("chainId", chainId),
+		attribute.String("address", address),
+	))
+	defer func() { endSpan(span, err) }()
+	if rpcURL == "" {
+		rpcURL = af.defaultRPCURL(chainId)
+	}
+	var ensName string
+	if isENSName(address) {
+		ensName = address
+	}
+	address, err = af.resolveAddress(ctx, chainId, address, rpcURL)
+	if err != nil {
+		return nil, err
 	}
 
-	if len(address) != 42 {
-		return nil, &InvalidInputError{message: "Invalid address: must be 42 characters long (including '0x' prefix)"}
+	item, source, err := af.lookupABI(ctx, chainId, address, rpcURL, opts)
+	if err != nil {
+		af.log(ctx).Warn("abi lookup failed",
+			"chainId", chainId,
+			"address", address,
+			"error", err.Error(),
+			"duration", time.Since(start),
+		)
+		return nil, err
 	}
+	af.log(ctx).Info("abi lookup",
+		"chainId", chainId,
+		"address", address,
+		"implementation", item.Implementation,
+		"source", source,
+		"proxyType", item.ProxyType,
+		"isDecompiled", item.IsDecompiled,
+		"duration", time.Since(start),
+	)
+	span.SetAttributes(
+		attribute.String("address", address),
+		attribute.String("source", source),
+		attribute.String("proxyType", item.ProxyType),
+		attribute.Bool("isDecompiled", item.IsDecompiled),
+	)
 
-	if rpcURL == "" {
-		return nil, &InvalidInputError{message: "Invalid rpcURL: cannot be empty"}
+	response, err = af.completeResponse(ctx, chainId, address, rpcURL, item, opts)
+	if err != nil {
+		return nil, err
+	}
+	if ensName != "" {
+		response["ensName"] = ensName
+		response["resolvedAddress"] = address
+	}
+	if opts.Block != nil {
+		response["block"] = opts.Block.String()
+	}
+	return response, nil
+}
+
+// resolveAddress validates the requested contract, resolving ENS names, and
+// returns its normalized address.
+func (af *ABIFetcher) resolveAddress(ctx context.Context, chainId string, address string, rpcURL string) (string, error) {
+	if err := af.checkChainServed(chainId); err != nil {
+		return "", err
 	}
+	if isENSName(address) {
+		resolved, err := af.resolveENS(ctx, chainId, address, rpcURL)
+		if err != nil {
+			return "", err
+		}
+		address = resolved
+	}
+	return validateInput(chainId, address, rpcURL)
+}
 
-	if item, ok := af.storage.Get(chainId + "-" + address); ok {
-		return af.createResponse(item), nil
+// resolveENS resolves an ENS name to the address it points to on chains with
+// ENS, caching the result.
+func (af *ABIFetcher) resolveENS(ctx context.Context, chainId string, name string, rpcURL string) (string, error) {
+	registry, ok := ensRegistries[chainId]
+	if !ok {
+		return "", &InvalidInputError{message: "Invalid address: ENS names are not supported on chain " + chainId, code: CodeENSUnsupported}
+	}
+	if address, ok := af.ensCache.Get(chainId, name); ok {
+		return address.Hex(), nil
+	}
+	if rpcURL == "" {
+		return "", &InvalidInputError{message: "Invalid rpcURL: cannot be empty", code: CodeEmptyRPCURL}
 	}
 
-	client, err := ethclient.Dial("https://" + rpcURL)
+	client, err := af.dial(ctx, rpcURL)
 	if err != nil {
-		return nil, &InvalidInputError{message: "Failed to connect to Ethereum node: " + err.Error()}
+		return "", err
 	}
 	defer client.Close()
+	address, err := resolveENSName(ctx, client, registry, name)
+	if err != nil {
+		return "", err
+	}
+	af.ensCache.Set(chainId, name, address)
+	return address.Hex(), nil
+}
 
-	if err := af.validateContract(c.Request.Context(), client, address); err != nil {
-		if _, ok := err.(*InvalidInputError); ok {
-			return nil, err
+// decompile reports whether a request with opts may be served a decompiled
+// ABI.
+func (af *ABIFetcher) decompile(opts FetchOptions) bool {
+	return !af.config.DisableDecompilation && !opts.SkipDecompilation && !af.verifiedOnly(opts)
+}
+
+// verifiedOnly reports whether the request may only be served a verified ABI.
+func (af *ABIFetcher) verifiedOnly(opts FetchOptions) bool {
+	return af.config.StrictVerified || opts.VerifiedOnly
+}
+
+// isVerified reports whether the ABI of item comes from a contract verified
+// on the explorer or Sourcify. Diamonds have no verification status of their
+// own, but their facets are only fetched from those sources or decompiled.
+func isVerified(item StorageItem) bool {
+	if item.IsDecompiled {
+		return false
+	}
+	if len(item.Facets) > 0 {
+		return true
+	}
+	switch item.VerificationStatus {
+	case "verified", "full_match", "partial_match":
+		return true
+	}
+	return false
+}
+
+// lookupABI returns the cached item of address, or fetches and caches it when
+// it is not cached or opts.Force is set. It also reports where the ABI came
+// from.
+func (af *ABIFetcher) lookupABI(ctx context.Context, chainId string, address string, rpcURL string, opts FetchOptions) (StorageItem, string, error) {
+	decompile := af.decompile(opts)
+	if !opts.Force {
+		if item, ok := af.cacheGet(ctx, abiCacheKey(chainId, address, opts.Block)); ok {
+			af.stats.cacheHits.Add(1)
+			if item.Error != "" {
+				return StorageItem{}, SourceCache, &ABIUnavailableError{address: address, reason: item.Error}
+			}
+			if af.verifiedOnly(opts) && !isVerified(item) {
+				return StorageItem{}, SourceCache, &UnverifiedABIError{address: address}
+			}
+			if item.IsDecompiled && !decompile {
+				return StorageItem{}, SourceCache, &VerifiedABINotFoundError{address: address}
+			}
+			return item, SourceCache, nil
 		}
-		return nil, fmt.Errorf("failed to validate contract: %v", err)
 	}
+	af.stats.cacheMisses.Add(1)
 
-	proxyInfo, err := DetectProxyTarget(c.Request.Context(), client, common.HexToAddress(address))
+	// Concurrent requests for the same contract wait for a single fetch and
+	// share its result. The fetch runs detached from the request that started
+	// it, so that request going away does not fail the others; the group
+	// holds no results, caching is left to the storage.
+	type lookup struct {
+		item   StorageItem
+		source string
+	}
+	key := abiCacheKey(chainId, address, opts.Block)
+	if !decompile {
+		key += "-verified"
+	}
+	result := af.lookups.DoChan(key, func() (interface{}, error) {
+		item, source, err := af.fetchAndStore(context.WithoutCancel(ctx), chainId, address, rpcURL, opts.Block, decompile)
+		return lookup{item: item, source: source}, err
+	})
+	select {
+	case res := <-result:
+		if res.Err != nil {
+			var notVerified *VerifiedABINotFoundError
+			if errors.As(res.Err, &notVerified) && af.verifiedOnly(opts) {
+				return StorageItem{}, "", &UnverifiedABIError{address: address}
+			}
+			return StorageItem{}, "", res.Err
+		}
+		shared := res.Val.(lookup)
+		if af.verifiedOnly(opts) && !isVerified(shared.item) {
+			return StorageItem{}, "", &UnverifiedABIError{address: address}
+		}
+		return shared.item, shared.source, nil
+	case <-ctx.Done():
+		return StorageItem{}, "", ctx.Err()
+	}
+}
+
+// abiCacheKey is the storage key of the ABI of address, either at the latest
+// block or pinned to block.
+func abiCacheKey(chainId string, address string, block *big.Int) string {
+	key := chainId + "-" + address
+	if block != nil {
+		key += "@" + block.String()
+	}
+	return key
+}
+
+// cacheGet reads key from the storage within a span.
+func (af *ABIFetcher) cacheGet(ctx context.Context, key string) (StorageItem, bool) {
+	_, span := tracer.Start(ctx, "cache.get", trace.WithAttributes(attribute.String("key", key)))
+	defer span.End()
+	item, ok := af.storage.Get(key)
+	span.SetAttributes(attribute.Bool("hit", ok))
+	return item, ok
+}
+
+// cacheSet writes key to the storage within a span.
+func (af *ABIFetcher) cacheSet(ctx context.Context, key string, item StorageItem) {
+	_, span := tracer.Start(ctx, "cache.set", trace.WithAttributes(attribute.String("key", key)))
+	defer span.End()
+	af.storage.Set(key, item)
+}
+
+// fetchAndStore fetches the ABI of address through the full pipeline and
+// caches it, or caches that no source has one. Proxies are resolved at block,
+// or at the latest block when it is nil. Unless decompile is set, only
+// verified ABIs are fetched.
+func (af *ABIFetcher) fetchAndStore(ctx context.Context, chainId string, address string, rpcURL string, block *big.Int, decompile bool) (StorageItem, string, error) {
+	client, err := af.dial(ctx, rpcURL)
+	if err != nil {
+		return StorageItem{}, "", err
+	}
+	defer client.Close()
+	reader := af.newReader(client, block)
+
+	code, err := af.validateContract(ctx, reader, address)
 	if err != nil {
-		proxyInfo = nil
+		return StorageItem{}, "", err
+	}
+
+	proxyInfo, resolutionPath := resolveProxyChain(ctx, reader, common.HexToAddress(address))
+	// The explorer only knows the current implementation.
+	var proxySource *SourceCode
+	if af.config.ExplorerProxyFallback && block == nil {
+		proxyInfo, resolutionPath, proxySource = af.explorerProxyInfo(ctx, chainId, address, proxyInfo, resolutionPath)
+	}
+	if proxyInfo != nil && !proxyInfo.hasTarget() {
+		proxyInfo, resolutionPath = nil, nil
+	}
+	proxyInfo, err = af.checkProxyTarget(ctx, reader, address, proxyInfo)
+	if err != nil {
+		return StorageItem{}, "", err
+	}
+	if proxyInfo == nil {
+		resolutionPath = nil
+	} else {
+		af.log(ctx).Debug("proxy detected",
+			"address", address,
+			"proxyType", proxyInfo.Type,
+			"target", proxyInfo.Target.Hex(),
+			"hops", len(resolutionPath)-1,
+			"facets", len(proxyInfo.Facets),
+		)
 	}
 
 	targetAddress, implementation := af.getTargetAddress(address, proxyInfo)
-	abi, isDecompiled, err := af.getABI(chainId, targetAddress, rpcURL)
+	proxyCode := code
+	if targetAddress != address {
+		// The bytecode is the proxy's, not that of the ABI's contract. The
+		// fallbacks matching the implementation's bytecode are skipped when
+		// it cannot be read.
+		code, _ = reader.CodeAt(ctx, common.HexToAddress(targetAddress), nil)
+	}
+	var fetched fetchedABI
+	if proxyInfo != nil && len(proxyInfo.Facets) > 0 {
+		fetched, err = af.getDiamondABI(ctx, chainId, proxyInfo.Facets, rpcURL, decompile)
+	} else {
+		fetched, err = af.getABI(ctx, chainId, targetAddress, rpcURL, code, decompile)
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch ABI: %v", err)
+		var unavailable *ABIUnavailableError
+		if errors.As(err, &unavailable) {
+			if af.config.NegativeCacheTTL > 0 {
+				af.cacheSet(ctx, abiCacheKey(chainId, address, block), StorageItem{Error: unavailable.reason, TTL: af.config.NegativeCacheTTL})
+			}
+			return StorageItem{}, "", unavailable
+		}
+		// Not cached, since requests allowing decompilation may succeed.
+		var notVerified *VerifiedABINotFoundError
+		if errors.As(err, &notVerified) {
+			return StorageItem{}, "", &VerifiedABINotFoundError{address: address}
+		}
+		return StorageItem{}, "", fmt.Errorf("failed to fetch ABI: %w", err)
 	}
 
 	item := StorageItem{
-		ABI:            abi,
-		Implementation: implementation,
-		IsProxy:        proxyInfo != nil,
-		IsDecompiled:   isDecompiled,
+		ABI:              fetched.ABI,
+		Implementation:   implementation,
+		IsProxy:          proxyInfo != nil,
+		IsDecompiled:     fetched.IsDecompiled,
+		RawABI:           fetched.RawABI,
+		ContractMetadata: fetched.Metadata,
+	}
+	if fetched.ExplorerError != nil {
+		item.ExplorerError = fetched.ExplorerError.Error()
+	}
+	if proxyInfo != nil {
+		item.ProxyType = proxyInfo.Type
+		item.Immutable = proxyInfo.Immutable
+		if proxyInfo.Admin != (common.Address{}) {
+			item.Admin = proxyInfo.Admin.Hex()
+		}
+		for _, facet := range proxyInfo.Facets {
+			item.Facets = append(item.Facets, facet.Hex())
+		}
+		for _, hop := range resolutionPath {
+			item.ResolutionPath = append(item.ResolutionPath, hop.Hex())
+		}
+	}
+	if proxyInfo != nil {
+		if merged, ok := af.mergePartialDelegation(ctx, chainId, address, proxyInfo, proxySource, proxyCode, fetched.ABI); ok {
+			item.ABI = merged
+			item.PartialDelegation = true
+		}
+	}
+	af.cacheSet(ctx, abiCacheKey(chainId, address, block), item)
+
+	return item, fetched.Source, nil
+}
+
+// completeResponse applies the options that need more data than the cached
+// item holds before creating the response.
+func (af *ABIFetcher) completeResponse(ctx context.Context, chainId string, address string, rpcURL string, item StorageItem, opts FetchOptions) (gin.H, error) {
+	if opts.IncludeCreation && item.Creation == nil {
+		item.Creation = af.contractCreation(ctx, chainId, address, item, opts.Block)
+	}
+	if opts.IncludeAdminOwner && item.Admin != "" && item.AdminOwner == "" {
+		item.AdminOwner = af.proxyAdminOwner(ctx, chainId, address, rpcURL, item, opts.Block)
+	}
+	if opts.MergeProxyABI && item.IsProxy && !opts.Raw {
+		merged, err := af.mergeProxyABI(ctx, chainId, address, rpcURL, item, opts.Block, af.decompile(opts))
+		if err != nil {
+			return nil, err
+		}
+		item.ABI = merged
+	}
+	response, err := af.createResponse(chainId, address, item, opts)
+	if err != nil {
+		return nil, err
+	}
+	if opts.DetectInterfaces {
+		interfaces, err := af.detectInterfaces(ctx, rpcURL, address, item, opts.Block)
+		if err != nil {
+			return nil, err
+		}
+		response["interfaces"] = interfaces
+	}
+	if opts.Candidates {
+		candidates, err := af.abiCandidates(ctx, chainId, address, rpcURL, item, opts)
+		if err != nil {
+			return nil, err
+		}
+		response["candidates"] = candidates
+	}
+	return response, nil
+}
+
+// detectInterfaces returns the standard interfaces the contract reports
+// through ERC-165. A proxy is asked through its implementation, except for
+// diamonds, which answer ERC-165 queries themselves.
+func (af *ABIFetcher) detectInterfaces(ctx context.Context, rpcURL string, address string, item StorageItem, block *big.Int) ([]string, error) {
+	target := address
+	if implementation, ok := item.Implementation.(string); ok && len(item.Facets) == 0 {
+		target = implementation
+	}
+	client, err := af.dial(ctx, rpcURL)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+	return detectInterfaces(ctx, af.newReader(client, block), common.HexToAddress(target)), nil
+}
+
+// contractCreation fetches who deployed address from the chain's explorer and
+// keeps it with the item cached for block. It returns nil, caching nothing,
+// when the explorer cannot tell.
+func (af *ABIFetcher) contractCreation(ctx context.Context, chainId string, address string, item StorageItem, block *big.Int) *ContractCreation {
+	chainIdInt, _ := strconv.Atoi(chainId)
+	provider, ok := af.etherscanAPIs[chainIdInt].(ContractCreationProvider)
+	if !ok {
+		return nil
+	}
+	creation, err := provider.GetContractCreation(ctx, address)
+	if err != nil {
+		af.log(ctx).Warn("error fetching contract creation", "chainId", chainId, "address", address, "error", err.Error())
+		return nil
+	}
+	item.Creation = &creation
+	af.cacheSet(ctx, abiCacheKey(chainId, address, block), item)
+	return item.Creation
+}
+
+// mergeProxyABI merges the proxy's own ABI into its implementation's ABI,
+// deduplicating by selector and preferring the implementation's definitions,
+// and tags each entry with the contract it originates from. The proxy's ABI
+// is fetched once and kept with the item cached for block.
+func (af *ABIFetcher) mergeProxyABI(ctx context.Context, chainId string, address string, rpcURL string, item StorageItem, block *big.Int, decompile bool) (string, error) {
+	if item.ProxyABI == "" {
+		fetched, err := af.getABI(ctx, chainId, address, rpcURL, nil, decompile)
+		if err != nil {
+			return "", fmt.Errorf("failed to fetch proxy ABI: %w", err)
+		}
+		item.ProxyABI = fetched.ABI
+		af.cacheSet(ctx, abiCacheKey(chainId, address, block), item)
+	}
+	implementation := address
+	if target, ok := item.Implementation.(string); ok {
+		implementation = target
+	}
+	merged, err := mergeABIsFrom(item.ABI, implementation, item.ProxyABI, address)
+	if err != nil {
+		return "", fmt.Errorf("failed to merge proxy ABI: %w", err)
+	}
+	return merged, nil
+}
+
+// InvalidateABI removes the cached ABIs of address, including those pinned
+// to a block, and reports whether any was cached. For an ENS name, its cached
+// resolution is removed along with the ABIs of the address it resolved to.
+func (af *ABIFetcher) InvalidateABI(chainId string, address string) (bool, error) {
+	if isENSName(address) {
+		if _, err := strconv.Atoi(chainId); err != nil {
+			return false, &InvalidInputError{message: "Invalid chainId: must be a number", code: CodeInvalidChainID}
+		}
+		resolved, ok := af.ensCache.Get(chainId, address)
+		deleted := af.ensCache.Delete(chainId, address)
+		if ok {
+			deleted = af.invalidateAddress(chainId, resolved.Hex()) || deleted
+		}
+		return deleted, nil
+	}
+	address, err := validateChainAndAddress(chainId, address)
+	if err != nil {
+		return false, err
 	}
-	af.storage.Set(chainId+"-"+address, item)
+	return af.invalidateAddress(chainId, address), nil
+}
 
-	return af.createResponse(item), nil
+func (af *ABIFetcher) invalidateAddress(chainId string, address string) bool {
+	key := abiCacheKey(chainId, address, nil)
+	deleted := af.storage.Delete(key)
+	return af.storage.DeletePrefix(key+"@") > 0 || deleted
 }
 
-func (af *ABIFetcher) validateContract(ctx context.Context, client *ethclient.Client, address string) error {
-	code, err := client.CodeAt(ctx, common.HexToAddress(address), nil)
+// FetchBytecode returns the runtime bytecode deployed at address along with its keccak256 hash.
+func (af *ABIFetcher) FetchBytecode(c *gin.Context, chainId string, address string, rpcURL string) (gin.H, error) {
+	if err := af.checkChainServed(chainId); err != nil {
+		return nil, err
+	}
+	address, err := validateInput(chainId, address, rpcURL)
+	if err != nil {
+		return nil, err
+	}
+
+	cacheKey := chainId + "-" + address
+	if code, ok := af.bytecodeCache.Get(cacheKey); ok {
+		return createBytecodeResponse(code), nil
+	}
+
+	client, err := af.dial(c.Request.Context(), rpcURL)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	code, err := af.validateContract(c.Request.Context(), client, address)
 	if err != nil {
+		return nil, err
+	}
+	af.bytecodeCache.Set(cacheKey, code)
+
+	return createBytecodeResponse(code), nil
+}
+
+// checkChainServed rejects chains that ALLOWED_CHAIN_IDS or DENIED_CHAIN_IDS
+// exclude from this deployment, before any RPC or explorer call is made.
+func (af *ABIFetcher) checkChainServed(chainId string) error {
+	id, err := strconv.Atoi(chainId)
+	if err != nil {
+		// Reported by validateChainAndAddress.
+		return nil
+	}
+	if af.config.DeniedChainIDs[id] || (len(af.config.AllowedChainIDs) > 0 && !af.config.AllowedChainIDs[id]) {
+		return &InvalidInputError{message: "Invalid chainId: chain " + chainId + " is not served by this deployment", code: CodeChainNotAllowed}
+	}
+	return nil
+}
+
+// chainServed reports whether checkChainServed accepts chain id.
+func (af *ABIFetcher) chainServed(id int) bool {
+	return af.checkChainServed(strconv.Itoa(id)) == nil
+}
+
+// validateInput checks the request parameters and returns address in its
+// EIP-55 checksummed form.
+func validateInput(chainId string, address string, rpcURL string) (string, error) {
+	address, err := validateChainAndAddress(chainId, address)
+	if err != nil {
+		return "", err
+	}
+
+	if rpcURL == "" {
+		return "", &InvalidInputError{message: "Invalid rpcURL: cannot be empty", code: CodeEmptyRPCURL}
+	}
+	return address, nil
+}
+
+// validateChainAndAddress checks chainId and address and returns address in
+// its EIP-55 checksummed form, so every spelling of an address shares one
+// cache entry. Mixed-case addresses must carry a valid checksum.
+func validateChainAndAddress(chainId string, address string) (string, error) {
+	if _, err := strconv.Atoi(chainId); err != nil {
+		return "", &InvalidInputError{message: "Invalid chainId: must be a number", code: CodeInvalidChainID}
+	}
+
+	if len(address) != 42 {
+		return "", &InvalidInputError{message: "Invalid address: must be 0x followed by 40 hexadecimal characters", code: CodeInvalidAddressLength}
+	}
+	if !strings.HasPrefix(address, "0x") || !common.IsHexAddress(address) {
+		return "", &InvalidInputError{message: "Invalid address: must be 0x followed by 40 hexadecimal characters", code: CodeInvalidAddressHex}
+	}
+	checksummed := common.HexToAddress(address).Hex()
+	hexDigits := address[2:]
+	if hexDigits != strings.ToLower(hexDigits) && hexDigits != strings.ToUpper(hexDigits) && address != checksummed {
+		return "", &InvalidInputError{message: "Invalid address: mixed-case address has an invalid EIP-55 checksum", code: CodeInvalidChecksum}
+	}
+	return checksummed, nil
+}
+
+// FetchProxyInfo only runs proxy detection on address, skipping the ABI
+// lookup entirely.
+func (af *ABIFetcher) FetchProxyInfo(c *gin.Context, chainId string, address string, rpcURL string) (gin.H, error) {
+	if err := af.checkChainServed(chainId); err != nil {
+		return nil, err
+	}
+	address, err := validateInput(chainId, address, rpcURL)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := c.Request.Context()
+	client, err := af.dial(ctx, rpcURL)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	return af.detectProxy(ctx, af.newReader(client, nil), address)
+}
+
+func (af *ABIFetcher) detectProxy(ctx context.Context, client ContractReader, address string) (gin.H, error) {
+	if _, err := af.validateContract(ctx, client, address); err != nil {
+		return nil, err
+	}
+	proxyInfo, err := DetectProxyTarget(ctx, client, common.HexToAddress(address))
+	if err != nil {
+		return gin.H{"isProxy": false}, nil
+	}
+	return createProxyResponse(proxyInfo), nil
+}
+
+func createProxyResponse(proxyInfo *ProxyInfo) gin.H {
+	response := gin.H{
+		"isProxy":   true,
+		"proxyType": proxyInfo.Type,
+		"immutable": proxyInfo.Immutable,
+	}
+	if proxyInfo.Target != (common.Address{}) {
+		response["target"] = proxyInfo.Target.Hex()
+	}
+	if len(proxyInfo.Facets) > 0 {
+		facets := make([]string, len(proxyInfo.Facets))
+		for i, facet := range proxyInfo.Facets {
+			facets[i] = facet.Hex()
+		}
+		response["facets"] = facets
+	}
+	if proxyInfo.Admin != (common.Address{}) {
+		response["admin"] = proxyInfo.Admin.Hex()
+	}
+	return response
+}
+
+// newReader wraps client in the reader a single request reads the chain
+// through: bounded by the RPC budget, pinned to block unless it is nil, and
+// batching the reads of proxy detection when configured. Batches count
+// against the same budgets as single reads.
+func (af *ABIFetcher) newReader(client *ethclient.Client, block *big.Int) ContractReader {
+	budgeted := newBudgetedReader(client, af.config.RPCConcurrency)
+	var reader ContractReader = budgeted
+	if block != nil {
+		reader = &pinnedReader{reader: reader, block: block}
+	}
+	if af.config.BatchProxyReads {
+		reader = newBatchingReader(reader, newBudgetedBatcher(client.Client(), budgeted), block)
+	}
+	return reader
+}
+
+// dial connects to the RPC node, bounding connection establishment by the
+// configured dial timeout and the caller's context.
+func (af *ABIFetcher) dial(ctx context.Context, rpcURL string) (*ethclient.Client, error) {
+	ctx, cancel := context.WithTimeout(ctx, af.config.DialTimeout)
+	defer cancel()
+
+	endpoint, err := normalizeRPCURL(rpcURL)
+	if err != nil {
+		return nil, err
+	}
+	if !af.config.AllowPrivateRPC {
+		if err := checkRPCHost(ctx, endpoint); err != nil {
+			return nil, err
+		}
+	}
+	rpcClient, err := rpc.DialOptions(ctx, endpoint, rpcClientOptions(af.config.AllowPrivateRPC)...)
+	if err != nil {
+		if errors.Is(err, errInternalRPCHost) {
+			return nil, &InvalidInputError{message: "Invalid rpcURL: " + err.Error(), code: CodePrivateRPCURL}
+		}
+		return nil, &InvalidInputError{message: "Failed to connect to Ethereum node: " + err.Error(), code: CodeRPCUnreachable}
+	}
+	return ethclient.NewClient(rpcClient), nil
+}
+
+// validateContract ensures address holds code and returns that code.
+func (af *ABIFetcher) validateContract(ctx context.Context, client ContractReader, address string) (code []byte, err error) {
+	ctx, span := tracer.Start(ctx, "validateContract", trace.WithAttributes(attribute.String("address", address)))
+	defer func() { endSpan(span, err) }()
+	code, err = client.CodeAt(ctx, common.HexToAddress(address), nil)
+	if err != nil {
+		if errors.Is(err, errInternalRPCHost) {
+			return nil, &InvalidInputError{message: "Invalid rpcURL: " + errInternalRPCHost.Error(), code: CodePrivateRPCURL}
+		}
 		if _, ok := err.(*url.Error); ok {
-			return &InvalidInputError{message: "Invalid RPC URL or network error: " + err.Error()}
+			return nil, &InvalidInputError{message: "Invalid RPC URL or network error: " + err.Error(), code: CodeRPCUnreachable}
 		}
-		return fmt.Errorf("failed to check contract code: %v", err)
+		return nil, fmt.Errorf("failed to validate contract: failed to check contract code: %w", err)
 	}
 	if len(code) == 0 {
-		return &ContractNotFoundError{address: address}
+		return nil, &ContractNotFoundError{address: address, code: accountKind(ctx, client, address)}
 	}
-	return nil
+	return code, nil
+}
+
+// accountKind classifies an address without code for ContractNotFoundError.
+// An address that has sent transactions is an externally owned account. The
+// nonce of a self-destructed contract is cleared with its code, so any other
+// address may either never have held code or have lost it.
+func accountKind(ctx context.Context, client ContractReader, address string) string {
+	accounts, ok := client.(AccountReader)
+	if !ok {
+		return CodeNoCode
+	}
+	nonce, err := accounts.NonceAt(ctx, common.HexToAddress(address), nil)
+	if err != nil || nonce == 0 {
+		return CodeNoCode
+	}
+	return CodeExternallyOwned
+}
+
+// maxProxyDepth bounds how many proxies resolveProxyChain follows.
+const maxProxyDepth = 5
+
+// resolveProxyChain detects whether address is a proxy and, when its target is
+// a proxy as well, follows the chain until it reaches a contract that is not
+// a proxy, a diamond, or maxProxyDepth hops. The returned ProxyInfo keeps the
+// outermost proxy's type with the final target, and the path lists every
+// address from address to that target. It returns nil when address is not a
+// proxy.
+func resolveProxyChain(ctx context.Context, client ContractReader, address common.Address) (*ProxyInfo, []common.Address) {
+	proxyInfo, err := DetectProxyTarget(ctx, client, address)
+	if err != nil {
+		return nil, nil
+	}
+
+	resolved := *proxyInfo
+	path := []common.Address{address}
+	visited := map[common.Address]bool{address: true}
+	for resolved.Target != (common.Address{}) && len(resolved.Facets) == 0 {
+		path = append(path, resolved.Target)
+		if len(path) > maxProxyDepth || visited[resolved.Target] {
+			break
+		}
+		visited[resolved.Target] = true
+
+		inner, err := DetectProxyTarget(ctx, client, resolved.Target)
+		if err != nil {
+			break
+		}
+		if len(inner.Facets) > 0 {
+			resolved.Facets = inner.Facets
+			break
+		}
+		if inner.Target == (common.Address{}) || visited[inner.Target] {
+			break
+		}
+		resolved.Target = inner.Target
+		resolved.Immutable = resolved.Immutable && inner.Immutable
+	}
+	return &resolved, path
+}
+
+// ExplorerProxyType is the proxy type of contracts only the explorer flagged
+// as proxies.
+const ExplorerProxyType = "Explorer"
+
+// explorerProxyInfo checks the on-chain proxy detection against the proxy
+// flag of the chain's explorer. When the explorer reports an implementation
+// that detection missed or resolved differently, the explorer's is used,
+// since it is usually set by hand for proxies the heuristics cannot read.
+// Diamonds and explorer failures leave the detection unchanged. The proxy's
+// verified source is returned as well, or nil if the explorer has none.
+func (af *ABIFetcher) explorerProxyInfo(ctx context.Context, chainId string, address string, proxyInfo *ProxyInfo, path []common.Address) (*ProxyInfo, []common.Address, *SourceCode) {
+	if proxyInfo != nil && len(proxyInfo.Facets) > 0 {
+		return proxyInfo, path, nil
+	}
+	chainIdInt, _ := strconv.Atoi(chainId)
+	provider, ok := af.etherscanAPIs[chainIdInt].(SourceCodeProvider)
+	if !ok {
+		return proxyInfo, path, nil
+	}
+	source, err := provider.GetSourceCode(ctx, address)
+	if err != nil {
+		return proxyInfo, path, nil
+	}
+	if !source.Proxy || !common.IsHexAddress(source.Implementation) {
+		return proxyInfo, path, &source
+	}
+	implementation := common.HexToAddress(source.Implementation)
+	if implementation == (common.Address{}) || (proxyInfo != nil && proxyInfo.Target == implementation) {
+		return proxyInfo, path, &source
+	}
+
+	logger := af.log(ctx).With("address", address, "explorerImplementation", implementation.Hex())
+	if proxyInfo == nil {
+		logger.Info("using proxy implementation reported by explorer")
+		proxyInfo = &ProxyInfo{Type: ExplorerProxyType}
+	} else {
+		logger.Warn("explorer disagrees with detected proxy implementation",
+			"proxyType", proxyInfo.Type,
+			"detectedImplementation", proxyInfo.Target.Hex(),
+		)
+		resolved := *proxyInfo
+		proxyInfo = &resolved
+	}
+	proxyInfo.Target = implementation
+	proxyInfo.Immutable = false
+	return proxyInfo, []common.Address{common.HexToAddress(address), implementation}, &source
+}
+
+// checkProxyTarget makes sure the detected target of a proxy has code, so
+// that no ABI is fetched for an account that is not a contract, e.g. the
+// target of a proxy that was never initialized. Proxies detected through an
+// authoritative standard are rejected, since decompiling the proxy itself
+// would only yield a meaningless ABI. Heuristic detections with such a target
+// most likely matched a contract that is not a proxy, so it is served as it
+// is and nil is returned.
+func (af *ABIFetcher) checkProxyTarget(ctx context.Context, client ContractReader, address string, proxyInfo *ProxyInfo) (*ProxyInfo, error) {
+	if proxyInfo == nil || proxyInfo.Target == (common.Address{}) {
+		return proxyInfo, nil
+	}
+	code, err := client.CodeAt(ctx, proxyInfo.Target, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check proxy target code: %w", err)
+	}
+	if len(code) > 0 {
+		return proxyInfo, nil
+	}
+	if isAuthoritativeProxyType(proxyInfo.Type) {
+		return nil, &ProxyTargetUnresolvableError{address: address, proxyType: proxyInfo.Type, target: proxyInfo.Target.Hex()}
+	}
+	af.log(ctx).Debug("ignoring proxy target without code", "address", address, "proxyType", proxyInfo.Type, "target", proxyInfo.Target.Hex())
+	return nil, nil
+}
+
+// proxyAdminOwner resolves the owner of the item's proxy admin, when the
+// admin is an ownable contract such as a ProxyAdmin, and keeps it with the
+// item cached for block. It returns "" when the owner cannot be determined.
+func (af *ABIFetcher) proxyAdminOwner(ctx context.Context, chainId string, address string, rpcURL string, item StorageItem, block *big.Int) string {
+	client, err := af.dial(ctx, rpcURL)
+	if err != nil {
+		af.log(ctx).Warn("error resolving proxy admin owner", "chainId", chainId, "address", address, "error", err.Error())
+		return ""
+	}
+	defer client.Close()
+	owner, ok := ResolveOwner(ctx, af.newReader(client, block), common.HexToAddress(item.Admin))
+	if !ok {
+		return ""
+	}
+	item.AdminOwner = owner.Hex()
+	af.cacheSet(ctx, abiCacheKey(chainId, address, block), item)
+	return item.AdminOwner
+}
+
+// mergePartialDelegation compares the proxy's own verified ABI with its
+// implementation's. If the proxy declares functions of its own beyond the
+// usual proxy management ones, it only delegates part of its interface, so
+// both ABIs are merged with the implementation taking precedence. The
+// proxy's ABI is taken from proxySource, its getsourcecode result, when the
+// explorer was already asked for it. Otherwise it is only fetched when the
+// proxy's bytecode suggests local functions, to spare the explorer quota.
+func (af *ABIFetcher) mergePartialDelegation(ctx context.Context, chainId string, address string, proxyInfo *ProxyInfo, proxySource *SourceCode, proxyCode []byte, implementationABI string) (string, bool) {
+	if proxyInfo.Type == "Eip1167" || proxyInfo.Type == "Diamond" {
+		return "", false
+	}
+	var proxyABI string
+	if proxySource != nil {
+		proxyABI = proxySource.ABI
+	} else {
+		if !mayHaveLocalFunctions(proxyCode) {
+			return "", false
+		}
+		chainIdInt, _ := strconv.Atoi(chainId)
+		api, ok := af.etherscanAPIs[chainIdInt]
+		if !ok {
+			return "", false
+		}
+		var err error
+		if proxyABI, err = api.GetABIFromEtherscan(ctx, address); err != nil {
+			return "", false
+		}
+	}
+	partial, err := hasLocalFunctions(proxyABI, implementationABI)
+	if err != nil || !partial {
+		return "", false
+	}
+	merged, err := mergeABIsFrom(implementationABI, proxyInfo.Target.Hex(), proxyABI, address)
+	if err != nil {
+		return "", false
+	}
+	return merged, true
 }
 
 func (af *ABIFetcher) getTargetAddress(address string, proxyInfo *ProxyInfo) (string, interface{}) {
 	targetAddress := address
 	var implementation interface{} = nil
+	// A zero Target is never fetched: the contract is served as it is.
 	if proxyInfo != nil && proxyInfo.Target != (common.Address{}) {
 		targetAddress = proxyInfo.Target.Hex()
 		implementation = targetAddress
@@ -101,50 +973,435 @@ func (af *ABIFetcher) getTargetAddress(address string, proxyInfo *ProxyInfo) (st
 	return targetAddress, implementation
 }
 
-func (af *ABIFetcher) getABI(chainId string, targetAddress string, rpcURL string) (string, bool, error) {
+// Sources an ABI can be served from.
+const (
+	SourceCache     = "cache"
+	SourceEtherscan = "etherscan"
+	SourceSourcify  = "sourcify"
+	SourceHeimdall  = "heimdall"
+	// SourceIPFS is the metadata.json the hash in the bytecode points to.
+	SourceIPFS = "ipfs"
+	// SourceBytecode is a known ABI matched by the contract's bytecode hash.
+	SourceBytecode = "bytecode"
+)
+
+// fetchedABI is an ABI together with how it was obtained.
+type fetchedABI struct {
+	ABI          string
+	IsDecompiled bool
+	// RawABI is the ABI exactly as the source returned it, when it differs
+	// from ABI.
+	RawABI string
+	// Source is where the ABI came from, one of the Source constants.
+	Source string
+	// Metadata describes the verified source of the ABI, if any.
+	Metadata ContractMetadata
+	// ExplorerError explains why the explorer's verified ABI could not be
+	// used. It is nil when the explorer succeeded or the contract simply is
+	// not verified there.
+	ExplorerError error
+}
+
+// setABI sets the ABI served from source to the canonical form of raw,
+// keeping raw in RawABI when it differs, so that an ABI serializes the same
+// whichever source served it. Anything go-ethereum cannot parse as an ABI,
+// such as an error message or truncated JSON from a flaky upstream, is
+// rejected with *InvalidABIError and leaves f unchanged.
+func (f *fetchedABI) setABI(raw string, source string) error {
+	abi, err := normalizeABI(raw)
+	if err != nil {
+		return &InvalidABIError{source: source, reason: err.Error()}
+	}
+	f.ABI = abi
+	f.RawABI = ""
+	if raw != abi {
+		f.RawABI = raw
+	}
+	f.Source = source
+	return nil
+}
+
+// getABI fetches the verified ABI from the chain's explorer, falling back to
+// Sourcify, then to the metadata.json on IPFS the metadata hash in code points
+// to, then to the known ABIs matching code, the contract's runtime bytecode if
+// already fetched, and finally, if decompile is set, to decompilation with
+// Heimdall. Unverified contracts fall back silently, while
+// other explorer failures (missing API keys, network or API errors, invalid
+// ABIs) are reported in ExplorerError. Every ABI is validated before it is
+// returned; a source answering with an invalid one is skipped, and if the last
+// source does, the *InvalidABIError is returned.
+func (af *ABIFetcher) getABI(ctx context.Context, chainId string, targetAddress string, rpcURL string, code []byte, decompile bool) (fetchedABI, error) {
+	logger := af.log(ctx).With("chainId", chainId, "address", targetAddress)
+	var result fetchedABI
 	chainIdInt, _ := strconv.Atoi(chainId)
 	api, ok := af.etherscanAPIs[chainIdInt]
 
 	if ok {
-		abi, err := api.GetABIFromEtherscan(targetAddress)
+		abi, metadata, err := getExplorerABI(ctx, api, targetAddress)
 		if err == nil {
-			return abi, false, nil
+			err = result.setABI(abi, SourceEtherscan)
 		}
-		fmt.Printf("Error fetching ABI from Etherscan: %v\n", err)
-		// Fall through to Heimdall if Etherscan fails
+		if err == nil {
+			af.knownABIs.Learn(code, metadata.ContractName, abi)
+			result.Metadata = metadata
+			return result, nil
+		}
+		if isUnverifiedContract(err) {
+			logger.Debug("contract not verified on explorer")
+		} else {
+			logger.Warn("error fetching ABI from explorer", "error", err.Error())
+			result.ExplorerError = err
+		}
+		// Fall through to Sourcify if Etherscan fails
+	}
+
+	abi, metadata, sourcifyErr := af.sourcify.GetABI(ctx, chainIdInt, targetAddress)
+	if sourcifyErr == nil {
+		sourcifyErr = result.setABI(abi, SourceSourcify)
+	}
+	if sourcifyErr == nil {
+		af.knownABIs.Learn(code, metadata.ContractName, abi)
+		result.Metadata = metadata
+		return result, nil
+	}
+	logger.Debug("error fetching ABI from Sourcify", "error", sourcifyErr.Error())
+	var invalidErr *InvalidABIError
+	sourcifyInvalid := errors.As(sourcifyErr, &invalidErr)
+
+	if af.config.IPFSGatewayURL != "" {
+		abi, metadata, err := getIPFSABI(ctx, af.config.IPFSGatewayURL, code)
+		if err == nil {
+			err = result.setABI(abi, SourceIPFS)
+		}
+		if err == nil {
+			af.knownABIs.Learn(code, metadata.ContractName, abi)
+			result.Metadata = metadata
+			return result, nil
+		}
+		logger.Debug("error fetching ABI from IPFS", "error", err.Error())
 	}
 
-	abi, err := getABIFromHeimdall(targetAddress, rpcURL)
+	if known, ok := af.knownABIs.Lookup(code); ok {
+		logger.Debug("bytecode matches a known ABI", "name", known.Name)
+		if err := result.setABI(string(known.ABI), SourceBytecode); err == nil {
+			result.Metadata = ContractMetadata{ContractName: known.Name, VerificationStatus: "bytecode_match"}
+			return result, nil
+		}
+		logger.Warn("known ABI is invalid", "name", known.Name)
+	}
+	if !decompile {
+		// Without a definitive answer from the explorer the contract may
+		// still be verified.
+		if sourcifyInvalid {
+			return fetchedABI{}, sourcifyErr
+		}
+		if result.ExplorerError != nil {
+			return fetchedABI{}, result.ExplorerError
+		}
+		return fetchedABI{}, &VerifiedABINotFoundError{address: targetAddress}
+	}
+	// Fall through to Heimdall if Sourcify fails
+
+	raw, err := af.getHeimdallABI(ctx, targetAddress, rpcURL)
+	if err == nil {
+		// Heimdall answers some failures with a body that is not an ABI,
+		// which must not be cached.
+		err = result.setABI(raw, SourceHeimdall)
+	}
 	if err != nil {
-		return "", false, err
+		// Only when every source answered is the failure definitive; any
+		// transport, explorer or validation failure may go away on the next
+		// attempt.
+		var decompileErr *DecompilationError
+		var netErr *NetworkError
+		if errors.As(err, &decompileErr) && result.ExplorerError == nil && !errors.As(sourcifyErr, &netErr) && !sourcifyInvalid {
+			return fetchedABI{}, &ABIUnavailableError{address: targetAddress, reason: err.Error()}
+		}
+		return fetchedABI{}, err
 	}
-	return abi, true, nil
+	af.stats.decompiled.Add(1)
+	result.IsDecompiled = true
+	return result, nil
 }
 
-func (af *ABIFetcher) createResponse(item StorageItem) gin.H {
-	return gin.H{
-		"abi":            item.ABI,
+// getExplorerABI fetches the verified ABI of address from the explorer,
+// through getsourcecode when the explorer supports it so the contract's
+// metadata is returned as well.
+func getExplorerABI(ctx context.Context, api ChainAPI, address string) (abi string, metadata ContractMetadata, err error) {
+	ctx, span := tracer.Start(ctx, "explorer.GetABI", trace.WithAttributes(attribute.String("address", address)))
+	defer func() { endSpan(span, err) }()
+	provider, ok := api.(SourceCodeProvider)
+	if !ok {
+		abi, err = api.GetABIFromEtherscan(ctx, address)
+		return abi, ContractMetadata{VerificationStatus: "verified"}, err
+	}
+	source, err := provider.GetSourceCode(ctx, address)
+	if err != nil {
+		return "", ContractMetadata{}, err
+	}
+	return source.ABI, ContractMetadata{
+		ContractName:       source.ContractName,
+		CompilerVersion:    source.CompilerVersion,
+		VerificationStatus: "verified",
+	}, nil
+}
+
+// getDiamondABI fetches the ABIs of all facets of a diamond and merges them
+// into one, with earlier facets winning for duplicate entries. Every entry is
+// tagged with the facet it originates from. Facets whose
+// ABI cannot be fetched are skipped; it fails only if none can be fetched.
+func (af *ABIFetcher) getDiamondABI(ctx context.Context, chainId string, facets []common.Address, rpcURL string, decompile bool) (fetchedABI, error) {
+	var combined fetchedABI
+	var lastErr error
+	for _, facet := range facets {
+		fetched, err := af.getABI(ctx, chainId, facet.Hex(), rpcURL, nil, decompile)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		tagged, err := tagABISource(fetched.ABI, facet.Hex())
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if combined.ABI == "" {
+			combined = fetched
+			combined.ABI = tagged
+			continue
+		}
+		merged, err := mergeABIs(combined.ABI, tagged)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		combined.ABI = merged
+		combined.RawABI = ""
+		// The merged ABI no longer stems from a single contract.
+		combined.Metadata = ContractMetadata{}
+		combined.IsDecompiled = combined.IsDecompiled || fetched.IsDecompiled
+		if combined.ExplorerError == nil {
+			combined.ExplorerError = fetched.ExplorerError
+		}
+	}
+	if combined.ABI == "" {
+		var notVerified *VerifiedABINotFoundError
+		if errors.As(lastErr, &notVerified) {
+			return fetchedABI{}, lastErr
+		}
+		return fetchedABI{}, fmt.Errorf("no facet ABI could be fetched: %w", lastErr)
+	}
+	return combined, nil
+}
+
+// ABIResponse describes the body of the ABI routes, from which the OpenAPI
+// spec derives its ABIResponse schema. The response itself is built as a
+// gin.H by createResponse and FetchABI, whose keys must match these fields.
+type ABIResponse struct {
+	ABI                       interface{}       `json:"abi" description:"The ABI, a JSON-encoded string or, with format=json, an array"`
+	Implementation            *string           `json:"implementation" description:"The implementation address for proxies"`
+	IsProxy                   bool              `json:"isProxy"`
+	IsDecompiled              bool              `json:"isDecompiled"`
+	ContractName              *string           `json:"contractName"`
+	CompilerVersion           *string           `json:"compilerVersion"`
+	VerificationStatus        *string           `json:"verificationStatus"`
+	ProxyType                 string            `json:"proxyType,omitempty"`
+	Immutable                 bool              `json:"immutable,omitempty"`
+	Admin                     string            `json:"admin,omitempty"`
+	AdminOwner                string            `json:"adminOwner,omitempty"`
+	Block                     string            `json:"block,omitempty" description:"The block the lookup was pinned to"`
+	ResolutionPath            []string          `json:"resolutionPath,omitempty"`
+	Facets                    []string          `json:"facets,omitempty"`
+	Selectors                 map[string]string `json:"selectors,omitempty"`
+	InterfaceID               string            `json:"interfaceId,omitempty"`
+	Interfaces                []string          `json:"interfaces,omitempty"`
+	Candidates                []ABICandidate    `json:"candidates,omitempty"`
+	Creator                   string            `json:"creator,omitempty"`
+	CreationTxHash            string            `json:"creationTxHash,omitempty"`
+	ExplorerURL               string            `json:"explorerUrl,omitempty"`
+	ImplementationExplorerURL string            `json:"implementationExplorerUrl,omitempty"`
+	ExplorerError             string            `json:"explorerError,omitempty"`
+	PartialDelegation         bool              `json:"partialDelegation,omitempty"`
+	Warning                   string            `json:"warning,omitempty"`
+	ENSName                   string            `json:"ensName,omitempty"`
+	ResolvedAddress           string            `json:"resolvedAddress,omitempty"`
+}
+
+func (af *ABIFetcher) createResponse(chainId string, address string, item StorageItem, opts FetchOptions) (gin.H, error) {
+	abi := item.ABI
+	if opts.Raw {
+		if item.RawABI != "" {
+			abi = item.RawABI
+		}
+	} else {
+		processed, err := applyPostProcessors(abi, af.postProcessors, opts.PostProcessors)
+		if err != nil {
+			return nil, fmt.Errorf("failed to post-process ABI: %w", err)
+		}
+		abi = processed
+	}
+
+	response := gin.H{
+		"abi":            abi,
 		"implementation": item.Implementation,
 		"isProxy":        item.IsProxy,
 		"isDecompiled":   item.IsDecompiled,
+		// Decompiled ABIs have no contract metadata, reported as null.
+		"contractName":       nullIfEmpty(item.ContractName),
+		"compilerVersion":    nullIfEmpty(item.CompilerVersion),
+		"verificationStatus": nullIfEmpty(item.VerificationStatus),
+	}
+	if item.IsProxy {
+		if item.ProxyType != "" {
+			response["proxyType"] = item.ProxyType
+		}
+		response["immutable"] = item.Immutable
+	}
+	if opts.StructuredABI {
+		if structured, ok := structuredABI(abi); ok {
+			response["abi"] = structured
+		} else {
+			response["warning"] = "The ABI is not a valid JSON array and is returned as a string"
+		}
+	}
+	if opts.IncludeInterfaceID {
+		interfaceID, err := computeInterfaceID(item.ABI)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compute interface ID: %w", err)
+		}
+		response["interfaceId"] = interfaceID
+	}
+	if opts.IncludeSelectors {
+		response["selectors"] = selectorSignatures(item.ABI)
+	}
+	if explorerURL := af.explorerURL(chainId, address); explorerURL != "" {
+		response["explorerUrl"] = explorerURL
+		if implementation, ok := item.Implementation.(string); ok {
+			response["implementationExplorerUrl"] = af.explorerURL(chainId, implementation)
+		}
+	}
+	if len(item.ResolutionPath) > 1 {
+		response["resolutionPath"] = item.ResolutionPath
+	}
+	if len(item.Facets) > 0 {
+		response["facets"] = item.Facets
+	}
+	if item.ExplorerError != "" {
+		response["explorerError"] = item.ExplorerError
+	}
+	if item.PartialDelegation {
+		response["partialDelegation"] = true
+		response["warning"] = "The proxy handles some calls itself; the ABI merges the proxy's and the implementation's functions"
+	}
+	if item.Admin != "" {
+		response["admin"] = item.Admin
+		if opts.IncludeAdminOwner && item.AdminOwner != "" {
+			response["adminOwner"] = item.AdminOwner
+		}
+	}
+	if opts.IncludeCreation && item.Creation != nil && item.Creation.Creator != "" {
+		response["creator"] = item.Creation.Creator
+		response["creationTxHash"] = item.Creation.TxHash
+	}
+	return response, nil
+}
+
+// nullIfEmpty returns nil for an empty string so it is encoded as null.
+func nullIfEmpty(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// explorerURL links to address on the chain's block explorer, or returns an
+// empty string when the chain has no known explorer.
+func (af *ABIFetcher) explorerURL(chainId string, address string) string {
+	chainIdInt, _ := strconv.Atoi(chainId)
+	linker, ok := af.etherscanAPIs[chainIdInt].(ExplorerLinker)
+	if !ok {
+		return ""
 	}
+	return linker.ExplorerAddressURL(address)
 }
 
-func getABIFromHeimdall(address string, rpcURL string) (string, error) {
-	url := fmt.Sprintf("https://heimdall-api.fly.dev/%s?rpc_url=%s", address, rpcURL)
-	resp, err := http.Get(url)
+// defaultRPCURL returns the RPC URL configured for the chain, or an empty
+// string when there is none.
+func (af *ABIFetcher) defaultRPCURL(chainId string) string {
+	chainIdInt, _ := strconv.Atoi(chainId)
+	provider, ok := af.etherscanAPIs[chainIdInt].(DefaultRPCProvider)
+	if !ok {
+		return ""
+	}
+	return provider.DefaultRPCURL()
+}
+
+// structuredABI returns abi as raw JSON to embed in a response, or false when
+// it is not a JSON array.
+func structuredABI(abi string) (json.RawMessage, bool) {
+	var entries []json.RawMessage
+	if err := json.Unmarshal([]byte(abi), &entries); err != nil {
+		return nil, false
+	}
+	return json.RawMessage(abi), true
+}
+
+func createBytecodeResponse(code []byte) gin.H {
+	return gin.H{
+		"bytecode": hexutil.Encode(code),
+		"hash":     crypto.Keccak256Hash(code).Hex(),
+	}
+}
+
+const defaultHeimdallURL = "https://heimdall-api.fly.dev"
+
+// heimdallRequestURL builds the Heimdall decompilation URL for address,
+// encoding rpcURL so its own query string stays part of the parameter.
+func heimdallRequestURL(baseURL string, address string, rpcURL string) string {
+	return strings.TrimSuffix(baseURL, "/") + "/" + url.PathEscape(address) + "?" + url.Values{"rpc_url": {rpcURL}}.Encode()
+}
+
+// getHeimdallABI decompiles address with Heimdall. Concurrent requests for the
+// same address and RPC URL share a single upstream request, since
+// decompilation takes seconds and is the most expensive call the service
+// makes. Like the lookups, the request runs detached from the caller that
+// started it.
+func (af *ABIFetcher) getHeimdallABI(ctx context.Context, address string, rpcURL string) (string, error) {
+	key := common.HexToAddress(address).Hex() + " " + rpcURL
+	result := af.decompilations.DoChan(key, func() (interface{}, error) {
+		return getABIFromHeimdall(context.WithoutCancel(ctx), af.config.HeimdallURL, address, rpcURL)
+	})
+	select {
+	case res := <-result:
+		if res.Err != nil {
+			return "", res.Err
+		}
+		return res.Val.(string), nil
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+func getABIFromHeimdall(ctx context.Context, baseURL string, address string, rpcURL string) (abi string, err error) {
+	ctx, span := tracer.Start(ctx, "heimdall.Decompile", trace.WithAttributes(attribute.String("address", address)))
+	defer func() { endSpan(span, err) }()
+	requestURL := heimdallRequestURL(baseURL, address, rpcURL)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, requestURL, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := heimdallHTTPClient.Do(req)
 	if err != nil {
 		return "", err
 	}
 	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	body, err := io.ReadAll(limitBody(resp.Body))
 	if err != nil {
 		return "", err
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("heimdall API error: %s", string(body))
+		return "", &DecompilationError{message: string(body)}
 	}
 
 	return string(body), nil
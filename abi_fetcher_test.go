@@ -0,0 +1,1138 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckProxyTarget(t *testing.T) {
+	fetcher := NewABIFetcher(NewABIStorage(time.Hour, 0), map[int]ChainAPI{}, DefaultFetcherConfig())
+	proxy := "0x1000000000000000000000000000000000000001"
+	deadTarget := common.HexToAddress("0x2000000000000000000000000000000000000002")
+	liveTarget := common.HexToAddress("0x3000000000000000000000000000000000000003")
+
+	client := newMockContractReader()
+	client.code[liveTarget] = []byte{0x60, 0x80}
+
+	t.Run("authoritative proxy with dead target", func(t *testing.T) {
+		proxyInfo, err := fetcher.checkProxyTarget(context.Background(), client, proxy, &ProxyInfo{Target: deadTarget, Type: "Eip1967Direct"})
+		assert.Nil(t, proxyInfo)
+		var unresolvable *ProxyTargetUnresolvableError
+		assert.True(t, errors.As(err, &unresolvable))
+		assert.Equal(t, "Eip1967Direct", unresolvable.proxyType)
+		assert.Equal(t, deadTarget.Hex(), unresolvable.target)
+	})
+
+	t.Run("authoritative proxy with live target", func(t *testing.T) {
+		live := &ProxyInfo{Target: liveTarget, Type: "Eip1967Direct"}
+		proxyInfo, err := fetcher.checkProxyTarget(context.Background(), client, proxy, live)
+		assert.NoError(t, err)
+		assert.Same(t, live, proxyInfo)
+	})
+
+	t.Run("heuristic proxy with dead target", func(t *testing.T) {
+		for _, proxyType := range []string{"InterfaceCall", "Slot0", ExplorerProxyType} {
+			proxyInfo, err := fetcher.checkProxyTarget(context.Background(), client, proxy, &ProxyInfo{Target: deadTarget, Type: proxyType})
+			assert.NoError(t, err, proxyType)
+			assert.Nil(t, proxyInfo, "a %s proxy with a codeless target is not a proxy", proxyType)
+		}
+	})
+
+	t.Run("heuristic proxy with live target", func(t *testing.T) {
+		proxyInfo, err := fetcher.checkProxyTarget(context.Background(), client, proxy, &ProxyInfo{Target: liveTarget, Type: "InterfaceCall"})
+		assert.NoError(t, err)
+		assert.Equal(t, liveTarget, proxyInfo.Target)
+	})
+
+	t.Run("diamond", func(t *testing.T) {
+		diamond := &ProxyInfo{Type: "Diamond", Facets: []common.Address{liveTarget}}
+		proxyInfo, err := fetcher.checkProxyTarget(context.Background(), client, proxy, diamond)
+		assert.NoError(t, err)
+		assert.Same(t, diamond, proxyInfo)
+	})
+
+	t.Run("not a proxy", func(t *testing.T) {
+		proxyInfo, err := fetcher.checkProxyTarget(context.Background(), client, proxy, nil)
+		assert.NoError(t, err)
+		assert.Nil(t, proxyInfo)
+	})
+}
+
+func TestFetchABIIgnoresCodelessHeuristicTarget(t *testing.T) {
+	proxy := common.HexToAddress("0x1000000000000000000000000000000000000001")
+	uninitialized := common.HexToAddress("0x2000000000000000000000000000000000000002")
+	// The contract answers implementation() with an account without code.
+	node := newFakeNode(t, map[string]rpcHandler{
+		"eth_getCode": func(params []json.RawMessage) (interface{}, error) {
+			if rpcParam[common.Address](params, 0) == proxy {
+				return hexutil.Bytes{0x60, 0x80}, nil
+			}
+			return hexutil.Bytes{}, nil
+		},
+		"eth_getStorageAt": func(params []json.RawMessage) (interface{}, error) {
+			return common.Hash{}, nil
+		},
+		"eth_call": func(params []json.RawMessage) (interface{}, error) {
+			call := rpcParam[rpcCall](params, 0)
+			if call.To == proxy && hexutil.Encode(call.calldata()) == EIP897Interface[0] {
+				return common.BytesToHash(uninitialized.Bytes()), nil
+			}
+			return nil, errExecutionReverted
+		},
+	})
+
+	config := DefaultFetcherConfig()
+	config.DisableDecompilation = true
+	explorer := staticChainAPI{
+		proxy.Hex(): `[{"type":"function","name":"own","inputs":[],"outputs":[],"stateMutability":"view"}]`,
+	}
+	fetcher := newTestFetcher(t, config, map[int]ChainAPI{1: explorer})
+
+	item, _, err := fetcher.lookupABI(context.Background(), "1", proxy.Hex(), node.URL, FetchOptions{})
+	assert.NoError(t, err)
+	assert.False(t, item.IsProxy)
+	assert.Nil(t, item.Implementation)
+	assert.Contains(t, item.ABI, "own", "the contract's own ABI is served")
+}
+
+func TestProxyAdmin(t *testing.T) {
+	fetcher := NewABIFetcher(NewABIStorage(time.Hour, 0), map[int]ChainAPI{}, DefaultFetcherConfig())
+	proxy := common.HexToAddress("0x1000000000000000000000000000000000000001")
+	proxyAdmin := common.HexToAddress("0x2000000000000000000000000000000000000002")
+	owner := common.HexToAddress("0x3000000000000000000000000000000000000003")
+
+	t.Run("admin", func(t *testing.T) {
+		implementation := common.HexToAddress("0x4000000000000000000000000000000000000004")
+		explorer := staticChainAPI{
+			implementation.Hex(): `[{"type":"function","name":"logic","inputs":[],"outputs":[],"stateMutability":"view"}]`,
+		}
+		for _, admin := range []common.Address{proxyAdmin, {}} {
+			slots := map[common.Hash]common.Address{
+				common.HexToHash(EIP1967LogicSlot): implementation,
+				common.HexToHash(EIP1967AdminSlot): admin,
+			}
+			handlers := contractNodeHandlers()
+			handlers["eth_getStorageAt"] = func(params []json.RawMessage) (interface{}, error) {
+				if rpcParam[common.Address](params, 0) != proxy {
+					return common.Hash{}, nil
+				}
+				return common.BytesToHash(slots[rpcParam[common.Hash](params, 1)].Bytes()), nil
+			}
+			node := newFakeNode(t, handlers)
+			config := DefaultFetcherConfig()
+			config.DisableDecompilation = true
+			fetcher := newTestFetcher(t, config, map[int]ChainAPI{1: explorer})
+
+			item, _, err := fetcher.lookupABI(context.Background(), "1", proxy.Hex(), node.URL, FetchOptions{})
+			assert.NoError(t, err)
+			assert.True(t, item.IsProxy)
+			if admin == (common.Address{}) {
+				assert.Empty(t, item.Admin, "a zero admin slot is no admin")
+			} else {
+				assert.Equal(t, proxyAdmin.Hex(), item.Admin, "the admin detected with the proxy is kept")
+			}
+		}
+	})
+
+	t.Run("owner on request", func(t *testing.T) {
+		var ownerCalls atomic.Int32
+		node := newFakeNode(t, map[string]rpcHandler{
+			"eth_call": func(params []json.RawMessage) (interface{}, error) {
+				ownerCalls.Add(1)
+				return hexutil.Bytes(common.LeftPadBytes(owner.Bytes(), 32)), nil
+			},
+		})
+		fetcher := newTestFetcher(t, DefaultFetcherConfig(), nil)
+		item := StorageItem{ABI: "[]", IsProxy: true, Admin: proxyAdmin.Hex()}
+		fetcher.storage.Set(abiCacheKey("1", proxy.Hex(), nil), item)
+
+		response, err := fetcher.completeResponse(context.Background(), "1", proxy.Hex(), node.URL, item, FetchOptions{})
+		assert.NoError(t, err)
+		assert.NotContains(t, response, "adminOwner")
+		assert.Zero(t, ownerCalls.Load(), "the owner is not looked up unless requested")
+
+		response, err = fetcher.completeResponse(context.Background(), "1", proxy.Hex(), node.URL, item, FetchOptions{IncludeAdminOwner: true})
+		assert.NoError(t, err)
+		assert.Equal(t, owner.Hex(), response["adminOwner"])
+		cached, ok := fetcher.storage.Get(abiCacheKey("1", proxy.Hex(), nil))
+		assert.True(t, ok)
+		assert.Equal(t, owner.Hex(), cached.AdminOwner, "the owner is cached with the item")
+	})
+
+	t.Run("response", func(t *testing.T) {
+		item := StorageItem{ABI: "[]", IsProxy: true, Admin: proxyAdmin.Hex(), AdminOwner: owner.Hex()}
+		response, err := fetcher.createResponse("1", proxy.Hex(), item, FetchOptions{})
+		assert.NoError(t, err)
+		assert.Equal(t, proxyAdmin.Hex(), response["admin"])
+		assert.NotContains(t, response, "adminOwner")
+
+		response, err = fetcher.createResponse("1", proxy.Hex(), item, FetchOptions{IncludeAdminOwner: true})
+		assert.NoError(t, err)
+		assert.Equal(t, owner.Hex(), response["adminOwner"])
+	})
+}
+
+// staticChainAPI serves fixed ABIs keyed by address.
+type staticChainAPI map[string]string
+
+func (s staticChainAPI) GetABIFromEtherscan(ctx context.Context, address string) (string, error) {
+	abi, ok := s[address]
+	if !ok {
+		return "", &EtherscanAPIError{message: "Contract source code not verified", unverified: true}
+	}
+	return abi, nil
+}
+
+// creationChainAPI is a staticChainAPI that also reports contract creations,
+// counting the lookups.
+type creationChainAPI struct {
+	staticChainAPI
+	creations map[string]ContractCreation
+	lookups   int
+}
+
+func (c *creationChainAPI) GetContractCreation(ctx context.Context, address string) (ContractCreation, error) {
+	c.lookups++
+	return c.creations[address], nil
+}
+
+func TestFetchABIIncludeCreation(t *testing.T) {
+	deployed := "0x1000000000000000000000000000000000000001"
+	unknown := "0x2000000000000000000000000000000000000002"
+	storage := NewABIStorage(time.Hour, 0)
+	storage.Set("1-"+deployed, StorageItem{ABI: "[]"})
+	storage.Set("1-"+unknown, StorageItem{ABI: "[]"})
+	api := &creationChainAPI{creations: map[string]ContractCreation{
+		deployed: {Creator: "0x3000000000000000000000000000000000000003", TxHash: "0xabc"},
+	}}
+	fetcher := NewABIFetcher(storage, map[int]ChainAPI{1: api}, DefaultFetcherConfig())
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("GET", "/", nil)
+	for i := 0; i < 2; i++ {
+		response, err := fetcher.FetchABI(c, "1", deployed, "127.0.0.1:1", FetchOptions{IncludeCreation: true})
+		assert.NoError(t, err)
+		assert.Equal(t, "0x3000000000000000000000000000000000000003", response["creator"])
+		assert.Equal(t, "0xabc", response["creationTxHash"])
+	}
+	assert.Equal(t, 1, api.lookups, "the creation is cached with the ABI")
+
+	response, err := fetcher.FetchABI(c, "1", deployed, "127.0.0.1:1", FetchOptions{})
+	assert.NoError(t, err)
+	assert.NotContains(t, response, "creator")
+
+	response, err = fetcher.FetchABI(c, "1", unknown, "127.0.0.1:1", FetchOptions{IncludeCreation: true})
+	assert.NoError(t, err)
+	assert.NotContains(t, response, "creator", "unknown creations are omitted")
+	assert.NotContains(t, response, "creationTxHash")
+
+	// Explorers without getcontractcreation, such as Blockscout, are skipped.
+	storage = NewABIStorage(time.Hour, 0)
+	storage.Set("1-"+deployed, StorageItem{ABI: "[]"})
+	fetcher = NewABIFetcher(storage, map[int]ChainAPI{1: staticChainAPI{}}, DefaultFetcherConfig())
+	response, err = fetcher.FetchABI(c, "1", deployed, "127.0.0.1:1", FetchOptions{IncludeCreation: true})
+	assert.NoError(t, err)
+	assert.NotContains(t, response, "creator")
+}
+
+func TestMergePartialDelegation(t *testing.T) {
+	proxy := "0x1000000000000000000000000000000000000001"
+	implementation := common.HexToAddress("0x2000000000000000000000000000000000000002")
+	proxyInfo := &ProxyInfo{Type: "Eip1967Direct", Target: implementation}
+	// PUSH4 pause(), as in a function dispatcher.
+	dispatchingCode := append([]byte{opPush4}, crypto.Keccak256([]byte("pause()"))[:4]...)
+
+	t.Run("proxy with local functions", func(t *testing.T) {
+		fetcher := NewABIFetcher(NewABIStorage(time.Hour, 0), map[int]ChainAPI{1: staticChainAPI{proxy: partialProxyABI}}, DefaultFetcherConfig())
+		merged, ok := fetcher.mergePartialDelegation(context.Background(), "1", proxy, proxyInfo, nil, dispatchingCode, implementationABI)
+		assert.True(t, ok)
+		var entries []map[string]interface{}
+		assert.NoError(t, json.Unmarshal([]byte(merged), &entries))
+		sources := make(map[string]interface{})
+		for _, entry := range entries {
+			sources[entry["type"].(string)+":"+fmt.Sprint(entry["name"])] = entry["source"]
+		}
+		assert.Equal(t, map[string]interface{}{
+			"function:transfer": implementation.Hex(),
+			"event:Transfer":    implementation.Hex(),
+			"function:pause":    proxy,
+			"fallback:<nil>":    proxy,
+		}, sources)
+	})
+
+	t.Run("pure proxy", func(t *testing.T) {
+		fetcher := NewABIFetcher(NewABIStorage(time.Hour, 0), map[int]ChainAPI{1: staticChainAPI{proxy: transparentProxyABI}}, DefaultFetcherConfig())
+		_, ok := fetcher.mergePartialDelegation(context.Background(), "1", proxy, proxyInfo, nil, dispatchingCode, implementationABI)
+		assert.False(t, ok)
+	})
+
+	t.Run("unverified proxy", func(t *testing.T) {
+		fetcher := NewABIFetcher(NewABIStorage(time.Hour, 0), map[int]ChainAPI{1: staticChainAPI{}}, DefaultFetcherConfig())
+		_, ok := fetcher.mergePartialDelegation(context.Background(), "1", proxy, proxyInfo, nil, dispatchingCode, implementationABI)
+		assert.False(t, ok)
+	})
+
+	t.Run("explorer calls", func(t *testing.T) {
+		counting := &countingChainAPI{ChainAPI: staticChainAPI{proxy: partialProxyABI}}
+		fetcher := NewABIFetcher(NewABIStorage(time.Hour, 0), map[int]ChainAPI{1: counting}, DefaultFetcherConfig())
+
+		_, ok := fetcher.mergePartialDelegation(context.Background(), "1", proxy, proxyInfo, &SourceCode{ABI: partialProxyABI}, nil, implementationABI)
+		assert.True(t, ok, "the ABI of the getsourcecode result is used")
+		// PUSH4 upgradeTo(address) and a PUSH20 holding 0x63 as data.
+		managementCode := append([]byte{opPush4}, crypto.Keccak256([]byte("upgradeTo(address)"))[:4]...)
+		managementCode = append(append(managementCode, 0x73, opPush4), make([]byte, 19)...)
+		_, ok = fetcher.mergePartialDelegation(context.Background(), "1", proxy, proxyInfo, nil, managementCode, implementationABI)
+		assert.False(t, ok, "bytecode without local functions is not looked up")
+		assert.Zero(t, counting.calls.Load())
+
+		_, ok = fetcher.mergePartialDelegation(context.Background(), "1", proxy, proxyInfo, nil, dispatchingCode, implementationABI)
+		assert.True(t, ok)
+		assert.Equal(t, int32(1), counting.calls.Load())
+	})
+}
+
+// countingChainAPI counts the getabi calls made through it.
+type countingChainAPI struct {
+	ChainAPI
+	calls atomic.Int32
+}
+
+func (c *countingChainAPI) GetABIFromEtherscan(ctx context.Context, address string) (string, error) {
+	c.calls.Add(1)
+	return c.ChainAPI.GetABIFromEtherscan(ctx, address)
+}
+
+// sourceCodeChainAPI reports a fixed getsourcecode result for every address.
+type sourceCodeChainAPI struct {
+	staticChainAPI
+	source SourceCode
+}
+
+func (s sourceCodeChainAPI) GetSourceCode(ctx context.Context, address string) (SourceCode, error) {
+	return s.source, nil
+}
+
+func TestExplorerProxyInfo(t *testing.T) {
+	proxy := "0x1000000000000000000000000000000000000001"
+	detected := common.HexToAddress("0x2000000000000000000000000000000000000002")
+	reported := common.HexToAddress("0x3000000000000000000000000000000000000003")
+	newFetcher := func(api ChainAPI) *ABIFetcher {
+		return NewABIFetcher(NewABIStorage(time.Hour, 0), map[int]ChainAPI{1: api}, DefaultFetcherConfig())
+	}
+	flagged := sourceCodeChainAPI{source: SourceCode{Proxy: true, Implementation: reported.Hex()}}
+
+	t.Run("detection missed the proxy", func(t *testing.T) {
+		info, path, source := newFetcher(flagged).explorerProxyInfo(context.Background(), "1", proxy, nil, nil)
+		assert.Equal(t, &ProxyInfo{Type: ExplorerProxyType, Target: reported}, info)
+		assert.Equal(t, []common.Address{common.HexToAddress(proxy), reported}, path)
+		assert.Equal(t, &flagged.source, source, "the source is returned for reuse")
+	})
+
+	t.Run("detection disagrees", func(t *testing.T) {
+		original := &ProxyInfo{Type: "Slot0", Target: detected}
+		info, _, _ := newFetcher(flagged).explorerProxyInfo(context.Background(), "1", proxy, original, nil)
+		assert.Equal(t, "Slot0", info.Type)
+		assert.Equal(t, reported, info.Target)
+		assert.Equal(t, detected, original.Target, "the detected ProxyInfo is not modified")
+	})
+
+	t.Run("not flagged by the explorer", func(t *testing.T) {
+		original := &ProxyInfo{Type: "Slot0", Target: detected}
+		info, _, source := newFetcher(sourceCodeChainAPI{}).explorerProxyInfo(context.Background(), "1", proxy, original, nil)
+		assert.Same(t, original, info)
+		assert.NotNil(t, source)
+	})
+
+	t.Run("explorer without getsourcecode", func(t *testing.T) {
+		info, _, source := newFetcher(staticChainAPI{}).explorerProxyInfo(context.Background(), "1", proxy, nil, nil)
+		assert.Nil(t, info)
+		assert.Nil(t, source)
+	})
+}
+
+func TestExplorerURL(t *testing.T) {
+	fetcher := NewABIFetcher(NewABIStorage(time.Hour, 0), map[int]ChainAPI{
+		1:  &GenericEtherscanAPI{BaseURL: "https://api.etherscan.io/api", EnvKey: "ETHEREUM_API_KEY", ExplorerURL: "https://etherscan.io"},
+		10: &MockEtherscanAPI{},
+	}, DefaultFetcherConfig())
+	address := "0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48"
+
+	assert.Equal(t, "https://etherscan.io/address/"+address, fetcher.explorerURL("1", address))
+	assert.Empty(t, fetcher.explorerURL("10", address), "chain API without explorer")
+	assert.Empty(t, fetcher.explorerURL("999", address), "unknown chain")
+}
+
+func TestCreateResponseRaw(t *testing.T) {
+	fetcher := NewABIFetcher(NewABIStorage(time.Hour, 0), map[int]ChainAPI{}, DefaultFetcherConfig())
+	item := StorageItem{ABI: `[{"type":"function","name":"a","inputs":[],"outputs":[],"stateMutability":"view"}]`, RawABI: `[ {"type":"function","name":"a"} ]`, IsDecompiled: true}
+	processors := map[string]bool{"annotateSelectors": true}
+
+	response, err := fetcher.createResponse("1", "0x1000000000000000000000000000000000000001", item, FetchOptions{Raw: true, PostProcessors: processors})
+	assert.NoError(t, err)
+	assert.Equal(t, item.RawABI, response["abi"])
+
+	response, err = fetcher.createResponse("1", "0x1000000000000000000000000000000000000001", item, FetchOptions{PostProcessors: processors})
+	assert.NoError(t, err)
+	assert.Contains(t, response["abi"], "selector")
+}
+
+func TestContractMetadata(t *testing.T) {
+	address := "0x1000000000000000000000000000000000000001"
+	api := sourceCodeChainAPI{source: SourceCode{ABI: "[]", ContractName: "Token", CompilerVersion: "v0.8.20+commit.a1b79de6"}}
+	fetcher := NewABIFetcher(NewABIStorage(time.Hour, 0), map[int]ChainAPI{1: api}, DefaultFetcherConfig())
+
+	fetched, err := fetcher.getABI(context.Background(), "1", address, "127.0.0.1:1", nil, true)
+	assert.NoError(t, err)
+	assert.Equal(t, ContractMetadata{ContractName: "Token", CompilerVersion: "v0.8.20+commit.a1b79de6", VerificationStatus: "verified"}, fetched.Metadata)
+
+	response, err := fetcher.createResponse("1", address, StorageItem{ABI: "[]", ContractMetadata: fetched.Metadata}, FetchOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "Token", response["contractName"])
+	assert.Equal(t, "v0.8.20+commit.a1b79de6", response["compilerVersion"])
+
+	response, err = fetcher.createResponse("1", address, StorageItem{ABI: "[]", IsDecompiled: true}, FetchOptions{})
+	assert.NoError(t, err)
+	assert.Contains(t, response, "contractName")
+	assert.Nil(t, response["contractName"])
+	assert.Nil(t, response["compilerVersion"])
+}
+
+func TestLookupABICoalescesConcurrentRequests(t *testing.T) {
+	var calls atomic.Int32
+	received := make(chan struct{}, 1)
+	release := make(chan struct{})
+	node := newFakeNode(t, map[string]rpcHandler{
+		"eth_getCode": func(params []json.RawMessage) (interface{}, error) {
+			calls.Add(1)
+			select {
+			case received <- struct{}{}:
+			default:
+			}
+			<-release
+			// No code, so every lookup fails with ContractNotFoundError.
+			return hexutil.Bytes{}, nil
+		},
+	})
+
+	fetcher := newTestFetcher(t, DefaultFetcherConfig(), nil)
+	address := "0x1000000000000000000000000000000000000001"
+
+	const requests = 10
+	errs := make(chan error, requests)
+	for i := 0; i < requests; i++ {
+		go func() {
+			_, _, err := fetcher.lookupABI(context.Background(), "1", address, node.URL, FetchOptions{})
+			errs <- err
+		}()
+	}
+	<-received
+	// Give the remaining requests time to join the fetch in flight.
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+
+	for i := 0; i < requests; i++ {
+		assert.IsType(t, &ContractNotFoundError{}, <-errs)
+	}
+	assert.Equal(t, int32(1), calls.Load(), "one fetch serves all requests")
+
+	_, _, err := fetcher.lookupABI(context.Background(), "1", address, node.URL, FetchOptions{})
+	assert.IsType(t, &ContractNotFoundError{}, err)
+	assert.Equal(t, int32(2), calls.Load(), "results are not kept once the fetch completed")
+}
+
+func TestFetchABIForceBypassesCache(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	storage := NewABIStorage(time.Hour, 0)
+	fetcher := NewABIFetcher(storage, map[int]ChainAPI{}, DefaultFetcherConfig())
+	address := "0x1000000000000000000000000000000000000001"
+	storage.Set("1-"+address, StorageItem{ABI: "[]"})
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("GET", "/", nil)
+	// Nothing listens on this port, so only a cache hit can succeed.
+	unreachableRPC := "127.0.0.1:1"
+
+	_, err := fetcher.FetchABI(c, "1", address, unreachableRPC, FetchOptions{})
+	assert.NoError(t, err)
+
+	_, err = fetcher.FetchABI(c, "1", address, unreachableRPC, FetchOptions{Force: true})
+	assert.Error(t, err, "force skips the cached entry and goes to the node")
+}
+
+func TestFetchABIHonorsNegativeCache(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	storage := NewABIStorage(time.Hour, 0)
+	fetcher := NewABIFetcher(storage, map[int]ChainAPI{}, DefaultFetcherConfig())
+	address := "0x1000000000000000000000000000000000000001"
+	storage.Set("1-"+address, StorageItem{Error: "heimdall API error: decompilation failed", TTL: time.Minute})
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("GET", "/", nil)
+
+	_, err := fetcher.FetchABI(c, "1", address, "127.0.0.1:1", FetchOptions{})
+	var unavailable *ABIUnavailableError
+	assert.True(t, errors.As(err, &unavailable), "the cached failure is returned without contacting the node")
+	status, _ := errorResponse(err)
+	assert.Equal(t, http.StatusNotFound, status)
+
+	_, err = fetcher.FetchABI(c, "1", address, "127.0.0.1:1", FetchOptions{Force: true})
+	assert.False(t, errors.As(err, &unavailable), "force retries the lookup")
+}
+
+// failingChainAPI fails every request with err.
+type failingChainAPI struct {
+	err error
+}
+
+func (f failingChainAPI) GetABIFromEtherscan(ctx context.Context, address string) (string, error) {
+	return "", f.err
+}
+
+func TestGetABIReportsExplorerErrors(t *testing.T) {
+	sourcify := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"output":{"abi":[]}}`)
+	}))
+	defer sourcify.Close()
+	config := DefaultFetcherConfig()
+	config.SourcifyURL = sourcify.URL
+	address := "0x1000000000000000000000000000000000000001"
+
+	fetcher := NewABIFetcher(NewABIStorage(time.Hour, 0), map[int]ChainAPI{1: staticChainAPI{}}, config)
+	fetched, err := fetcher.getABI(context.Background(), "1", address, "127.0.0.1:1", nil, true)
+	assert.NoError(t, err)
+	assert.NoError(t, fetched.ExplorerError, "unverified contracts are an expected fallback")
+
+	keyErr := &MissingAPIKeyError{envKey: "ETHEREUM_API_KEY"}
+	fetcher = NewABIFetcher(NewABIStorage(time.Hour, 0), map[int]ChainAPI{1: failingChainAPI{err: keyErr}}, config)
+	fetched, err = fetcher.getABI(context.Background(), "1", address, "127.0.0.1:1", nil, true)
+	assert.NoError(t, err)
+	assert.Equal(t, keyErr, fetched.ExplorerError)
+
+	response, err := fetcher.createResponse("1", address, StorageItem{ABI: "[]", ExplorerError: keyErr.Error()}, FetchOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "API key not set for chain: ETHEREUM_API_KEY", response["explorerError"])
+}
+
+func TestGetDiamondABI(t *testing.T) {
+	first := common.HexToAddress("0xfa00000000000000000000000000000000000001")
+	second := common.HexToAddress("0xfa00000000000000000000000000000000000002")
+	fetcher := NewABIFetcher(NewABIStorage(time.Hour, 0), map[int]ChainAPI{1: staticChainAPI{
+		first.Hex():  `[{"type":"function","name":"diamondCut","inputs":[],"outputs":[],"stateMutability":"nonpayable"},{"type":"event","name":"DiamondCut","inputs":[],"anonymous":false}]`,
+		second.Hex(): `[{"type":"function","name":"facets","inputs":[],"outputs":[],"stateMutability":"view"},{"type":"event","name":"DiamondCut","inputs":[],"anonymous":false}]`,
+	}}, DefaultFetcherConfig())
+
+	fetched, err := fetcher.getDiamondABI(context.Background(), "1", []common.Address{first, second}, "127.0.0.1:1", true)
+	assert.NoError(t, err)
+	assert.False(t, fetched.IsDecompiled)
+
+	var entries []map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(fetched.ABI), &entries))
+	assert.Len(t, entries, 3, "entries shared by facets appear once")
+	assert.Equal(t, "diamondCut", entries[0]["name"])
+	assert.Equal(t, first.Hex(), entries[0]["source"])
+	assert.Equal(t, first.Hex(), entries[1]["source"], "the first facet wins for shared entries")
+	assert.Equal(t, "facets", entries[2]["name"])
+	assert.Equal(t, second.Hex(), entries[2]["source"])
+}
+
+func TestResolveProxyChain(t *testing.T) {
+	ctx := context.Background()
+	chain := make([]common.Address, 8)
+	client := newMockContractReader()
+	for i := range chain {
+		chain[i] = common.HexToAddress(fmt.Sprintf("0x%040x", i+1))
+		client.code[chain[i]] = common.FromHex("0x6080")
+	}
+	link := func(from, to int) { client.setStorage(chain[from], EIP1967LogicSlot, chain[to]) }
+
+	t.Run("not a proxy", func(t *testing.T) {
+		proxyInfo, path := resolveProxyChain(ctx, client, chain[7])
+		assert.Nil(t, proxyInfo)
+		assert.Nil(t, path)
+	})
+
+	t.Run("nested proxies", func(t *testing.T) {
+		link(0, 1)
+		link(1, 2)
+		proxyInfo, path := resolveProxyChain(ctx, client, chain[0])
+		assert.Equal(t, chain[2], proxyInfo.Target)
+		assert.Equal(t, "Eip1967Direct", proxyInfo.Type)
+		assert.Equal(t, chain[:3], path)
+	})
+
+	t.Run("depth limit", func(t *testing.T) {
+		for i := 2; i < 7; i++ {
+			link(i, i+1)
+		}
+		proxyInfo, path := resolveProxyChain(ctx, client, chain[0])
+		assert.Equal(t, chain[maxProxyDepth], proxyInfo.Target)
+		assert.Equal(t, chain[:maxProxyDepth+1], path)
+	})
+
+	t.Run("cycle", func(t *testing.T) {
+		cycle := newMockContractReader()
+		cycle.setStorage(chain[0], EIP1967LogicSlot, chain[1])
+		cycle.setStorage(chain[1], EIP1967LogicSlot, chain[0])
+		proxyInfo, path := resolveProxyChain(ctx, cycle, chain[0])
+		assert.Equal(t, chain[1], proxyInfo.Target)
+		assert.Equal(t, chain[:2], path)
+	})
+
+	t.Run("diamond behind a proxy", func(t *testing.T) {
+		facet := common.HexToAddress("0xfa00000000000000000000000000000000000001")
+		diamond := newMockContractReader()
+		diamond.setStorage(chain[0], EIP1967LogicSlot, chain[1])
+		diamond.setCallResult(chain[1], DiamondFacetsMethod, encodeDiamondFacets(t, facet))
+		proxyInfo, path := resolveProxyChain(ctx, diamond, chain[0])
+		assert.Equal(t, chain[1], proxyInfo.Target)
+		assert.Equal(t, []common.Address{facet}, proxyInfo.Facets)
+		assert.Equal(t, chain[:2], path)
+	})
+}
+
+func TestMergeProxyABI(t *testing.T) {
+	proxy := "0x1000000000000000000000000000000000000001"
+	implementation := "0x2000000000000000000000000000000000000002"
+	storage := NewABIStorage(time.Hour, 0)
+	api := staticChainAPI{
+		proxy: `[{"type":"function","name":"upgradeTo","inputs":[{"name":"impl","type":"address"}],"outputs":[],"stateMutability":"nonpayable"},` +
+			`{"type":"function","name":"transfer","inputs":[{"name":"proxyTo","type":"address"},{"name":"proxyAmount","type":"uint256"}],"outputs":[],"stateMutability":"nonpayable"}]`,
+	}
+	fetcher := NewABIFetcher(storage, map[int]ChainAPI{1: api}, DefaultFetcherConfig())
+	item := StorageItem{ABI: erc20TransferABI, Implementation: implementation, IsProxy: true}
+	storage.Set("1-"+proxy, item)
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("GET", "/", nil)
+	response, err := fetcher.FetchABI(c, "1", proxy, "127.0.0.1:1", FetchOptions{MergeProxyABI: true})
+	assert.NoError(t, err)
+
+	var entries []map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(response["abi"].(string)), &entries))
+	assert.Len(t, entries, 4)
+	var names []string
+	for _, entry := range entries {
+		if entry["type"] == "function" {
+			names = append(names, entry["name"].(string))
+			if entry["name"] == "transfer" {
+				assert.Equal(t, "to", entry["inputs"].([]interface{})[0].(map[string]interface{})["name"], "the implementation wins on collisions")
+				assert.Equal(t, implementation, entry["source"])
+			} else {
+				assert.Equal(t, proxy, entry["source"])
+			}
+		}
+	}
+	assert.ElementsMatch(t, []string{"transfer", "upgradeTo"}, names)
+
+	cached, _ := storage.Get("1-" + proxy)
+	canonical, _ := normalizeABI(api[proxy])
+	assert.Equal(t, canonical, cached.ProxyABI, "the proxy ABI is cached")
+	assert.Equal(t, erc20TransferABI, cached.ABI, "the cached implementation ABI stays unmerged")
+
+	response, err = fetcher.FetchABI(c, "1", proxy, "127.0.0.1:1", FetchOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, erc20TransferABI, response["abi"])
+}
+
+func TestValidateChainAndAddress(t *testing.T) {
+	checksummed := "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed"
+	for _, input := range []string{
+		checksummed,
+		"0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed",
+		"0x5AAEB6053F3E94C9B9A09F33669435E7EF1BEAED",
+	} {
+		address, err := validateChainAndAddress("1", input)
+		assert.NoError(t, err, input)
+		assert.Equal(t, checksummed, address, input)
+	}
+
+	for input, code := range map[string]string{
+		"0xZZZeb6053F3E94C9b9A09f33669435E7Ef1BeAed": CodeInvalidAddressHex,
+		"0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAe":  CodeInvalidAddressLength,
+		"005aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed": CodeInvalidAddressHex,
+		"0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAeD": CodeInvalidChecksum,
+	} {
+		_, err := validateChainAndAddress("1", input)
+		var invalid *InvalidInputError
+		if assert.True(t, errors.As(err, &invalid), input) {
+			assert.Equal(t, code, invalid.code, input)
+		}
+	}
+
+	_, err := validateChainAndAddress("mainnet", checksummed)
+	var invalid *InvalidInputError
+	if assert.True(t, errors.As(err, &invalid)) {
+		assert.Equal(t, CodeInvalidChainID, invalid.code)
+	}
+}
+
+func TestFetchABINormalizesCacheKey(t *testing.T) {
+	storage := NewABIStorage(time.Hour, 0)
+	fetcher := NewABIFetcher(storage, map[int]ChainAPI{}, DefaultFetcherConfig())
+	storage.Set("1-0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed", StorageItem{ABI: "[]"})
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("GET", "/", nil)
+	_, err := fetcher.FetchABI(c, "1", "0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed", "127.0.0.1:1", FetchOptions{})
+	assert.NoError(t, err, "the lowercase address hits the checksummed cache entry")
+
+	deleted, err := fetcher.InvalidateABI("1", "0x5AAEB6053F3E94C9B9A09F33669435E7EF1BEAED")
+	assert.NoError(t, err)
+	assert.True(t, deleted)
+}
+
+func TestCreateResponseStructuredABI(t *testing.T) {
+	fetcher := NewABIFetcher(NewABIStorage(time.Hour, 0), map[int]ChainAPI{}, DefaultFetcherConfig())
+	address := "0x1000000000000000000000000000000000000001"
+
+	response, err := fetcher.createResponse("1", address, StorageItem{ABI: erc20TransferABI}, FetchOptions{StructuredABI: true})
+	assert.NoError(t, err)
+	encoded, err := json.Marshal(response)
+	assert.NoError(t, err)
+	var decoded struct {
+		ABI []map[string]interface{} `json:"abi"`
+	}
+	assert.NoError(t, json.Unmarshal(encoded, &decoded))
+	assert.Len(t, decoded.ABI, 3)
+	assert.Equal(t, "transfer", decoded.ABI[0]["name"])
+
+	response, err = fetcher.createResponse("1", address, StorageItem{ABI: "Error: decompilation failed"}, FetchOptions{StructuredABI: true})
+	assert.NoError(t, err)
+	assert.Equal(t, "Error: decompilation failed", response["abi"])
+	assert.Contains(t, response, "warning")
+}
+
+func TestCreateResponseProxyDetails(t *testing.T) {
+	fetcher := NewABIFetcher(NewABIStorage(time.Hour, 0), map[int]ChainAPI{}, DefaultFetcherConfig())
+	address := "0x1000000000000000000000000000000000000001"
+
+	response, err := fetcher.createResponse("1", address, StorageItem{ABI: "[]", IsProxy: true, ProxyType: "Eip1167", Immutable: true}, FetchOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "Eip1167", response["proxyType"])
+	assert.Equal(t, true, response["immutable"])
+
+	response, err = fetcher.createResponse("1", address, StorageItem{ABI: "[]", IsProxy: true, ProxyType: "Eip1967Direct"}, FetchOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "Eip1967Direct", response["proxyType"])
+	assert.Equal(t, false, response["immutable"])
+
+	response, err = fetcher.createResponse("1", address, StorageItem{ABI: "[]"}, FetchOptions{})
+	assert.NoError(t, err)
+	assert.NotContains(t, response, "proxyType")
+	assert.NotContains(t, response, "immutable")
+}
+
+func TestDetectProxy(t *testing.T) {
+	fetcher := NewABIFetcher(NewABIStorage(time.Hour, 0), map[int]ChainAPI{}, DefaultFetcherConfig())
+	proxy := common.HexToAddress("0x1000000000000000000000000000000000000001")
+	implementation := common.HexToAddress("0x2000000000000000000000000000000000000002")
+	admin := common.HexToAddress("0x3000000000000000000000000000000000000003")
+
+	client := newMockContractReader()
+	client.code[proxy] = []byte{0x60, 0x80}
+	client.setStorage(proxy, EIP1967LogicSlot, implementation)
+	client.setStorage(proxy, EIP1967AdminSlot, admin)
+
+	response, err := fetcher.detectProxy(context.Background(), client, proxy.Hex())
+	assert.NoError(t, err)
+	assert.Equal(t, gin.H{
+		"isProxy":   true,
+		"proxyType": "Eip1967Direct",
+		"immutable": false,
+		"target":    implementation.Hex(),
+		"admin":     admin.Hex(),
+	}, response)
+
+	client.code[implementation] = []byte{0x60, 0x80}
+	response, err = fetcher.detectProxy(context.Background(), client, implementation.Hex())
+	assert.NoError(t, err)
+	assert.Equal(t, gin.H{"isProxy": false}, response)
+
+	_, err = fetcher.detectProxy(context.Background(), client, admin.Hex())
+	var notFound *ContractNotFoundError
+	assert.True(t, errors.As(err, &notFound))
+}
+
+func TestLookupABISeparatesPinnedBlocks(t *testing.T) {
+	var codeBlocks []string
+	node := newFakeNode(t, map[string]rpcHandler{
+		"eth_getCode": func(params []json.RawMessage) (interface{}, error) {
+			codeBlocks = append(codeBlocks, rpcParam[string](params, 1))
+			return hexutil.Bytes{}, nil
+		},
+	})
+
+	fetcher := newTestFetcher(t, DefaultFetcherConfig(), nil)
+	address := "0x1000000000000000000000000000000000000001"
+	latest := StorageItem{ABI: `[{"type":"function","name":"latest"}]`}
+	pinned := StorageItem{ABI: `[{"type":"function","name":"pinned"}]`}
+	fetcher.storage.Set(abiCacheKey("1", address, nil), latest)
+	fetcher.storage.Set(abiCacheKey("1", address, big.NewInt(100)), pinned)
+
+	item, source, err := fetcher.lookupABI(context.Background(), "1", address, node.URL, FetchOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, SourceCache, source)
+	assert.Equal(t, latest.ABI, item.ABI)
+
+	item, source, err = fetcher.lookupABI(context.Background(), "1", address, node.URL, FetchOptions{Block: big.NewInt(100)})
+	assert.NoError(t, err)
+	assert.Equal(t, SourceCache, source)
+	assert.Equal(t, pinned.ABI, item.ABI)
+	assert.Empty(t, codeBlocks)
+
+	// Another block is neither served from the latest nor the pinned entry.
+	_, _, err = fetcher.lookupABI(context.Background(), "1", address, node.URL, FetchOptions{Block: big.NewInt(200)})
+	assert.IsType(t, &ContractNotFoundError{}, err)
+	assert.Equal(t, []string{"0xc8"}, codeBlocks, "the contract is checked at the pinned block")
+}
+
+func TestValidateContractClassifiesEmptyAccounts(t *testing.T) {
+	fetcher := &ABIFetcher{}
+	client := newMockContractReader()
+	eoa := common.HexToAddress("0x1000000000000000000000000000000000000001")
+	empty := common.HexToAddress("0x2000000000000000000000000000000000000002")
+	client.nonces[eoa] = 7
+
+	_, err := fetcher.validateContract(context.Background(), newBudgetedReader(client, 1), eoa.Hex())
+	var notFound *ContractNotFoundError
+	if assert.True(t, errors.As(err, &notFound)) {
+		assert.Equal(t, CodeExternallyOwned, notFound.code)
+		assert.Contains(t, err.Error(), "externally owned account")
+	}
+
+	_, err = fetcher.validateContract(context.Background(), client, empty.Hex())
+	if assert.True(t, errors.As(err, &notFound)) {
+		assert.Equal(t, CodeNoCode, notFound.code)
+		assert.Contains(t, err.Error(), "self-destructed")
+	}
+
+	status, body := errorResponse(err)
+	assert.Equal(t, http.StatusNotFound, status)
+	assert.Equal(t, CodeNoCode, body["code"])
+}
+
+func TestHeimdallRequestURL(t *testing.T) {
+	var received *url.URL
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.URL
+	}))
+	defer server.Close()
+
+	address := "0x1000000000000000000000000000000000000001"
+	rpcURL := "rpc.example/eth?key=a&b=c#frag"
+	resp, err := http.Get(heimdallRequestURL(server.URL+"/", address, rpcURL))
+	assert.NoError(t, err)
+	resp.Body.Close()
+
+	assert.Equal(t, "/"+address, received.Path)
+	assert.Equal(t, url.Values{"rpc_url": {rpcURL}}, received.Query(), "the rpcURL arrives intact as the only parameter")
+}
+
+func TestGetABIUsesConfiguredHeimdall(t *testing.T) {
+	var received *url.URL
+	heimdall := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.URL
+		fmt.Fprint(w, `[{"type":"function","name":"decompiled","inputs":[],"outputs":[],"stateMutability":"view"}]`)
+	}))
+	defer heimdall.Close()
+	sourcify := httptest.NewServer(http.NotFoundHandler())
+	defer sourcify.Close()
+
+	t.Setenv("HEIMDALL_URL", heimdall.URL)
+	t.Setenv("SOURCIFY_URL", sourcify.URL)
+	fetcher := NewABIFetcher(NewABIStorage(time.Hour, 0), map[int]ChainAPI{1: staticChainAPI{}}, fetcherConfigFromEnv())
+
+	address := "0x1000000000000000000000000000000000000001"
+	fetched, err := fetcher.getABI(context.Background(), "1", address, "rpc.example/eth", nil, true)
+	assert.NoError(t, err)
+	assert.True(t, fetched.IsDecompiled)
+	assert.Contains(t, fetched.ABI, "decompiled")
+	if assert.NotNil(t, received) {
+		assert.Equal(t, "/"+address, received.Path)
+		assert.Equal(t, "rpc.example/eth", received.Query().Get("rpc_url"))
+	}
+}
+
+func TestHeimdallCoalescesConcurrentDecompilations(t *testing.T) {
+	var decompilations atomic.Int32
+	received := make(chan struct{}, 1)
+	release := make(chan struct{})
+	heimdall := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		decompilations.Add(1)
+		select {
+		case received <- struct{}{}:
+		default:
+		}
+		<-release
+		fmt.Fprint(w, `[{"type":"function","name":"decompiled","inputs":[],"outputs":[],"stateMutability":"view"}]`)
+	}))
+	defer heimdall.Close()
+	sourcify := httptest.NewServer(http.NotFoundHandler())
+	defer sourcify.Close()
+
+	config := DefaultFetcherConfig()
+	config.HeimdallURL = heimdall.URL
+	config.SourcifyURL = sourcify.URL
+	fetcher := NewABIFetcher(NewABIStorage(time.Hour, 0), map[int]ChainAPI{}, config)
+	address := "0x1000000000000000000000000000000000000001"
+
+	const requests = 10
+	results := make(chan fetchedABI, requests)
+	for i := 0; i < requests; i++ {
+		go func() {
+			fetched, err := fetcher.getABI(context.Background(), "1", address, "rpc.example/eth", nil, true)
+			assert.NoError(t, err)
+			results <- fetched
+		}()
+	}
+	<-received
+	// Give the remaining requests time to join the decompilation in flight.
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+
+	for i := 0; i < requests; i++ {
+		fetched := <-results
+		assert.True(t, fetched.IsDecompiled)
+		assert.Contains(t, fetched.ABI, "decompiled")
+	}
+	assert.Equal(t, int32(1), decompilations.Load(), "one decompilation serves all requests")
+
+	_, err := fetcher.getABI(context.Background(), "1", address, "rpc.example/other", nil, true)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), decompilations.Load(), "requests through another RPC URL are not coalesced")
+}
+
+func TestDecompilationToggle(t *testing.T) {
+	var decompilations atomic.Int32
+	heimdall := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		decompilations.Add(1)
+		fmt.Fprint(w, `[{"type":"function","name":"decompiled","inputs":[],"outputs":[],"stateMutability":"view"}]`)
+	}))
+	defer heimdall.Close()
+	sourcify := httptest.NewServer(http.NotFoundHandler())
+	defer sourcify.Close()
+
+	config := DefaultFetcherConfig()
+	config.HeimdallURL = heimdall.URL
+	config.SourcifyURL = sourcify.URL
+	address := "0x1000000000000000000000000000000000000001"
+
+	t.Run("enabled", func(t *testing.T) {
+		fetcher := NewABIFetcher(NewABIStorage(time.Hour, 0), map[int]ChainAPI{1: staticChainAPI{}}, config)
+		fetched, err := fetcher.getABI(context.Background(), "1", address, "rpc.example/eth", nil, fetcher.decompile(FetchOptions{}))
+		assert.NoError(t, err)
+		assert.True(t, fetched.IsDecompiled)
+		assert.Equal(t, int32(1), decompilations.Load())
+	})
+
+	t.Run("disabled per request", func(t *testing.T) {
+		fetcher := NewABIFetcher(NewABIStorage(time.Hour, 0), map[int]ChainAPI{1: staticChainAPI{}}, config)
+		_, err := fetcher.getABI(context.Background(), "1", address, "rpc.example/eth", nil, fetcher.decompile(FetchOptions{SkipDecompilation: true}))
+		assert.IsType(t, &VerifiedABINotFoundError{}, err)
+		assert.Equal(t, int32(1), decompilations.Load(), "Heimdall is not called")
+		status, body := errorResponse(err)
+		assert.Equal(t, http.StatusNotFound, status)
+		assert.Equal(t, false, body["verified"])
+	})
+
+	t.Run("disabled by config", func(t *testing.T) {
+		disabled := config
+		disabled.DisableDecompilation = true
+		storage := NewABIStorage(time.Hour, 0)
+		fetcher := NewABIFetcher(storage, map[int]ChainAPI{1: staticChainAPI{}}, disabled)
+		_, err := fetcher.getABI(context.Background(), "1", address, "rpc.example/eth", nil, fetcher.decompile(FetchOptions{}))
+		assert.IsType(t, &VerifiedABINotFoundError{}, err)
+
+		storage.Set("1-"+address, StorageItem{ABI: "[]", IsDecompiled: true})
+		_, _, err = fetcher.lookupABI(context.Background(), "1", address, "rpc.example/eth", FetchOptions{})
+		assert.IsType(t, &VerifiedABINotFoundError{}, err, "cached decompiled ABIs are not served either")
+	})
+
+	t.Run("explorer failure is not reported as unverified", func(t *testing.T) {
+		fetcher := NewABIFetcher(NewABIStorage(time.Hour, 0), map[int]ChainAPI{1: failingChainAPI{err: &NetworkError{err: errors.New("timeout")}}}, config)
+		_, err := fetcher.getABI(context.Background(), "1", address, "rpc.example/eth", nil, false)
+		assert.IsType(t, &NetworkError{}, err)
+	})
+}
+
+func TestVerifiedOnly(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	node := newContractNode(t)
+	var decompilations atomic.Int32
+	heimdall := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		decompilations.Add(1)
+		fmt.Fprint(w, `[{"type":"function","name":"decompiled","inputs":[],"outputs":[],"stateMutability":"view"}]`)
+	}))
+	defer heimdall.Close()
+
+	config := DefaultFetcherConfig()
+	config.HeimdallURL = heimdall.URL
+	verified := "0x1000000000000000000000000000000000000001"
+	unverified := "0x2000000000000000000000000000000000000002"
+	clone := "0x3000000000000000000000000000000000000003"
+	explorer := staticChainAPI{
+		verified: `[{"type":"function","name":"verified","inputs":[],"outputs":[],"stateMutability":"view"}]`,
+	}
+	newFetcher := func(config FetcherConfig) *ABIFetcher {
+		fetcher := newTestFetcher(t, config, map[int]ChainAPI{1: explorer})
+		// newContractNode serves 0x6080 followed by the address as code.
+		fetcher.knownABIs.Learn(append([]byte{0x60, 0x80}, common.HexToAddress(clone).Bytes()...), "Clone", `[{"type":"function","name":"cloned","inputs":[],"outputs":[],"stateMutability":"view"}]`)
+		return fetcher
+	}
+	strictOpts := FetchOptions{VerifiedOnly: true}
+
+	t.Run("per request", func(t *testing.T) {
+		fetcher := newFetcher(config)
+		item, _, err := fetcher.lookupABI(context.Background(), "1", verified, node.URL, strictOpts)
+		assert.NoError(t, err)
+		assert.Contains(t, item.ABI, "verified")
+		assert.False(t, item.IsDecompiled)
+
+		for _, address := range []string{unverified, clone} {
+			_, _, err = fetcher.lookupABI(context.Background(), "1", address, node.URL, strictOpts)
+			assert.IsType(t, &UnverifiedABIError{}, err, address)
+		}
+		assert.Zero(t, decompilations.Load(), "Heimdall is not called")
+		status, body := errorResponse(err)
+		assert.Equal(t, http.StatusNotFound, status)
+		assert.Equal(t, CodeUnverified, body["code"])
+		assert.Equal(t, false, body["verified"])
+
+		// ABIs cached for other requests are not served either.
+		item, _, err = fetcher.lookupABI(context.Background(), "1", unverified, node.URL, FetchOptions{})
+		assert.NoError(t, err)
+		assert.True(t, item.IsDecompiled)
+		item, _, err = fetcher.lookupABI(context.Background(), "1", clone, node.URL, FetchOptions{})
+		assert.NoError(t, err)
+		assert.Equal(t, "bytecode_match", item.VerificationStatus)
+		for _, address := range []string{unverified, clone} {
+			_, _, err = fetcher.lookupABI(context.Background(), "1", address, node.URL, strictOpts)
+			assert.IsType(t, &UnverifiedABIError{}, err, address)
+		}
+	})
+
+	t.Run("by config", func(t *testing.T) {
+		strict := config
+		strict.StrictVerified = true
+		fetcher := newFetcher(strict)
+		decompilations.Store(0)
+		_, _, err := fetcher.lookupABI(context.Background(), "1", verified, node.URL, FetchOptions{})
+		assert.NoError(t, err)
+		_, _, err = fetcher.lookupABI(context.Background(), "1", unverified, node.URL, FetchOptions{})
+		assert.IsType(t, &UnverifiedABIError{}, err)
+		assert.Zero(t, decompilations.Load())
+	})
+
+	t.Run("query parameter", func(t *testing.T) {
+		previous := abiFetcher
+		defer func() { abiFetcher = previous }()
+		abiFetcher = newFetcher(config)
+		router := gin.New()
+		router.GET("/abi/:chainId/:address/*rpcUrl", getABI)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/abi/1/"+clone+"/"+node.URL+"?verifiedOnly=true", nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Contains(t, w.Body.String(), CodeUnverified)
+
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest("GET", "/abi/1/"+verified+"/"+node.URL+"?verifiedOnly=true", nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"isDecompiled":false`)
+	})
+}
+
+func TestGetABIRejectsInvalidABIs(t *testing.T) {
+	address := "0x1000000000000000000000000000000000000001"
+	valid := `[{"type":"function","name":"verified","inputs":[],"outputs":[],"stateMutability":"view"}]`
+	explorer := staticChainAPI{address: "Max rate limit reached"}
+	serve := func(body string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, body)
+		}))
+	}
+
+	t.Run("falls through to the next source", func(t *testing.T) {
+		sourcify := serve(`{"output":{"abi":` + valid + `}}`)
+		defer sourcify.Close()
+		config := DefaultFetcherConfig()
+		config.SourcifyURL = sourcify.URL
+		fetcher := NewABIFetcher(NewABIStorage(time.Hour, 0), map[int]ChainAPI{1: explorer}, config)
+
+		fetched, err := fetcher.getABI(context.Background(), "1", address, "rpc.example/eth", nil, true)
+		assert.NoError(t, err)
+		assert.Equal(t, SourceSourcify, fetched.Source)
+		assert.JSONEq(t, valid, fetched.ABI)
+		assert.IsType(t, &InvalidABIError{}, fetched.ExplorerError, "the explorer's failure is reported")
+	})
+
+	t.Run("last source invalid", func(t *testing.T) {
+		sourcify := httptest.NewServer(http.NotFoundHandler())
+		defer sourcify.Close()
+		heimdall := serve(`[{"type":"function","name":"truncated","inputs":[`)
+		defer heimdall.Close()
+		config := DefaultFetcherConfig()
+		config.SourcifyURL = sourcify.URL
+		config.HeimdallURL = heimdall.URL
+		fetcher := NewABIFetcher(NewABIStorage(time.Hour, 0), map[int]ChainAPI{1: staticChainAPI{}}, config)
+
+		_, err := fetcher.getABI(context.Background(), "1", address, "rpc.example/eth", nil, true)
+		var invalidErr *InvalidABIError
+		if assert.ErrorAs(t, err, &invalidErr) {
+			assert.Equal(t, SourceHeimdall, invalidErr.source)
+		}
+		status, body := errorResponse(err)
+		assert.Equal(t, http.StatusBadGateway, status)
+		assert.Equal(t, SourceHeimdall, body["source"])
+	})
+
+	t.Run("last verified source invalid", func(t *testing.T) {
+		sourcify := serve(`{"output":{"abi":"Error: not found"}}`)
+		defer sourcify.Close()
+		config := DefaultFetcherConfig()
+		config.SourcifyURL = sourcify.URL
+		fetcher := NewABIFetcher(NewABIStorage(time.Hour, 0), map[int]ChainAPI{1: staticChainAPI{}}, config)
+
+		_, err := fetcher.getABI(context.Background(), "1", address, "rpc.example/eth", nil, false)
+		var invalidErr *InvalidABIError
+		if assert.ErrorAs(t, err, &invalidErr) {
+			assert.Equal(t, SourceSourcify, invalidErr.source)
+		}
+	})
+
+	t.Run("over HTTP", func(t *testing.T) {
+		node := erc20Node(t)
+		heimdall := serve(`[{"type":"function","name":"truncated","inputs":[`)
+		defer heimdall.Close()
+		config := DefaultFetcherConfig()
+		config.HeimdallURL = heimdall.URL
+		previous := abiFetcher
+		defer func() { abiFetcher = previous }()
+		abiFetcher = newTestFetcher(t, config, map[int]ChainAPI{1: staticChainAPI{}})
+		router := gin.New()
+		router.GET("/abi/:chainId/:address/*rpcUrl", getABI)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/abi/1/"+address+"/"+node.URL, nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadGateway, w.Code, "the error survives being wrapped by the fetcher")
+		assert.Contains(t, w.Body.String(), `"source":"heimdall"`)
+	})
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// proxyManagementSignatures are functions proxies commonly expose themselves
+// for administration. They do not indicate that a proxy handles calls locally.
+var proxyManagementSignatures = []string{
+	"admin()",
+	"implementation()",
+	"changeAdmin(address)",
+	"upgradeTo(address)",
+	"upgradeToAndCall(address,bytes)",
+	"proxiableUUID()",
+	"proxyType()",
+}
+
+// entryKey identifies an ABI entry for deduplication: functions and errors by
+// selector, events by topic0 and constructor/fallback/receive by their type.
+func entryKey(raw json.RawMessage) (string, error) {
+	var entry struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(raw, &entry); err != nil {
+		return "", err
+	}
+	if _, selector, ok := entrySelector(raw); ok {
+		return entry.Type + ":" + selector, nil
+	}
+	return entry.Type, nil
+}
+
+// mergeABIs combines two ABIs into one, deduplicating entries by selector.
+// When both define the same entry the one from primary wins.
+func mergeABIs(primary string, secondary string) (string, error) {
+	var primaryEntries, secondaryEntries []json.RawMessage
+	if err := json.Unmarshal([]byte(primary), &primaryEntries); err != nil {
+		return "", fmt.Errorf("failed to parse ABI: %v", err)
+	}
+	if err := json.Unmarshal([]byte(secondary), &secondaryEntries); err != nil {
+		return "", fmt.Errorf("failed to parse ABI: %v", err)
+	}
+
+	seen := make(map[string]bool)
+	merged := make([]json.RawMessage, 0, len(primaryEntries)+len(secondaryEntries))
+	for _, raw := range append(primaryEntries, secondaryEntries...) {
+		key, err := entryKey(raw)
+		if err != nil {
+			return "", fmt.Errorf("failed to parse ABI entry: %v", err)
+		}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		merged = append(merged, raw)
+	}
+
+	out, err := json.Marshal(merged)
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+// tagABISource sets the source of every entry of abiJSON that has none to
+// address, the contract the entry originates from, so that clients can tell
+// the contracts of a merged ABI apart.
+func tagABISource(abiJSON string, address string) (string, error) {
+	var entries []map[string]interface{}
+	if err := json.Unmarshal([]byte(abiJSON), &entries); err != nil {
+		return "", fmt.Errorf("failed to parse ABI: %v", err)
+	}
+	for _, entry := range entries {
+		if _, ok := entry["source"]; !ok {
+			entry["source"] = address
+		}
+	}
+	out, err := json.Marshal(entries)
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+// mergeABIsFrom merges the ABIs of the contracts at primarySource and
+// secondarySource like mergeABIs, tagging every entry with the contract it
+// originates from.
+func mergeABIsFrom(primary string, primarySource string, secondary string, secondarySource string) (string, error) {
+	primary, err := tagABISource(primary, primarySource)
+	if err != nil {
+		return "", err
+	}
+	secondary, err = tagABISource(secondary, secondarySource)
+	if err != nil {
+		return "", err
+	}
+	return mergeABIs(primary, secondary)
+}
+
+// functionSelectors returns the set of function selectors defined in an ABI.
+func functionSelectors(abiJSON string) (map[string]bool, error) {
+	var entries []json.RawMessage
+	if err := json.Unmarshal([]byte(abiJSON), &entries); err != nil {
+		return nil, fmt.Errorf("failed to parse ABI: %v", err)
+	}
+	selectors := make(map[string]bool)
+	for _, raw := range entries {
+		key, err := entryKey(raw)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse ABI entry: %v", err)
+		}
+		if selector, ok := strings.CutPrefix(key, "function:"); ok {
+			selectors[selector] = true
+		}
+	}
+	return selectors, nil
+}
+
+// hasLocalFunctions reports whether a proxy's own ABI declares functions that
+// are neither in the implementation's ABI nor standard proxy management
+// functions, meaning the proxy only delegates part of its interface.
+func hasLocalFunctions(proxyABI string, implementationABI string) (bool, error) {
+	proxySelectors, err := functionSelectors(proxyABI)
+	if err != nil {
+		return false, err
+	}
+	implementationSelectors, err := functionSelectors(implementationABI)
+	if err != nil {
+		return false, err
+	}
+	for _, signature := range proxyManagementSignatures {
+		delete(proxySelectors, hexutil.Encode(crypto.Keccak256([]byte(signature))[:4]))
+	}
+	for selector := range proxySelectors {
+		if !implementationSelectors[selector] {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// mayHaveLocalFunctions reports whether the bytecode of a proxy dispatches on
+// a selector that is not a standard proxy management function, by looking
+// for the PUSH4 instructions function dispatchers compare the selector with.
+// It errs on the side of true, since PUSH4 also pushes other constants.
+func mayHaveLocalFunctions(code []byte) bool {
+	management := make(map[string]bool, len(proxyManagementSignatures))
+	for _, signature := range proxyManagementSignatures {
+		management[hexutil.Encode(crypto.Keccak256([]byte(signature))[:4])] = true
+	}
+	for i := 0; i < len(code); i++ {
+		if code[i] < opPush1 || code[i] > opPush32 {
+			continue
+		}
+		size := int(code[i]-opPush1) + 1
+		if code[i] == opPush4 && i+size < len(code) && !management[hexutil.Encode(code[i+1:i+1+size])] {
+			return true
+		}
+		i += size
+	}
+	return false
+}
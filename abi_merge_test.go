@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	implementationABI = `[
+  {"type":"function","name":"transfer","inputs":[{"name":"to","type":"address"},{"name":"amount","type":"uint256"}],"outputs":[{"name":"","type":"bool"}],"stateMutability":"nonpayable"},
+  {"type":"event","name":"Transfer","inputs":[{"name":"from","type":"address","indexed":true},{"name":"to","type":"address","indexed":true},{"name":"value","type":"uint256","indexed":false}],"anonymous":false}
+]`
+	transparentProxyABI = `[
+  {"type":"function","name":"upgradeTo","inputs":[{"name":"newImplementation","type":"address"}],"outputs":[],"stateMutability":"nonpayable"},
+  {"type":"function","name":"admin","inputs":[],"outputs":[{"name":"","type":"address"}],"stateMutability":"nonpayable"},
+  {"type":"fallback","stateMutability":"payable"}
+]`
+	partialProxyABI = `[
+  {"type":"function","name":"transfer","inputs":[{"name":"recipient","type":"address"},{"name":"value","type":"uint256"}],"outputs":[],"stateMutability":"payable"},
+  {"type":"function","name":"pause","inputs":[],"outputs":[],"stateMutability":"nonpayable"},
+  {"type":"fallback","stateMutability":"payable"}
+]`
+)
+
+func TestMergeABIsPrefersPrimary(t *testing.T) {
+	merged, err := mergeABIs(implementationABI, partialProxyABI)
+	assert.NoError(t, err)
+
+	var entries []map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(merged), &entries))
+	assert.Len(t, entries, 4, "transfer is deduplicated by selector")
+
+	transfer := entries[0]
+	assert.Equal(t, "nonpayable", transfer["stateMutability"], "the primary definition wins on collision")
+	assert.Equal(t, "pause", entries[2]["name"])
+	assert.Equal(t, "fallback", entries[3]["type"])
+}
+
+func TestTagABISource(t *testing.T) {
+	tagged, err := tagABISource(`[{"type":"fallback","stateMutability":"payable"},{"type":"receive","stateMutability":"payable","source":"0x2"}]`, "0x1")
+	assert.NoError(t, err)
+	assert.JSONEq(t, `[{"type":"fallback","stateMutability":"payable","source":"0x1"},{"type":"receive","stateMutability":"payable","source":"0x2"}]`, tagged, "existing tags are kept")
+
+	_, err = tagABISource("not an ABI", "0x1")
+	assert.Error(t, err)
+}
+
+func TestHasLocalFunctions(t *testing.T) {
+	partial, err := hasLocalFunctions(transparentProxyABI, implementationABI)
+	assert.NoError(t, err)
+	assert.False(t, partial, "proxy management functions do not count as local functions")
+
+	partial, err = hasLocalFunctions(partialProxyABI, implementationABI)
+	assert.NoError(t, err)
+	assert.True(t, partial)
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+)
+
+// normalizeABI checks that raw is an ABI go-ethereum can parse and returns it
+// as compact JSON with the keys of every entry sorted, so that the same ABI
+// always serializes the same way whatever formatting the source used.
+func normalizeABI(raw string) (string, error) {
+	if _, err := abi.JSON(strings.NewReader(raw)); err != nil {
+		return "", fmt.Errorf("invalid ABI: %v", err)
+	}
+	var entries []interface{}
+	if err := json.Unmarshal([]byte(raw), &entries); err != nil {
+		return "", fmt.Errorf("invalid ABI: %v", err)
+	}
+	normalized, err := json.Marshal(entries)
+	if err != nil {
+		return "", err
+	}
+	return string(normalized), nil
+}
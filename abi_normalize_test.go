@@ -0,0 +1,91 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeABI(t *testing.T) {
+	heimdallOutput := `[
+  {
+    "type": "function",
+    "name": "getOwner",
+    "inputs": [],
+    "outputs": [
+      {
+        "name": "",
+        "type": "uint256"
+      }
+    ],
+    "stateMutability": "payable"
+  }
+]`
+	normalized, err := normalizeABI(heimdallOutput)
+	assert.NoError(t, err)
+	assert.Equal(t, `[{"inputs":[],"name":"getOwner","outputs":[{"name":"","type":"uint256"}],"stateMutability":"payable","type":"function"}]`, normalized)
+
+	again, err := normalizeABI(normalized)
+	assert.NoError(t, err)
+	assert.Equal(t, normalized, again, "normalizing is idempotent")
+
+	for _, garbage := range []string{
+		"",
+		"Error: failed to decompile contract",
+		"<html><body>502 Bad Gateway</body></html>",
+		`{"type":"function","name":"getOwner"}`,
+		`[{"type":"function","name":"broken","inputs":[{"name":"x","type":"notatype"}]}]`,
+	} {
+		_, err := normalizeABI(garbage)
+		assert.Error(t, err, garbage)
+	}
+}
+
+func TestSetABICanonicalizesAllSources(t *testing.T) {
+	etherscanOutput := `[{"inputs":[],"name":"getOwner","outputs":[{"internalType":"uint256","name":"","type":"uint256"}],"stateMutability":"payable","type":"function"}, {"anonymous":false,"inputs":[],"name":"Ping","type":"event"}]`
+	heimdallOutput := `[
+  {
+    "type": "function",
+    "name": "getOwner",
+    "stateMutability": "payable",
+    "inputs": [],
+    "outputs": [
+      {
+        "type": "uint256",
+        "name": "",
+        "internalType": "uint256"
+      }
+    ]
+  },
+  {
+    "type": "event",
+    "name": "Ping",
+    "inputs": [],
+    "anonymous": false
+  }
+]`
+	var fromEtherscan, fromHeimdall fetchedABI
+	assert.NoError(t, fromEtherscan.setABI(etherscanOutput, SourceEtherscan))
+	assert.NoError(t, fromHeimdall.setABI(heimdallOutput, SourceHeimdall))
+	assert.Equal(t, fromEtherscan.ABI, fromHeimdall.ABI)
+	assert.Equal(t, heimdallOutput, fromHeimdall.RawABI, "the source's output is kept for raw responses")
+
+	_, err := abi.JSON(strings.NewReader(fromEtherscan.ABI))
+	assert.NoError(t, err, "the canonical ABI parses")
+
+	for _, invalid := range []string{
+		"Max rate limit reached",
+		`[{"type":"function","name":"truncated","inputs":[`,
+		`[{"type":"function","name":"broken","inputs":[{"name":"x","type":"notatype"}]}]`,
+	} {
+		var unparsable fetchedABI
+		err := unparsable.setABI(invalid, SourceEtherscan)
+		var invalidErr *InvalidABIError
+		if assert.ErrorAs(t, err, &invalidErr, invalid) {
+			assert.Equal(t, SourceEtherscan, invalidErr.source)
+		}
+		assert.Equal(t, fetchedABI{}, unparsable, "an invalid ABI is not set")
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadAPIKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys")
+	assert.NoError(t, os.WriteFile(path, []byte("# issued 2024-08\nkey-c\n\n  key-d  \n"), 0o600))
+
+	keys, err := loadAPIKeys(" key-a, key-b ,", path)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"key-a", "key-b", "key-c", "key-d"}, keys)
+
+	keys, err = loadAPIKeys("", "")
+	assert.NoError(t, err)
+	assert.Empty(t, keys)
+
+	_, err = loadAPIKeys("", filepath.Join(t.TempDir(), "missing"))
+	assert.Error(t, err)
+}
+
+func TestRequireAPIKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	newRouter := func(keys []string) *gin.Engine {
+		router := gin.New()
+		router.GET("/", healthCheck)
+		router.GET("/stats", requireAPIKey(keys), func(c *gin.Context) { c.Status(http.StatusOK) })
+		return router
+	}
+
+	tests := []struct {
+		name          string
+		keys          []string
+		path          string
+		authorization string
+		expected      int
+	}{
+		{"disabled", nil, "/stats", "", http.StatusOK},
+		{"missing key", []string{"key-a"}, "/stats", "", http.StatusUnauthorized},
+		{"bad key", []string{"key-a"}, "/stats", "Bearer key-b", http.StatusUnauthorized},
+		{"not a bearer token", []string{"key-a"}, "/stats", "key-a", http.StatusUnauthorized},
+		{"valid key", []string{"key-a", "key-b"}, "/stats", "Bearer key-b", http.StatusOK},
+		{"health check stays open", []string{"key-a"}, "/", "", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", tt.path, nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			newRouter(tt.keys).ServeHTTP(w, req)
+			assert.Equal(t, tt.expected, w.Code)
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BatchRequest identifies one contract of a batch lookup.
+type BatchRequest struct {
+	ChainID string `json:"chainId"`
+	Address string `json:"address"`
+	RPCURL  string `json:"rpcUrl"`
+}
+
+// BatchResult is the outcome of one batch entry: either the ABI response or
+// the error FetchABI returned.
+type BatchResult struct {
+	Response gin.H
+	Err      error
+}
+
+// FetchABIBatch fetches the ABIs of all requests, at most
+// config.BatchConcurrency at a time. Identical requests are fetched once and
+// the results are returned in request order.
+func (af *ABIFetcher) FetchABIBatch(c *gin.Context, requests []BatchRequest, opts FetchOptions) []BatchResult {
+	unique := make(map[BatchRequest]int)
+	var order []BatchRequest
+	for _, request := range requests {
+		if _, ok := unique[request]; !ok {
+			unique[request] = len(order)
+			order = append(order, request)
+		}
+	}
+
+	fetched := make([]BatchResult, len(order))
+	slots := make(chan struct{}, max(af.config.BatchConcurrency, 1))
+	var wg sync.WaitGroup
+	for i, request := range order {
+		wg.Add(1)
+		go func(i int, request BatchRequest) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+			response, err := af.FetchABI(c, request.ChainID, request.Address, request.RPCURL, opts)
+			fetched[i] = BatchResult{Response: response, Err: err}
+		}(i, request)
+	}
+	wg.Wait()
+
+	results := make([]BatchResult, len(requests))
+	for i, request := range requests {
+		results[i] = fetched[unique[request]]
+	}
+	return results
+}
+
+// ProxyBatchRequest asks for the proxy resolution of many addresses on one
+// chain, through one RPC node.
+type ProxyBatchRequest struct {
+	ChainID   string   `json:"chainId"`
+	RPCURL    string   `json:"rpcUrl"`
+	Addresses []string `json:"addresses"`
+}
+
+// FetchProxyInfoBatch resolves the proxies of all addresses of request over a
+// single connection to its RPC node, at most config.BatchConcurrency at a
+// time. Invalid addresses and failed detections are reported per address;
+// only a chain or RPC node that cannot be used fails the whole batch.
+func (af *ABIFetcher) FetchProxyInfoBatch(c *gin.Context, request ProxyBatchRequest) ([]BatchResult, error) {
+	if err := af.checkChainServed(request.ChainID); err != nil {
+		return nil, err
+	}
+	if _, err := strconv.Atoi(request.ChainID); err != nil {
+		return nil, &InvalidInputError{message: "Invalid chainId: must be a number", code: CodeInvalidChainID}
+	}
+	rpcURL := request.RPCURL
+	if rpcURL == "" {
+		rpcURL = af.defaultRPCURL(request.ChainID)
+	}
+	if rpcURL == "" {
+		return nil, &InvalidInputError{message: "Invalid rpcURL: cannot be empty", code: CodeEmptyRPCURL}
+	}
+
+	ctx := c.Request.Context()
+	client, err := af.dial(ctx, rpcURL)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	return af.detectProxies(ctx, af.newReader(client, nil), request.ChainID, request.Addresses), nil
+}
+
+// detectProxies resolves the proxies of addresses through client, at most
+// config.BatchConcurrency at a time. Identical addresses are resolved once and
+// the results are returned in request order.
+func (af *ABIFetcher) detectProxies(ctx context.Context, client ContractReader, chainId string, addresses []string) []BatchResult {
+	unique := make(map[string]int)
+	var order []string
+	for _, address := range addresses {
+		if _, ok := unique[address]; !ok {
+			unique[address] = len(order)
+			order = append(order, address)
+		}
+	}
+
+	detected := make([]BatchResult, len(order))
+	slots := make(chan struct{}, max(af.config.BatchConcurrency, 1))
+	var wg sync.WaitGroup
+	for i, address := range order {
+		wg.Add(1)
+		go func(i int, address string) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+			address, err := validateChainAndAddress(chainId, address)
+			if err != nil {
+				detected[i] = BatchResult{Err: err}
+				return
+			}
+			response, err := af.detectProxy(ctx, client, address)
+			if err == nil {
+				response["address"] = address
+			}
+			detected[i] = BatchResult{Response: response, Err: err}
+		}(i, address)
+	}
+	wg.Wait()
+
+	results := make([]BatchResult, len(addresses))
+	for i, address := range addresses {
+		results[i] = detected[unique[address]]
+	}
+	return results
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetABIBatch(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	previous := abiFetcher
+	defer func() { abiFetcher = previous }()
+	storage := NewABIStorage(time.Hour, 0)
+	config := DefaultFetcherConfig()
+	config.BatchMaxSize = 5
+	abiFetcher = NewABIFetcher(storage, map[int]ChainAPI{}, config)
+
+	router := gin.New()
+	router.POST("/abi/batch", getABIBatch)
+
+	first := "0x1000000000000000000000000000000000000001"
+	second := "0x2000000000000000000000000000000000000002"
+	storage.Set("1-"+first, StorageItem{ABI: `[{"type":"function","name":"first"}]`})
+	storage.Set("10-"+second, StorageItem{ABI: `[{"type":"function","name":"second"}]`, IsProxy: true})
+
+	body := `[
+  {"chainId": "10", "address": "` + second + `", "rpcUrl": "rpc.example"},
+  {"chainId": "1", "address": "0x1234", "rpcUrl": "rpc.example"},
+  {"chainId": "1", "address": "` + first + `", "rpcUrl": "rpc.example"},
+  {"chainId": "10", "address": "` + second + `", "rpcUrl": "rpc.example"}
+]`
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/abi/batch", strings.NewReader(body))
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var results []map[string]interface{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &results))
+	assert.Len(t, results, 4)
+	assert.Equal(t, `[{"type":"function","name":"second"}]`, results[0]["abi"])
+	assert.Equal(t, true, results[0]["isProxy"])
+	assert.Equal(t, float64(http.StatusBadRequest), results[1]["status"])
+	assert.Contains(t, results[1]["error"], "Invalid address")
+	assert.Equal(t, `[{"type":"function","name":"first"}]`, results[2]["abi"])
+	assert.Equal(t, results[0], results[3])
+	assert.Equal(t, int64(2), abiFetcher.stats.cacheHits.Load(), "duplicate entries are fetched once")
+
+	for _, invalid := range []string{`{"chainId": "1"}`, `[{}, {}, {}, {}, {}, {}]`} {
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest("POST", "/abi/batch", strings.NewReader(invalid))
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, invalid)
+	}
+}
+
+// concurrencyReader delays every CodeAt call and records how many of them were
+// in flight at once.
+type concurrencyReader struct {
+	*mockContractReader
+	active    atomic.Int64
+	maxActive atomic.Int64
+}
+
+func (r *concurrencyReader) CodeAt(ctx context.Context, account common.Address, blockNumber *big.Int) ([]byte, error) {
+	active := r.active.Add(1)
+	defer r.active.Add(-1)
+	for {
+		highest := r.maxActive.Load()
+		if active <= highest || r.maxActive.CompareAndSwap(highest, active) {
+			break
+		}
+	}
+	time.Sleep(5 * time.Millisecond)
+	return r.mockContractReader.CodeAt(ctx, account, blockNumber)
+}
+
+func TestDetectProxies(t *testing.T) {
+	config := DefaultFetcherConfig()
+	config.BatchConcurrency = 2
+	fetcher := NewABIFetcher(NewABIStorage(time.Hour, 0), map[int]ChainAPI{}, config)
+
+	proxy := common.HexToAddress("0x1000000000000000000000000000000000000001")
+	implementation := common.HexToAddress("0x2000000000000000000000000000000000000002")
+	missing := common.HexToAddress("0x3000000000000000000000000000000000000003")
+	reader := &concurrencyReader{mockContractReader: newMockContractReader()}
+	reader.code[proxy] = []byte{0x60, 0x80}
+	reader.code[implementation] = []byte{0x60, 0x81}
+	reader.setStorage(proxy, EIP1967LogicSlot, implementation)
+	addresses := []string{proxy.Hex(), "0x1234", missing.Hex(), proxy.Hex()}
+	for i := 0; i < 6; i++ {
+		contract := common.BigToAddress(big.NewInt(int64(0x100 + i)))
+		reader.code[contract] = []byte{0x60, byte(i)}
+		addresses = append(addresses, contract.Hex())
+	}
+
+	results := fetcher.detectProxies(context.Background(), reader, "1", addresses)
+	assert.Len(t, results, len(addresses))
+	if assert.NoError(t, results[0].Err) {
+		assert.Equal(t, true, results[0].Response["isProxy"])
+		assert.Equal(t, implementation.Hex(), results[0].Response["target"])
+		assert.Equal(t, proxy.Hex(), results[0].Response["address"])
+	}
+	var invalid *InvalidInputError
+	assert.ErrorAs(t, results[1].Err, &invalid)
+	var notFound *ContractNotFoundError
+	assert.ErrorAs(t, results[2].Err, &notFound, "a failing address does not fail the batch")
+	assert.Equal(t, results[0], results[3], "duplicate addresses are resolved once")
+	for _, result := range results[4:] {
+		if assert.NoError(t, result.Err) {
+			assert.Equal(t, false, result.Response["isProxy"])
+		}
+	}
+	assert.LessOrEqual(t, reader.maxActive.Load(), int64(2), "at most BatchConcurrency addresses are resolved at once")
+	assert.Greater(t, reader.maxActive.Load(), int64(1), "addresses are resolved concurrently")
+}
+
+func TestGetProxyBatch(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	previous := abiFetcher
+	defer func() { abiFetcher = previous }()
+
+	node := newContractNode(t)
+	config := DefaultFetcherConfig()
+	config.BatchMaxSize = 3
+	abiFetcher = newTestFetcher(t, config, nil)
+
+	router := gin.New()
+	router.POST("/proxy/batch", getProxyBatch)
+
+	contract := "0x1000000000000000000000000000000000000001"
+	body := `{"chainId": "1", "rpcUrl": "` + node.URL + `", "addresses": ["` + contract + `", "0x1234"]}`
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/proxy/batch", strings.NewReader(body))
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var results []map[string]interface{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &results))
+	if assert.Len(t, results, 2) {
+		assert.Equal(t, map[string]interface{}{"isProxy": false, "address": contract}, results[0])
+		assert.Equal(t, float64(http.StatusBadRequest), results[1]["status"])
+		assert.Equal(t, "0x1234", results[1]["address"])
+	}
+
+	for _, invalid := range []string{
+		`[]`,
+		`{"chainId": "1", "rpcUrl": "` + node.URL + `"}`,
+		`{"chainId": "1", "rpcUrl": "` + node.URL + `", "addresses": ["a", "b", "c", "d"]}`,
+		`{"chainId": "one", "rpcUrl": "` + node.URL + `", "addresses": []}`,
+		`{"chainId": "1", "addresses": []}`,
+	} {
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest("POST", "/proxy/batch", strings.NewReader(invalid))
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, invalid)
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// BlockscoutAPI fetches verified ABIs from a Blockscout explorer through its
+// REST API, which returns the ABI as a JSON array rather than the string
+// Etherscan-compatible APIs return. Most instances do not require an API key.
+type BlockscoutAPI struct {
+	BaseURL string
+	// EnvKey optionally names the environment variable holding an API key.
+	EnvKey      string
+	ExplorerURL string
+	RPCURL      string
+	// Limiter paces requests to the API; nil means unlimited.
+	Limiter *ExplorerLimiter
+}
+
+func (b *BlockscoutAPI) ExplorerAddressURL(address string) string {
+	if b.ExplorerURL == "" {
+		return ""
+	}
+	return b.ExplorerURL + "/address/" + address
+}
+
+func (b *BlockscoutAPI) DefaultRPCURL() string {
+	return b.RPCURL
+}
+
+func (b *BlockscoutAPI) GetABIFromEtherscan(ctx context.Context, address string) (string, error) {
+	if b.Limiter != nil {
+		if err := b.Limiter.Wait(ctx); err != nil {
+			return "", err
+		}
+	}
+	requestURL := strings.TrimSuffix(b.BaseURL, "/") + "/api/v2/smart-contracts/" + url.PathEscape(address)
+	if b.EnvKey != "" {
+		if apiKey := os.Getenv(b.EnvKey); apiKey != "" {
+			requestURL += "?apikey=" + url.QueryEscape(apiKey)
+		}
+	}
+	return fetchBlockscoutABI(ctx, requestURL)
+}
+
+// fetchBlockscoutABI queries a Blockscout smart-contract URL and returns the
+// ABI re-encoded as a JSON string. Errors follow the same kinds as queryExplorer.
+func fetchBlockscoutABI(ctx context.Context, requestURL string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, requestURL, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := explorerHTTPClient.Do(req)
+	if err != nil {
+		return "", &NetworkError{err: err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return "", &EtherscanAPIError{message: "Contract source code not verified", statusCode: resp.StatusCode, unverified: true}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", &EtherscanAPIError{message: resp.Status, statusCode: resp.StatusCode}
+	}
+
+	var result struct {
+		ABI json.RawMessage `json:"abi"`
+	}
+	if err := json.NewDecoder(limitBody(resp.Body)).Decode(&result); err != nil {
+		return "", fmt.Errorf("failed to decode Blockscout response: %v", err)
+	}
+	if len(result.ABI) == 0 || string(result.ABI) == "null" {
+		return "", &EtherscanAPIError{message: "Contract source code not verified", statusCode: resp.StatusCode, unverified: true}
+	}
+
+	return string(result.ABI), nil
+}
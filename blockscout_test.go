@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBlockscoutAPI(t *testing.T) {
+	verified := "0x1000000000000000000000000000000000000001"
+	unverified := "0x2000000000000000000000000000000000000002"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v2/smart-contracts/" + verified:
+			fmt.Fprint(w, `{"name":"Token","abi":[{"type":"function","name":"foo","inputs":[],"outputs":[]}]}`)
+		case "/api/v2/smart-contracts/" + unverified:
+			fmt.Fprint(w, `{"abi":null}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	api := &BlockscoutAPI{BaseURL: server.URL + "/", ExplorerURL: "https://gnosis.blockscout.com"}
+
+	abi, err := api.GetABIFromEtherscan(context.Background(), verified)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `[{"type":"function","name":"foo","inputs":[],"outputs":[]}]`, abi)
+
+	for _, address := range []string{unverified, "0x3000000000000000000000000000000000000003"} {
+		_, err = api.GetABIFromEtherscan(context.Background(), address)
+		var apiErr *EtherscanAPIError
+		assert.True(t, errors.As(err, &apiErr), address)
+	}
+
+	assert.Equal(t, "https://gnosis.blockscout.com/address/"+verified, api.ExplorerAddressURL(verified))
+}
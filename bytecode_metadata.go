@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+const defaultIPFSGatewayURL = "https://ipfs.io"
+
+// maxCBORDepth bounds the nesting of the CBOR metadata trailer, which the
+// compiler only ever emits as a flat map.
+const maxCBORDepth = 4
+
+// bytecodeMetadata is the CBOR-encoded map the Solidity compiler appends to a
+// contract's runtime bytecode, followed by its length as a big-endian uint16.
+// It holds the hash of the contract's metadata.json, with which the
+// metadata, including the ABI, can be retrieved from IPFS.
+type bytecodeMetadata struct {
+	// IPFS is the multihash of metadata.json, absent for contracts compiled
+	// with the Swarm hash (bzzr0/bzzr1) or without metadata hash.
+	IPFS []byte
+	// Solc is the compiler version, absent for Vyper and old compilers.
+	Solc string
+}
+
+// parseBytecodeMetadata decodes the metadata trailer of runtime bytecode. It
+// reports false for bytecode without a trailer, such as Vyper contracts,
+// minimal proxies or contracts compiled with metadata disabled.
+func parseBytecodeMetadata(code []byte) (bytecodeMetadata, bool) {
+	if len(code) < 2 {
+		return bytecodeMetadata{}, false
+	}
+	length := int(code[len(code)-2])<<8 | int(code[len(code)-1])
+	if length == 0 || length+2 > len(code) {
+		return bytecodeMetadata{}, false
+	}
+	reader := &cborReader{data: code[len(code)-2-length : len(code)-2]}
+	value, err := reader.readItem(0)
+	if err != nil || reader.pos != len(reader.data) {
+		return bytecodeMetadata{}, false
+	}
+	entries, ok := value.(map[string]interface{})
+	if !ok {
+		return bytecodeMetadata{}, false
+	}
+	var metadata bytecodeMetadata
+	if hash, ok := entries["ipfs"].([]byte); ok {
+		metadata.IPFS = hash
+	}
+	// Releases encode the version as three bytes, prereleases as a string.
+	switch solc := entries["solc"].(type) {
+	case []byte:
+		if len(solc) == 3 {
+			metadata.Solc = fmt.Sprintf("%d.%d.%d", solc[0], solc[1], solc[2])
+		}
+	case string:
+		metadata.Solc = solc
+	}
+	return metadata, true
+}
+
+// CID returns the IPFS CIDv0 of metadata.json, or false when the trailer
+// holds no sha2-256 IPFS hash.
+func (m bytecodeMetadata) CID() (string, bool) {
+	// A sha2-256 multihash: function code 0x12, digest length 32.
+	if len(m.IPFS) != 34 || m.IPFS[0] != 0x12 || m.IPFS[1] != 0x20 {
+		return "", false
+	}
+	return base58Encode(m.IPFS), true
+}
+
+// getIPFSABI fetches the ABI of the contract with runtime bytecode code from
+// the metadata.json its metadata trailer points to, through the IPFS gateway
+// at gatewayURL. The metadata is only available if someone pinned it, usually
+// the deployer or Sourcify.
+func getIPFSABI(ctx context.Context, gatewayURL string, code []byte) (string, ContractMetadata, error) {
+	metadata, ok := parseBytecodeMetadata(code)
+	if !ok {
+		return "", ContractMetadata{}, errors.New("bytecode has no metadata trailer")
+	}
+	cid, ok := metadata.CID()
+	if !ok {
+		return "", ContractMetadata{}, errors.New("bytecode metadata has no IPFS hash")
+	}
+	abi, contract, err := fetchMetadataABI(ctx, ipfsHTTPClient, strings.TrimSuffix(gatewayURL, "/")+"/ipfs/"+cid)
+	if err != nil {
+		return "", ContractMetadata{}, err
+	}
+	contract.VerificationStatus = "metadata_match"
+	return abi, contract, nil
+}
+
+// cborReader decodes the subset of CBOR (RFC 8949) used by the metadata
+// trailer: integers, byte and text strings, arrays, maps with text keys and
+// the simple values false, true and null. Indefinite lengths, tags and floats
+// are rejected.
+type cborReader struct {
+	data []byte
+	pos  int
+}
+
+var errInvalidCBOR = errors.New("invalid CBOR")
+
+// readHead reads the initial byte of an item and its argument.
+func (r *cborReader) readHead() (major byte, argument uint64, err error) {
+	if r.pos >= len(r.data) {
+		return 0, 0, errInvalidCBOR
+	}
+	initial := r.data[r.pos]
+	r.pos++
+	major, info := initial>>5, initial&0x1f
+	switch {
+	case info < 24:
+		return major, uint64(info), nil
+	case info <= 27:
+		size := 1 << (info - 24)
+		if r.pos+size > len(r.data) {
+			return 0, 0, errInvalidCBOR
+		}
+		for _, b := range r.data[r.pos : r.pos+size] {
+			argument = argument<<8 | uint64(b)
+		}
+		r.pos += size
+		return major, argument, nil
+	}
+	return 0, 0, errInvalidCBOR
+}
+
+// readBytes reads the payload of a byte or text string of length n.
+func (r *cborReader) readBytes(n uint64) ([]byte, error) {
+	if n > uint64(len(r.data)-r.pos) {
+		return nil, errInvalidCBOR
+	}
+	payload := r.data[r.pos : r.pos+int(n)]
+	r.pos += int(n)
+	return payload, nil
+}
+
+func (r *cborReader) readItem(depth int) (interface{}, error) {
+	if depth > maxCBORDepth {
+		return nil, errInvalidCBOR
+	}
+	major, argument, err := r.readHead()
+	if err != nil {
+		return nil, err
+	}
+	switch major {
+	case 0:
+		return argument, nil
+	case 1:
+		return -1 - int64(argument), nil
+	case 2:
+		return r.readBytes(argument)
+	case 3:
+		text, err := r.readBytes(argument)
+		return string(text), err
+	case 4:
+		if argument > uint64(len(r.data)) {
+			return nil, errInvalidCBOR
+		}
+		items := make([]interface{}, 0, argument)
+		for i := uint64(0); i < argument; i++ {
+			item, err := r.readItem(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	case 5:
+		if argument > uint64(len(r.data)) {
+			return nil, errInvalidCBOR
+		}
+		entries := make(map[string]interface{}, argument)
+		for i := uint64(0); i < argument; i++ {
+			key, err := r.readItem(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			name, ok := key.(string)
+			if !ok {
+				return nil, errInvalidCBOR
+			}
+			value, err := r.readItem(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			entries[name] = value
+		}
+		return entries, nil
+	case 7:
+		switch argument {
+		case 20:
+			return false, nil
+		case 21:
+			return true, nil
+		case 22:
+			return nil, nil
+		}
+	}
+	return nil, errInvalidCBOR
+}
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// base58Encode encodes data with the Bitcoin alphabet IPFS uses for CIDv0.
+func base58Encode(data []byte) string {
+	value := new(big.Int).SetBytes(data)
+	base := big.NewInt(58)
+	remainder := new(big.Int)
+	var encoded []byte
+	for value.Sign() > 0 {
+		value.DivMod(value, base, remainder)
+		encoded = append(encoded, base58Alphabet[remainder.Int64()])
+	}
+	// Every leading zero byte is encoded as the first digit.
+	for _, b := range data {
+		if b != 0 {
+			break
+		}
+		encoded = append(encoded, base58Alphabet[0])
+	}
+	for i, j := 0, len(encoded)-1; i < j; i, j = i+1, j-1 {
+		encoded[i], encoded[j] = encoded[j], encoded[i]
+	}
+	return string(encoded)
+}
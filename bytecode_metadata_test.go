@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+// solidityTrailer returns the metadata trailer solc 0.8.19 appends for a
+// metadata.json with the given sha2-256 digest.
+func solidityTrailer(digest []byte) []byte {
+	trailer := common.FromHex("0xa2646970667358221220")
+	trailer = append(trailer, digest...)
+	trailer = append(trailer, common.FromHex("0x64736f6c63430008130033")...)
+	return trailer
+}
+
+func TestParseBytecodeMetadata(t *testing.T) {
+	digest := sha256.Sum256([]byte("metadata"))
+	code := append(common.FromHex("0x6080604052348015600f57600080fd5b50fe"), solidityTrailer(digest[:])...)
+	metadata, ok := parseBytecodeMetadata(code)
+	if assert.True(t, ok) {
+		assert.Equal(t, "0.8.19", metadata.Solc)
+		cid, ok := metadata.CID()
+		assert.True(t, ok)
+		assert.Equal(t, "QmT13QYnUQxx28uA7BqnPdPE1dQpV3zp9Jy9z21dv3DqWn", cid)
+	}
+
+	zero, _ := parseBytecodeMetadata(solidityTrailer(make([]byte, 32)))
+	cid, _ := zero.CID()
+	assert.Equal(t, "QmNLei78zWmzUdbeRB3CiUfAizWUrbeeZh5K1rhAQKCh51", cid)
+
+	// {"bzzr0": <32 bytes>, "solc": "0.5.0-nightly"}, as emitted by older
+	// compilers, has no IPFS hash.
+	swarm := append(common.FromHex("0xa265627a7a723058"+"20"), make([]byte, 32)...)
+	swarm = append(swarm, common.FromHex("0x64736f6c63")...)
+	swarm = append(swarm, append([]byte{0x6d}, "0.5.0-nightly"...)...)
+	swarm = append(swarm, 0x00, byte(len(swarm)))
+	metadata, ok = parseBytecodeMetadata(swarm)
+	if assert.True(t, ok) {
+		assert.Equal(t, "0.5.0-nightly", metadata.Solc)
+		_, ok := metadata.CID()
+		assert.False(t, ok)
+	}
+
+	for name, code := range map[string][]byte{
+		"empty":           nil,
+		"single byte":     {0x00},
+		"minimal proxy":   common.FromHex("0x363d3d373d3d3d363d73bebebebebebebebebebebebebebebebebebebebe5af43d82803e903d91602b57fd5bf3"),
+		"zero length":     common.FromHex("0x60800000"),
+		"not a map":       common.FromHex("0x6080" + "6161" + "0002"),
+		"truncated":       append(solidityTrailer(digest[:])[:40], 0x00, 0x33),
+		"trailing bytes":  common.FromHex("0xa0" + "00" + "0002"),
+		"non-string key":  common.FromHex("0xa10101" + "0003"),
+		"indefinite map":  common.FromHex("0xbf" + "ff" + "0002"),
+		"deeply nested":   common.FromHex("0x8181818181818100" + "0008"),
+		"oversized array": common.FromHex("0x9bffffffffffffffff" + "0009"),
+	} {
+		_, ok := parseBytecodeMetadata(code)
+		assert.False(t, ok, name)
+	}
+}
+
+func TestBase58Encode(t *testing.T) {
+	assert.Equal(t, "", base58Encode(nil))
+	assert.Equal(t, "112", base58Encode([]byte{0, 0, 1}))
+	assert.Equal(t, "StV1DL6CwTryKyV", base58Encode([]byte("hello world")))
+}
+
+func TestGetABIFromIPFS(t *testing.T) {
+	digest := sha256.Sum256([]byte("metadata"))
+	code := append(common.FromHex("0x6080604052"), solidityTrailer(digest[:])...)
+	var requested string
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = r.URL.Path
+		w.Write([]byte(`{
+			"compiler": {"version": "0.8.19+commit.7dd6d404"},
+			"settings": {"compilationTarget": {"contracts/Vault.sol": "Vault"}},
+			"output": {"abi": [{"type":"function","name":"deposit","inputs":[],"outputs":[],"stateMutability":"payable"}]}
+		}`))
+	}))
+	defer gateway.Close()
+	sourcify := httptest.NewServer(http.NotFoundHandler())
+	defer sourcify.Close()
+
+	config := DefaultFetcherConfig()
+	config.SourcifyURL = sourcify.URL
+	config.IPFSGatewayURL = gateway.URL + "/"
+	fetcher := NewABIFetcher(NewABIStorage(time.Hour, 0), map[int]ChainAPI{1: staticChainAPI{}}, config)
+	address := "0x1000000000000000000000000000000000000001"
+
+	fetched, err := fetcher.getABI(context.Background(), "1", address, "rpc.example", code, false)
+	assert.NoError(t, err)
+	assert.Equal(t, "/ipfs/QmT13QYnUQxx28uA7BqnPdPE1dQpV3zp9Jy9z21dv3DqWn", requested)
+	assert.Equal(t, SourceIPFS, fetched.Source)
+	assert.Contains(t, fetched.ABI, "deposit")
+	assert.False(t, fetched.IsDecompiled)
+	assert.Equal(t, ContractMetadata{ContractName: "Vault", CompilerVersion: "0.8.19+commit.7dd6d404", VerificationStatus: "metadata_match"}, fetched.Metadata)
+
+	requested = ""
+	_, err = fetcher.getABI(context.Background(), "1", address, "rpc.example", common.FromHex("0x60806040"), false)
+	assert.IsType(t, &VerifiedABINotFoundError{}, err, "bytecode without a trailer skips IPFS")
+	assert.Empty(t, requested)
+
+	config.IPFSGatewayURL = ""
+	fetcher = NewABIFetcher(NewABIStorage(time.Hour, 0), map[int]ChainAPI{1: staticChainAPI{}}, config)
+	_, err = fetcher.getABI(context.Background(), "1", address, "rpc.example", code, false)
+	assert.IsType(t, &VerifiedABINotFoundError{}, err)
+	assert.Empty(t, requested, "an empty gateway URL disables the fallback")
+}
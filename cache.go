@@ -0,0 +1,93 @@
+package main
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// ttlCache is a concurrency-safe map whose entries expire after a fixed TTL.
+// As every entry lives for the same TTL, entries are kept in the order they
+// expire: each Set evicts the expired ones and, while more than maxEntries
+// are held, those closest to expiring, so keys never read again do not pile
+// up.
+type ttlCache[V any] struct {
+	mu         sync.Mutex
+	ttl        time.Duration
+	maxEntries int
+	entries    map[string]*list.Element
+	// order holds the *ttlEntry values, soonest to expire first.
+	order *list.List
+}
+
+type ttlEntry[V any] struct {
+	key       string
+	value     V
+	expiresAt time.Time
+}
+
+// newTTLCache creates a cache whose entries expire ttl after they were set
+// and which holds at most maxEntries entries, or any number when it is zero.
+func newTTLCache[V any](ttl time.Duration, maxEntries int) *ttlCache[V] {
+	return &ttlCache[V]{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		entries:    make(map[string]*list.Element),
+		order:      list.New(),
+	}
+}
+
+func (c *ttlCache[V]) Set(key string, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	if element, ok := c.entries[key]; ok {
+		c.remove(element)
+	}
+	c.entries[key] = c.order.PushBack(&ttlEntry[V]{key: key, value: value, expiresAt: now.Add(c.ttl)})
+
+	for element := c.order.Front(); element != nil && now.After(element.Value.(*ttlEntry[V]).expiresAt); element = c.order.Front() {
+		c.remove(element)
+	}
+	for c.maxEntries > 0 && c.order.Len() > c.maxEntries {
+		c.remove(c.order.Front())
+	}
+}
+
+func (c *ttlCache[V]) Get(key string) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	element, ok := c.entries[key]
+	if ok && time.Now().After(element.Value.(*ttlEntry[V]).expiresAt) {
+		c.remove(element)
+		ok = false
+	}
+	if !ok {
+		var zero V
+		return zero, false
+	}
+	return element.Value.(*ttlEntry[V]).value, true
+}
+
+func (c *ttlCache[V]) Delete(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	element, ok := c.entries[key]
+	if ok {
+		c.remove(element)
+	}
+	return ok
+}
+
+// Len returns the number of entries held, including expired ones that have
+// not been evicted yet.
+func (c *ttlCache[V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+func (c *ttlCache[V]) remove(element *list.Element) {
+	c.order.Remove(element)
+	delete(c.entries, element.Value.(*ttlEntry[V]).key)
+}
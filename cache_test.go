@@ -0,0 +1,51 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTTLCache(t *testing.T) {
+	cache := newTTLCache[string](50*time.Millisecond, 0)
+	cache.Set("key", "value")
+
+	value, ok := cache.Get("key")
+	assert.True(t, ok)
+	assert.Equal(t, "value", value)
+
+	time.Sleep(60 * time.Millisecond)
+	_, ok = cache.Get("key")
+	assert.False(t, ok, "entry should expire after the TTL")
+}
+
+func TestTTLCacheEvictsUnreadEntries(t *testing.T) {
+	cache := newTTLCache[string](50*time.Millisecond, 0)
+	cache.Set("a", "value")
+	cache.Set("b", "value")
+
+	time.Sleep(60 * time.Millisecond)
+	cache.Set("c", "value")
+	assert.Equal(t, 1, cache.Len(), "expired keys are evicted without being read again")
+
+	cache.Set("a", "value")
+	cache.Set("c", "renewed")
+	cache.Set("a", "renewed")
+	assert.Equal(t, 2, cache.Len(), "setting a key again replaces it")
+}
+
+func TestTTLCacheMaxEntries(t *testing.T) {
+	cache := newTTLCache[string](time.Hour, 2)
+	cache.Set("a", "value")
+	cache.Set("b", "value")
+	cache.Set("a", "renewed")
+	cache.Set("c", "value")
+
+	assert.Equal(t, 2, cache.Len())
+	_, ok := cache.Get("b")
+	assert.False(t, ok, "the entry closest to expiring is evicted")
+	value, ok := cache.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, "renewed", value)
+}
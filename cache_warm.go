@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// WarmJob is a background job fetching the ABIs of a list of contracts into
+// the cache, e.g. the most requested ones after a deploy.
+type WarmJob struct {
+	ID        string
+	Total     int
+	StartedAt time.Time
+
+	warmed  atomic.Int64
+	skipped atomic.Int64
+	failed  atomic.Int64
+	done    atomic.Bool
+}
+
+// WarmProgress is the progress of a WarmJob.
+type WarmProgress struct {
+	JobID     string    `json:"jobId"`
+	Total     int       `json:"total"`
+	Warmed    int64     `json:"warmed"`
+	Skipped   int64     `json:"skipped"`
+	Failed    int64     `json:"failed"`
+	Done      bool      `json:"done"`
+	StartedAt time.Time `json:"startedAt"`
+}
+
+// Progress reports how many of the job's contracts were fetched, skipped as
+// already cached or failed so far.
+func (j *WarmJob) Progress() WarmProgress {
+	return WarmProgress{
+		JobID:     j.ID,
+		Total:     j.Total,
+		Warmed:    j.warmed.Load(),
+		Skipped:   j.skipped.Load(),
+		Failed:    j.failed.Load(),
+		Done:      j.done.Load(),
+		StartedAt: j.StartedAt,
+	}
+}
+
+// WarmCache starts a job fetching the ABIs of requests in the background and
+// returns it right away. Contracts whose ABI is already cached are skipped,
+// and at most config.WarmConcurrency ABIs are fetched at once across all
+// jobs. The job stops early when the fetcher's jobs are stopped.
+func (af *ABIFetcher) WarmCache(requests []BatchRequest) *WarmJob {
+	unique := make(map[BatchRequest]bool)
+	var order []BatchRequest
+	for _, request := range requests {
+		if !unique[request] {
+			unique[request] = true
+			order = append(order, request)
+		}
+	}
+	job := &WarmJob{ID: newRequestID(), Total: len(order), StartedAt: time.Now()}
+	af.runningWarmJobs.Store(job.ID, job)
+	ctx := af.jobsCtx
+
+	go func() {
+		var wg sync.WaitGroup
+	entries:
+		for _, request := range order {
+			if ctx.Err() != nil {
+				break
+			}
+			select {
+			case af.warmSlots <- struct{}{}:
+			case <-ctx.Done():
+				break entries
+			}
+			wg.Add(1)
+			go func(request BatchRequest) {
+				defer wg.Done()
+				defer func() { <-af.warmSlots }()
+				af.warmEntry(ctx, job, request)
+			}(request)
+		}
+		wg.Wait()
+		job.done.Store(true)
+		// The TTL of a job starts once it finished, so that its outcome can
+		// be queried however long it ran.
+		af.warmJobs.Set(job.ID, job)
+		af.runningWarmJobs.Delete(job.ID)
+		af.logger.Info("cache warming finished",
+			"jobId", job.ID,
+			"warmed", job.warmed.Load(),
+			"skipped", job.skipped.Load(),
+			"failed", job.failed.Load(),
+			"cancelled", ctx.Err() != nil,
+			"duration", time.Since(job.StartedAt),
+		)
+	}()
+	return job
+}
+
+func (af *ABIFetcher) warmEntry(ctx context.Context, job *WarmJob, request BatchRequest) {
+	rpcURL := request.RPCURL
+	if rpcURL == "" {
+		rpcURL = af.defaultRPCURL(request.ChainID)
+	}
+	address, err := af.resolveAddress(ctx, request.ChainID, request.Address, rpcURL)
+	if err == nil {
+		if _, ok := af.cacheGet(ctx, abiCacheKey(request.ChainID, address, nil)); ok {
+			job.skipped.Add(1)
+			return
+		}
+		_, _, err = af.lookupABI(ctx, request.ChainID, address, rpcURL, FetchOptions{})
+	}
+	if err != nil {
+		job.failed.Add(1)
+		af.logger.Warn("cache warming failed",
+			"jobId", job.ID,
+			"chainId", request.ChainID,
+			"address", request.Address,
+			"error", err.Error(),
+		)
+		return
+	}
+	job.warmed.Add(1)
+}
+
+// WarmJob returns the cache warming job with id, if it is still known.
+func (af *ABIFetcher) WarmJob(id string) (*WarmJob, bool) {
+	if job, ok := af.runningWarmJobs.Load(id); ok {
+		return job.(*WarmJob), true
+	}
+	return af.warmJobs.Get(id)
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWarmCache(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	previous := abiFetcher
+	defer func() { abiFetcher = previous }()
+
+	node := newContractNode(t)
+	config := DefaultFetcherConfig()
+	config.DisableDecompilation = true
+	config.WarmMaxSize = 4
+
+	verified := "0x1000000000000000000000000000000000000001"
+	cached := "0x2000000000000000000000000000000000000002"
+	unverified := "0x3000000000000000000000000000000000000003"
+	abi := `[{"type":"function","name":"transfer","inputs":[],"outputs":[],"stateMutability":"nonpayable"}]`
+	abiFetcher = newTestFetcher(t, config, map[int]ChainAPI{1: staticChainAPI{verified: abi}})
+	abiFetcher.storage.Set("1-"+cached, StorageItem{ABI: "[]"})
+
+	router := gin.New()
+	router.POST("/cache/warm", warmCache)
+	router.GET("/cache/warm/:jobId", getWarmJob)
+
+	body := fmt.Sprintf(`[
+		{"chainId": "1", "address": %[1]q, "rpcUrl": %[2]q},
+		{"chainId": "1", "address": %[1]q, "rpcUrl": %[2]q},
+		{"chainId": "1", "address": %[3]q, "rpcUrl": %[2]q},
+		{"chainId": "1", "address": %[4]q, "rpcUrl": %[2]q}
+	]`, verified, node.URL, cached, unverified)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/cache/warm", strings.NewReader(body))
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	var accepted struct {
+		JobID string `json:"jobId"`
+		Total int    `json:"total"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &accepted))
+	assert.Equal(t, 3, accepted.Total, "duplicate entries are warmed once")
+
+	var progress WarmProgress
+	assert.Eventually(t, func() bool {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/cache/warm/"+accepted.JobID, nil)
+		router.ServeHTTP(w, req)
+		return json.Unmarshal(w.Body.Bytes(), &progress) == nil && progress.Done
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, int64(1), progress.Warmed)
+	assert.Equal(t, int64(1), progress.Skipped)
+	assert.Equal(t, int64(1), progress.Failed)
+
+	item, ok := abiFetcher.storage.Get("1-" + verified)
+	assert.True(t, ok, "the warmed ABI is cached")
+	canonical, _ := normalizeABI(abi)
+	assert.Equal(t, canonical, item.ABI)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/cache/warm/unknown", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/cache/warm", strings.NewReader(`[{},{},{},{},{}]`))
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), CodeBatchTooLarge)
+}
+
+func TestWarmJobLifetime(t *testing.T) {
+	config := DefaultFetcherConfig()
+	config.WarmConcurrency = 1
+	config.WarmJobTTL = time.Millisecond
+	fetcher := NewABIFetcher(NewABIStorage(time.Hour, 0), map[int]ChainAPI{}, config)
+	ctx, cancel := context.WithCancel(context.Background())
+	fetcher.StopJobsOn(ctx)
+
+	// Another job holds the only slot, so this one cannot make progress.
+	fetcher.warmSlots <- struct{}{}
+	defer func() { <-fetcher.warmSlots }()
+	job := fetcher.WarmCache([]BatchRequest{{ChainID: "1", Address: "0x1000000000000000000000000000000000000001", RPCURL: "rpc.example"}})
+	time.Sleep(10 * config.WarmJobTTL)
+	_, ok := fetcher.WarmJob(job.ID)
+	assert.True(t, ok, "running jobs do not expire")
+
+	cancel()
+	assert.Eventually(t, func() bool { return job.Progress().Done }, time.Second, time.Millisecond, "jobs stop with the server")
+	progress := job.Progress()
+	assert.Zero(t, progress.Warmed+progress.Skipped+progress.Failed)
+	assert.Eventually(t, func() bool {
+		_, ok := fetcher.WarmJob(job.ID)
+		return !ok
+	}, time.Second, time.Millisecond, "finished jobs expire after the TTL")
+}
+
+func TestWarmCacheRequiresAdmin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("ADMIN_TOKEN", "admin")
+	router := newRouter([]string{"key"})
+
+	for token, expected := range map[string]int{
+		"key":   http.StatusUnauthorized,
+		"admin": http.StatusBadRequest,
+	} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/cache/warm", strings.NewReader(`{}`))
+		req.Header.Set("Authorization", "Bearer "+token)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, expected, w.Code, token)
+	}
+}
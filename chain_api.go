@@ -1,50 +1,298 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math/rand/v2"
 	"net/http"
 	"os"
+	"strings"
+	"sync/atomic"
+	"time"
 )
 
 type ChainAPI interface {
-	GetABIFromEtherscan(address string) (string, error)
+	GetABIFromEtherscan(ctx context.Context, address string) (string, error)
+}
+
+// ExplorerLinker is implemented by chain APIs that know the human-facing
+// block explorer of their chain.
+type ExplorerLinker interface {
+	ExplorerAddressURL(address string) string
+}
+
+// SourceCodeProvider is implemented by chain APIs that can report a
+// contract's verified source metadata, including the explorer's own verdict
+// on whether it is a proxy.
+type SourceCodeProvider interface {
+	GetSourceCode(ctx context.Context, address string) (SourceCode, error)
+}
+
+// ContractCreationProvider is implemented by chain APIs that know who deployed
+// a contract and in which transaction.
+type ContractCreationProvider interface {
+	GetContractCreation(ctx context.Context, address string) (ContractCreation, error)
+}
+
+// ContractCreation is who deployed a contract and in which transaction. Both
+// are empty when the explorer has no record of the contract's creation.
+type ContractCreation struct {
+	Creator string `json:"creator,omitempty"`
+	TxHash  string `json:"txHash,omitempty"`
+}
+
+// SourceCode is the part of an Etherscan getsourcecode result the service uses.
+type SourceCode struct {
+	ABI             string
+	ContractName    string
+	CompilerVersion string
+	// Proxy is set when the explorer flagged the contract as a proxy, with
+	// Implementation holding the implementation address it reported.
+	Proxy          bool
+	Implementation string
+}
+
+// DefaultRPCProvider is implemented by chain APIs that know an RPC node to
+// use for their chain when a request does not name one.
+type DefaultRPCProvider interface {
+	DefaultRPCURL() string
 }
 
 type GenericEtherscanAPI struct {
-	BaseURL string
-	EnvKey  string
+	BaseURL     string
+	EnvKey      string
+	ExplorerURL string
+	RPCURL      string
+	// Limiter paces requests to the API; nil means unlimited.
+	Limiter *ExplorerLimiter
+	// Retry controls how rate-limited requests are retried.
+	Retry RetryPolicy
+
+	// nextKey rotates requests across the API keys when EnvKey holds a
+	// comma-separated list of them.
+	nextKey atomic.Uint64
+}
+
+// RetryPolicy retries requests that failed transiently, such as those the
+// explorer rejected for exceeding its rate limit, waiting BaseDelay doubled
+// on every attempt plus up to as much again in random jitter.
+type RetryPolicy struct {
+	MaxRetries int
+	BaseDelay  time.Duration
 }
 
-func (e *GenericEtherscanAPI) GetABIFromEtherscan(address string) (string, error) {
-	apiKey := os.Getenv(e.EnvKey)
-	if apiKey == "" {
-		return "", fmt.Errorf("API key not set for chain: %s", e.EnvKey)
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	delay := p.BaseDelay << attempt
+	if delay <= 0 {
+		return 0
 	}
-	url := fmt.Sprintf("%s?module=contract&action=getabi&address=%s&apikey=%s", e.BaseURL, address, apiKey)
-	return fetchABI(url)
+	return delay + rand.N(delay)
 }
 
-func fetchABI(url string) (string, error) {
-	resp, err := http.Get(url)
+func (e *GenericEtherscanAPI) ExplorerAddressURL(address string) string {
+	if e.ExplorerURL == "" {
+		return ""
+	}
+	return e.ExplorerURL + "/address/" + address
+}
+
+func (e *GenericEtherscanAPI) DefaultRPCURL() string {
+	return e.RPCURL
+}
+
+func (e *GenericEtherscanAPI) GetABIFromEtherscan(ctx context.Context, address string) (string, error) {
+	var abi string
+	err := e.fetchWithKeys(ctx, func(apiKey string) string {
+		return fmt.Sprintf("%s?module=contract&action=getabi&address=%s&apikey=%s", e.BaseURL, address, apiKey)
+	}, &abi)
+	return abi, err
+}
+
+func (e *GenericEtherscanAPI) GetSourceCode(ctx context.Context, address string) (SourceCode, error) {
+	return e.getSourceCode(ctx, func(apiKey string) string {
+		return fmt.Sprintf("%s?module=contract&action=getsourcecode&address=%s&apikey=%s", e.BaseURL, address, apiKey)
+	})
+}
+
+// getSourceCode fetches the getsourcecode result from the URL requestURL
+// builds for an API key. Unverified contracts are reported as an
+// *EtherscanAPIError like they are by getabi.
+func (e *GenericEtherscanAPI) getSourceCode(ctx context.Context, requestURL func(apiKey string) string) (SourceCode, error) {
+	var entries []struct {
+		ABI             string `json:"ABI"`
+		ContractName    string `json:"ContractName"`
+		CompilerVersion string `json:"CompilerVersion"`
+		Proxy           string `json:"Proxy"`
+		Implementation  string `json:"Implementation"`
+	}
+	if err := e.fetchWithKeys(ctx, requestURL, &entries); err != nil {
+		return SourceCode{}, err
+	}
+	if len(entries) == 0 {
+		return SourceCode{}, &EtherscanAPIError{message: "empty getsourcecode result", statusCode: http.StatusOK}
+	}
+	entry := entries[0]
+	if strings.Contains(strings.ToLower(entry.ABI), "not verified") {
+		return SourceCode{}, &EtherscanAPIError{message: entry.ABI, statusCode: http.StatusOK, unverified: true}
+	}
+	return SourceCode{
+		ABI:             entry.ABI,
+		ContractName:    entry.ContractName,
+		CompilerVersion: entry.CompilerVersion,
+		Proxy:           entry.Proxy == "1",
+		Implementation:  entry.Implementation,
+	}, nil
+}
+
+func (e *GenericEtherscanAPI) GetContractCreation(ctx context.Context, address string) (ContractCreation, error) {
+	return e.getContractCreation(ctx, func(apiKey string) string {
+		return fmt.Sprintf("%s?module=contract&action=getcontractcreation&contractaddresses=%s&apikey=%s", e.BaseURL, address, apiKey)
+	})
+}
+
+// getContractCreation fetches the getcontractcreation result from the URL
+// requestURL builds for an API key. Contracts the explorer has no creation
+// record of yield an empty ContractCreation rather than an error.
+func (e *GenericEtherscanAPI) getContractCreation(ctx context.Context, requestURL func(apiKey string) string) (ContractCreation, error) {
+	var entries []struct {
+		ContractCreator string `json:"contractCreator"`
+		TxHash          string `json:"txHash"`
+	}
+	err := e.fetchWithKeys(ctx, requestURL, &entries)
+	var apiErr *EtherscanAPIError
+	if errors.As(err, &apiErr) && strings.Contains(strings.ToLower(apiErr.message), "no data found") {
+		return ContractCreation{}, nil
+	}
+	if err != nil || len(entries) == 0 {
+		return ContractCreation{}, err
+	}
+	return ContractCreation{Creator: entries[0].ContractCreator, TxHash: entries[0].TxHash}, nil
+}
+
+// fetchWithKeys decodes the result of the URL requestURL builds for an API
+// key into result, using the keys in turn, one per request. A key rejected as
+// rate limited or invalid is skipped for the next one, and only once every
+// key was rate limited is the request retried after a backoff.
+func (e *GenericEtherscanAPI) fetchWithKeys(ctx context.Context, requestURL func(apiKey string) string, result interface{}) error {
+	apiKeys := splitAPIKeys(os.Getenv(e.EnvKey))
+	if len(apiKeys) == 0 {
+		return &MissingAPIKeyError{envKey: e.EnvKey}
+	}
+	first := e.nextKey.Add(1) - 1
+	for tried, retry := 0, 0; ; {
+		if e.Limiter != nil {
+			if err := e.Limiter.Wait(ctx); err != nil {
+				return err
+			}
+		}
+		apiKey := apiKeys[(first+uint64(tried))%uint64(len(apiKeys))]
+		err := queryExplorer(ctx, requestURL(apiKey), result)
+		var apiErr *EtherscanAPIError
+		if !errors.As(err, &apiErr) || (!apiErr.rateLimited && !apiErr.invalidKey) {
+			return err
+		}
+		if tried++; tried%len(apiKeys) != 0 {
+			continue
+		}
+		if !apiErr.rateLimited || retry >= e.Retry.MaxRetries {
+			return err
+		}
+		select {
+		case <-time.After(e.Retry.backoff(retry)):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		retry++
+	}
+}
+
+// defaultEtherscanV2URL is Etherscan's unified API serving every chain it
+// supports, selected by the chainid parameter.
+const defaultEtherscanV2URL = "https://api.etherscan.io/v2/api"
+
+// EtherscanV2API fetches ABIs from Etherscan's unified V2 API, which replaces
+// the per-chain domains and accepts one API key for all chains. Chains
+// sharing a key should share the Limiter, as the key's rate limit is global.
+type EtherscanV2API struct {
+	GenericEtherscanAPI
+	ChainID int
+}
+
+func (e *EtherscanV2API) GetABIFromEtherscan(ctx context.Context, address string) (string, error) {
+	var abi string
+	err := e.fetchWithKeys(ctx, func(apiKey string) string {
+		return fmt.Sprintf("%s?chainid=%d&module=contract&action=getabi&address=%s&apikey=%s", e.BaseURL, e.ChainID, address, apiKey)
+	}, &abi)
+	return abi, err
+}
+
+func (e *EtherscanV2API) GetSourceCode(ctx context.Context, address string) (SourceCode, error) {
+	return e.getSourceCode(ctx, func(apiKey string) string {
+		return fmt.Sprintf("%s?chainid=%d&module=contract&action=getsourcecode&address=%s&apikey=%s", e.BaseURL, e.ChainID, address, apiKey)
+	})
+}
+
+func (e *EtherscanV2API) GetContractCreation(ctx context.Context, address string) (ContractCreation, error) {
+	return e.getContractCreation(ctx, func(apiKey string) string {
+		return fmt.Sprintf("%s?chainid=%d&module=contract&action=getcontractcreation&contractaddresses=%s&apikey=%s", e.BaseURL, e.ChainID, address, apiKey)
+	})
+}
+
+// splitAPIKeys parses a comma-separated list of API keys.
+func splitAPIKeys(value string) []string {
+	var keys []string
+	for _, key := range strings.Split(value, ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// queryExplorer queries an Etherscan-compatible API URL and decodes the
+// result field of a successful response into result. Transport failures are
+// returned as *NetworkError, while HTTP error statuses and rejected requests
+// are returned as *EtherscanAPIError, flagged when the contract is unverified.
+func queryExplorer(ctx context.Context, url string, result interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
-		return "", err
+		return err
+	}
+	resp, err := explorerHTTPClient.Do(req)
+	if err != nil {
+		return &NetworkError{err: err}
 	}
 	defer resp.Body.Close()
 
-	var result struct {
-		Status  string `json:"status"`
-		Message string `json:"message"`
-		Result  string `json:"result"`
+	if resp.StatusCode != http.StatusOK {
+		return &EtherscanAPIError{message: resp.Status, statusCode: resp.StatusCode}
+	}
+
+	var response struct {
+		Status  string          `json:"status"`
+		Message string          `json:"message"`
+		Result  json.RawMessage `json:"result"`
 	}
 
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return "", err
+	if err := json.NewDecoder(limitBody(resp.Body)).Decode(&response); err != nil {
+		return err
 	}
 
-	if result.Status != "1" {
-		return "", fmt.Errorf("API error: %s", result.Message)
+	if response.Status != "1" {
+		// Rejections carry their reason as a string result.
+		var reason string
+		json.Unmarshal(response.Result, &reason)
+		reason = strings.ToLower(reason)
+		return &EtherscanAPIError{
+			message:     response.Message,
+			statusCode:  resp.StatusCode,
+			rateLimited: strings.Contains(reason, "rate limit"),
+			invalidKey:  strings.Contains(reason, "invalid api key"),
+			unverified:  strings.Contains(reason, "not verified"),
+		}
 	}
 
-	return result.Result, nil
+	return json.Unmarshal(response.Result, result)
 }
@@ -0,0 +1,343 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFetchABIErrorKinds(t *testing.T) {
+	t.Run("API error", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `{"status":"0","message":"NOTOK","result":"Invalid API Key"}`)
+		}))
+		defer server.Close()
+
+		err := queryExplorer(context.Background(), server.URL, new(string))
+		var apiErr *EtherscanAPIError
+		assert.True(t, errors.As(err, &apiErr))
+	})
+
+	t.Run("HTTP error status", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadGateway)
+		}))
+		defer server.Close()
+
+		err := queryExplorer(context.Background(), server.URL, new(string))
+		var apiErr *EtherscanAPIError
+		assert.True(t, errors.As(err, &apiErr))
+		assert.Equal(t, http.StatusBadGateway, apiErr.statusCode)
+	})
+
+	t.Run("network error", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		server.Close()
+
+		err := queryExplorer(context.Background(), server.URL, new(string))
+		var netErr *NetworkError
+		assert.True(t, errors.As(err, &netErr))
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(200 * time.Millisecond)
+		}))
+		defer server.Close()
+		previous := explorerHTTPClient.Timeout
+		explorerHTTPClient.Timeout = 20 * time.Millisecond
+		defer func() { explorerHTTPClient.Timeout = previous }()
+
+		start := time.Now()
+		err := queryExplorer(context.Background(), server.URL, new(string))
+		var netErr *NetworkError
+		assert.True(t, errors.As(err, &netErr))
+		assert.Less(t, time.Since(start), 200*time.Millisecond)
+	})
+
+	t.Run("success", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `{"status":"1","message":"OK","result":"[]"}`)
+		}))
+		defer server.Close()
+
+		var abi string
+		err := queryExplorer(context.Background(), server.URL, &abi)
+		assert.NoError(t, err)
+		assert.Equal(t, "[]", abi)
+	})
+}
+
+func TestUpstreamResponseSizeLimit(t *testing.T) {
+	previous := maxResponseBodySize
+	maxResponseBodySize = 1024
+	defer func() { maxResponseBodySize = previous }()
+
+	oversized := `{"status":"1","message":"OK","result":"` + strings.Repeat("a", 2048) + `"}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, oversized)
+	}))
+	defer server.Close()
+
+	err := queryExplorer(context.Background(), server.URL, new(string))
+	var tooLarge *ResponseTooLargeError
+	assert.True(t, errors.As(err, &tooLarge), "explorer: %v", err)
+
+	_, err = getABIFromHeimdall(context.Background(), server.URL, "0x0000000000000000000000000000000000000001", "https://rpc.example")
+	assert.True(t, errors.As(err, &tooLarge), "heimdall: %v", err)
+	assert.EqualError(t, err, "upstream response exceeds the limit of 1024 bytes")
+
+	t.Run("body of exactly the limit", func(t *testing.T) {
+		exact := strings.Repeat("a", 1024)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, exact)
+		}))
+		defer server.Close()
+
+		abi, err := getABIFromHeimdall(context.Background(), server.URL, "0x0000000000000000000000000000000000000001", "https://rpc.example")
+		assert.NoError(t, err)
+		assert.Equal(t, exact, abi)
+	})
+}
+
+func TestGetABIFromEtherscanRetriesRateLimits(t *testing.T) {
+	t.Setenv("TEST_API_KEY", "key")
+	rateLimited := `{"status":"0","message":"NOTOK","result":"Max rate limit reached"}`
+
+	newServer := func(responses ...string) (*httptest.Server, *int) {
+		calls := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, responses[min(calls, len(responses)-1)])
+			calls++
+		}))
+		t.Cleanup(server.Close)
+		return server, &calls
+	}
+
+	t.Run("succeeds after rate limit", func(t *testing.T) {
+		server, calls := newServer(rateLimited, rateLimited, `{"status":"1","message":"OK","result":"[]"}`)
+		api := &GenericEtherscanAPI{BaseURL: server.URL, EnvKey: "TEST_API_KEY", Retry: RetryPolicy{MaxRetries: 3, BaseDelay: time.Millisecond}}
+
+		abi, err := api.GetABIFromEtherscan(context.Background(), "0x1000000000000000000000000000000000000001")
+		assert.NoError(t, err)
+		assert.Equal(t, "[]", abi)
+		assert.Equal(t, 3, *calls)
+	})
+
+	t.Run("gives up after max retries", func(t *testing.T) {
+		server, calls := newServer(rateLimited)
+		api := &GenericEtherscanAPI{BaseURL: server.URL, EnvKey: "TEST_API_KEY", Retry: RetryPolicy{MaxRetries: 2, BaseDelay: time.Millisecond}}
+
+		_, err := api.GetABIFromEtherscan(context.Background(), "0x1000000000000000000000000000000000000001")
+		var apiErr *EtherscanAPIError
+		assert.True(t, errors.As(err, &apiErr))
+		assert.True(t, apiErr.rateLimited)
+		assert.Equal(t, 3, *calls)
+	})
+
+	t.Run("does not retry unverified contracts", func(t *testing.T) {
+		server, calls := newServer(`{"status":"0","message":"NOTOK","result":"Contract source code not verified"}`)
+		api := &GenericEtherscanAPI{BaseURL: server.URL, EnvKey: "TEST_API_KEY", Retry: RetryPolicy{MaxRetries: 3, BaseDelay: time.Hour}}
+
+		_, err := api.GetABIFromEtherscan(context.Background(), "0x1000000000000000000000000000000000000001")
+		assert.Error(t, err)
+		assert.Equal(t, 1, *calls)
+	})
+}
+
+func TestGetABIFromEtherscanRotatesAPIKeys(t *testing.T) {
+	address := "0x1000000000000000000000000000000000000001"
+	newServer := func(reject map[string]string) (*httptest.Server, *[]string) {
+		var mu sync.Mutex
+		var keys []string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.URL.Query().Get("apikey")
+			mu.Lock()
+			keys = append(keys, key)
+			mu.Unlock()
+			if result, ok := reject[key]; ok {
+				fmt.Fprintf(w, `{"status":"0","message":"NOTOK","result":%q}`, result)
+				return
+			}
+			fmt.Fprint(w, `{"status":"1","message":"OK","result":"[]"}`)
+		}))
+		t.Cleanup(server.Close)
+		return server, &keys
+	}
+
+	t.Run("round robin", func(t *testing.T) {
+		t.Setenv("TEST_API_KEY", "a, b,c")
+		server, keys := newServer(nil)
+		api := &GenericEtherscanAPI{BaseURL: server.URL, EnvKey: "TEST_API_KEY"}
+		for i := 0; i < 4; i++ {
+			_, err := api.GetABIFromEtherscan(context.Background(), address)
+			assert.NoError(t, err)
+		}
+		assert.Equal(t, []string{"a", "b", "c", "a"}, *keys)
+	})
+
+	t.Run("skips rate limited and invalid keys", func(t *testing.T) {
+		t.Setenv("TEST_API_KEY", "a,b,c")
+		server, keys := newServer(map[string]string{"a": "Max rate limit reached", "b": "Invalid API Key"})
+		api := &GenericEtherscanAPI{BaseURL: server.URL, EnvKey: "TEST_API_KEY", Retry: RetryPolicy{MaxRetries: 3, BaseDelay: time.Hour}}
+		abi, err := api.GetABIFromEtherscan(context.Background(), address)
+		assert.NoError(t, err)
+		assert.Equal(t, "[]", abi)
+		assert.Equal(t, []string{"a", "b", "c"}, *keys, "the next keys are tried without a backoff")
+	})
+
+	t.Run("gives up when every key is invalid", func(t *testing.T) {
+		t.Setenv("TEST_API_KEY", "a,b")
+		server, keys := newServer(map[string]string{"a": "Invalid API Key", "b": "Invalid API Key"})
+		api := &GenericEtherscanAPI{BaseURL: server.URL, EnvKey: "TEST_API_KEY", Retry: RetryPolicy{MaxRetries: 3, BaseDelay: time.Hour}}
+		_, err := api.GetABIFromEtherscan(context.Background(), address)
+		var apiErr *EtherscanAPIError
+		assert.True(t, errors.As(err, &apiErr))
+		assert.True(t, apiErr.invalidKey)
+		assert.Equal(t, []string{"a", "b"}, *keys)
+	})
+
+	t.Run("concurrent requests spread across keys", func(t *testing.T) {
+		t.Setenv("TEST_API_KEY", "a,b")
+		server, keys := newServer(nil)
+		api := &GenericEtherscanAPI{BaseURL: server.URL, EnvKey: "TEST_API_KEY"}
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				api.GetABIFromEtherscan(context.Background(), address)
+			}()
+		}
+		wg.Wait()
+		counts := map[string]int{}
+		for _, key := range *keys {
+			counts[key]++
+		}
+		assert.Equal(t, map[string]int{"a": 5, "b": 5}, counts)
+	})
+}
+
+func TestEtherscanV2APISelectsChain(t *testing.T) {
+	t.Setenv("TEST_API_KEY", "shared")
+	var query url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		fmt.Fprint(w, `{"status":"1","message":"OK","result":"[]"}`)
+	}))
+	defer server.Close()
+
+	api := &EtherscanV2API{GenericEtherscanAPI: GenericEtherscanAPI{BaseURL: server.URL, EnvKey: "TEST_API_KEY"}, ChainID: 8453}
+	abi, err := api.GetABIFromEtherscan(context.Background(), "0x1000000000000000000000000000000000000001")
+	assert.NoError(t, err)
+	assert.Equal(t, "[]", abi)
+	assert.Equal(t, "8453", query.Get("chainid"))
+	assert.Equal(t, "getabi", query.Get("action"))
+	assert.Equal(t, "0x1000000000000000000000000000000000000001", query.Get("address"))
+	assert.Equal(t, "shared", query.Get("apikey"))
+}
+
+func TestGetSourceCode(t *testing.T) {
+	t.Setenv("TEST_API_KEY", "key")
+	var result string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "getsourcecode", r.URL.Query().Get("action"))
+		fmt.Fprintf(w, `{"status":"1","message":"OK","result":%s}`, result)
+	}))
+	defer server.Close()
+	api := &GenericEtherscanAPI{BaseURL: server.URL, EnvKey: "TEST_API_KEY"}
+
+	result = `[{"SourceCode":"contract P {}","ABI":"[]","ContractName":"TransparentUpgradeableProxy","Proxy":"1","Implementation":"0x2000000000000000000000000000000000000002"}]`
+	source, err := api.GetSourceCode(context.Background(), "0x1000000000000000000000000000000000000001")
+	assert.NoError(t, err)
+	assert.Equal(t, SourceCode{ABI: "[]", ContractName: "TransparentUpgradeableProxy", Proxy: true, Implementation: "0x2000000000000000000000000000000000000002"}, source)
+
+	result = `[{"SourceCode":"","ABI":"Contract source code not verified","ContractName":"","Proxy":"0","Implementation":""}]`
+	_, err = api.GetSourceCode(context.Background(), "0x1000000000000000000000000000000000000001")
+	assert.True(t, isUnverifiedContract(err))
+}
+
+func TestGetContractCreation(t *testing.T) {
+	t.Setenv("TEST_API_KEY", "key")
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "getcontractcreation", r.URL.Query().Get("action"))
+		assert.Equal(t, "0x1000000000000000000000000000000000000001", r.URL.Query().Get("contractaddresses"))
+		fmt.Fprint(w, body)
+	}))
+	defer server.Close()
+	api := &GenericEtherscanAPI{BaseURL: server.URL, EnvKey: "TEST_API_KEY"}
+
+	body = `{"status":"1","message":"OK","result":[{"contractAddress":"0x1000000000000000000000000000000000000001","contractCreator":"0x3000000000000000000000000000000000000003","txHash":"0xabc"}]}`
+	creation, err := api.GetContractCreation(context.Background(), "0x1000000000000000000000000000000000000001")
+	assert.NoError(t, err)
+	assert.Equal(t, ContractCreation{Creator: "0x3000000000000000000000000000000000000003", TxHash: "0xabc"}, creation)
+
+	body = `{"status":"0","message":"No data found","result":[]}`
+	creation, err = api.GetContractCreation(context.Background(), "0x1000000000000000000000000000000000000001")
+	assert.NoError(t, err, "contracts without a creation record are no error")
+	assert.Equal(t, ContractCreation{}, creation)
+
+	body = `{"status":"0","message":"NOTOK","result":"Invalid API Key"}`
+	_, err = api.GetContractCreation(context.Background(), "0x1000000000000000000000000000000000000001")
+	assert.Error(t, err)
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: 100 * time.Millisecond}
+	for attempt, base := range []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond} {
+		delay := policy.backoff(attempt)
+		assert.GreaterOrEqual(t, delay, base)
+		assert.Less(t, delay, 2*base)
+	}
+	assert.Zero(t, RetryPolicy{}.backoff(0))
+}
+
+func TestFetchABIFlagsUnverifiedContracts(t *testing.T) {
+	for body, unverified := range map[string]bool{
+		`{"status":"0","message":"NOTOK","result":"Contract source code not verified"}`: true,
+		`{"status":"0","message":"NOTOK","result":"Invalid API Key"}`:                   false,
+	} {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, body)
+		}))
+		err := queryExplorer(context.Background(), server.URL, new(string))
+		server.Close()
+		assert.Equal(t, unverified, isUnverifiedContract(err), body)
+	}
+
+	_, err := (&GenericEtherscanAPI{EnvKey: "UNSET_TEST_API_KEY"}).GetABIFromEtherscan(context.Background(), "0x1000000000000000000000000000000000000001")
+	var keyErr *MissingAPIKeyError
+	assert.True(t, errors.As(err, &keyErr))
+	assert.False(t, isUnverifiedContract(err))
+}
+
+func TestFetchABIAbortsOnCancellation(t *testing.T) {
+	handlerDone := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer close(handlerDone)
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	err := queryExplorer(ctx, server.URL, new(string))
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Less(t, time.Since(start), time.Second)
+	<-handlerDone
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+)
+
+// Explorer API flavours a chain can be configured with.
+const (
+	ChainTypeEtherscan   = "etherscan"
+	ChainTypeEtherscanV2 = "etherscanV2"
+	ChainTypeBlockscout  = "blockscout"
+)
+
+// ChainConfig describes a chain's explorer API. Type selects between an
+// Etherscan-compatible API (the default) and Blockscout. RPCURL is the node
+// used when a request does not name one.
+type ChainConfig struct {
+	ID          int    `json:"id"`
+	Type        string `json:"type,omitempty"`
+	BaseURL     string `json:"baseUrl"`
+	EnvKey      string `json:"envKey"`
+	ExplorerURL string `json:"explorerUrl"`
+	RPCURL      string `json:"rpcUrl,omitempty"`
+}
+
+func defaultChainConfigs() []ChainConfig {
+	return []ChainConfig{
+		{ID: 1, BaseURL: "https://api.etherscan.io/api", EnvKey: "ETHEREUM_API_KEY", ExplorerURL: "https://etherscan.io", RPCURL: "https://ethereum-rpc.publicnode.com"},
+		{ID: 11155111, BaseURL: "https://api-sepolia.etherscan.io/api", EnvKey: "SEPOLIA_API_KEY", ExplorerURL: "https://sepolia.etherscan.io", RPCURL: "https://ethereum-sepolia-rpc.publicnode.com"},
+		{ID: 10, BaseURL: "https://api-optimistic.etherscan.io/api", EnvKey: "OPTIMISM_API_KEY", ExplorerURL: "https://optimistic.etherscan.io", RPCURL: "https://optimism-rpc.publicnode.com"},
+		{ID: 8453, BaseURL: "https://api.basescan.org/api", EnvKey: "BASE_API_KEY", ExplorerURL: "https://basescan.org", RPCURL: "https://base-rpc.publicnode.com"},
+		{ID: 42161, BaseURL: "https://api.arbiscan.io/api", EnvKey: "ARBITRUM_API_KEY", ExplorerURL: "https://arbiscan.io", RPCURL: "https://arbitrum-one-rpc.publicnode.com"},
+		{ID: 43114, BaseURL: "https://api.snowtrace.io/api", EnvKey: "AVALANCHE_API_KEY", ExplorerURL: "https://snowtrace.io", RPCURL: "https://avalanche-c-chain-rpc.publicnode.com"},
+		{ID: 100, Type: ChainTypeBlockscout, BaseURL: "https://gnosis.blockscout.com", EnvKey: "GNOSIS_API_KEY", ExplorerURL: "https://gnosis.blockscout.com", RPCURL: "https://gnosis-rpc.publicnode.com"},
+		{ID: 324, BaseURL: "https://block-explorer-api.mainnet.zksync.io/api", EnvKey: "ZKSYNC_API_KEY", ExplorerURL: "https://explorer.zksync.io", RPCURL: "https://mainnet.era.zksync.io"},
+		{ID: 534352, BaseURL: "https://api.scrollscan.com/api", EnvKey: "SCROLL_API_KEY", ExplorerURL: "https://scrollscan.com", RPCURL: "https://rpc.scroll.io"},
+		{ID: 56, BaseURL: "https://api.bscscan.com/api", EnvKey: "BSC_API_KEY", ExplorerURL: "https://bscscan.com", RPCURL: "https://bsc-rpc.publicnode.com"},
+		{ID: 137, BaseURL: "https://api.polygonscan.com/api", EnvKey: "POLYGON_API_KEY", ExplorerURL: "https://polygonscan.com", RPCURL: "https://polygon-bor-rpc.publicnode.com"},
+	}
+}
+
+// loadChainRegistry builds the chain API registry from the JSON file at path,
+// an array of ChainConfig objects. The built-in chains are used when path is
+// empty or the file does not exist. Invalid entries are logged and skipped.
+func loadChainRegistry(path string) map[int]ChainAPI {
+	configs := defaultChainConfigs()
+	if path != "" {
+		data, err := os.ReadFile(path)
+		switch {
+		case os.IsNotExist(err):
+			slog.Warn("chain config not found, using built-in chains", "path", path)
+		case err != nil:
+			slog.Warn("failed to read chain config, using built-in chains", "path", path, "error", err)
+		default:
+			var fileConfigs []ChainConfig
+			if err := json.Unmarshal(data, &fileConfigs); err != nil {
+				slog.Warn("failed to parse chain config, using built-in chains", "path", path, "error", err)
+			} else {
+				configs = fileConfigs
+			}
+		}
+	}
+
+	registry := make(map[int]ChainAPI)
+	for i, config := range configs {
+		if config.ID <= 0 || (config.BaseURL == "" && config.Type != ChainTypeEtherscanV2) {
+			slog.Warn("skipping chain config entry: id and baseUrl are required", "entry", i)
+			continue
+		}
+		if _, ok := registry[config.ID]; ok {
+			slog.Warn("skipping chain config entry: duplicate chain id", "entry", i, "chainId", config.ID)
+			continue
+		}
+		api, err := newChainAPI(config)
+		if err != nil {
+			slog.Warn("skipping chain config entry", "entry", i, "error", err)
+			continue
+		}
+		registry[config.ID] = api
+	}
+	return registry
+}
+
+func newChainAPI(config ChainConfig) (ChainAPI, error) {
+	switch config.Type {
+	case "", ChainTypeEtherscan:
+		if config.EnvKey == "" {
+			return nil, fmt.Errorf("envKey is required for etherscan chains")
+		}
+		return &GenericEtherscanAPI{BaseURL: config.BaseURL, EnvKey: config.EnvKey, ExplorerURL: config.ExplorerURL, RPCURL: config.RPCURL}, nil
+	case ChainTypeEtherscanV2:
+		api := &EtherscanV2API{ChainID: config.ID}
+		api.BaseURL = config.BaseURL
+		if api.BaseURL == "" {
+			api.BaseURL = defaultEtherscanV2URL
+		}
+		api.EnvKey = config.EnvKey
+		if api.EnvKey == "" {
+			api.EnvKey = "ETHERSCAN_API_KEY"
+		}
+		api.ExplorerURL = config.ExplorerURL
+		api.RPCURL = config.RPCURL
+		return api, nil
+	case ChainTypeBlockscout:
+		return &BlockscoutAPI{BaseURL: config.BaseURL, EnvKey: config.EnvKey, ExplorerURL: config.ExplorerURL, RPCURL: config.RPCURL}, nil
+	default:
+		return nil, fmt.Errorf("unknown type %q", config.Type)
+	}
+}
+
+// ChainStatus describes a configured chain and whether its explorer API key
+// is set, without revealing the key.
+type ChainStatus struct {
+	ID             int    `json:"id"`
+	Type           string `json:"type"`
+	BaseURL        string `json:"baseUrl"`
+	ExplorerURL    string `json:"explorerUrl,omitempty"`
+	EnvKey         string `json:"envKey,omitempty"`
+	APIKeyRequired bool   `json:"apiKeyRequired"`
+	APIKeySet      bool   `json:"apiKeySet"`
+}
+
+// chainStatuses lists the chains of registry ordered by chain id, checking the
+// environment for their API keys.
+func chainStatuses(registry map[int]ChainAPI) []ChainStatus {
+	statuses := make([]ChainStatus, 0, len(registry))
+	for id, api := range registry {
+		status := ChainStatus{ID: id}
+		switch api := api.(type) {
+		case *GenericEtherscanAPI:
+			status.Type = ChainTypeEtherscan
+			status.BaseURL = api.BaseURL
+			status.ExplorerURL = api.ExplorerURL
+			status.EnvKey = api.EnvKey
+			status.APIKeyRequired = true
+		case *EtherscanV2API:
+			status.Type = ChainTypeEtherscanV2
+			status.BaseURL = api.BaseURL
+			status.ExplorerURL = api.ExplorerURL
+			status.EnvKey = api.EnvKey
+			status.APIKeyRequired = true
+		case *BlockscoutAPI:
+			status.Type = ChainTypeBlockscout
+			status.BaseURL = api.BaseURL
+			status.ExplorerURL = api.ExplorerURL
+			status.EnvKey = api.EnvKey
+		}
+		status.APIKeySet = status.EnvKey != "" && os.Getenv(status.EnvKey) != ""
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].ID < statuses[j].ID })
+	return statuses
+}
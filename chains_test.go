@@ -0,0 +1,47 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadChainRegistryFromFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "chains.json")
+	config := `[
+  {"id": 1, "baseUrl": "https://api.etherscan.io/api", "envKey": "ETHEREUM_API_KEY", "explorerUrl": "https://etherscan.io"},
+  {"id": 43114, "baseUrl": "https://api.snowtrace.io/api", "envKey": "AVALANCHE_API_KEY"},
+  {"id": 0, "baseUrl": "https://invalid.example/api", "envKey": "INVALID_API_KEY"},
+  {"id": 5, "envKey": "MISSING_URL_API_KEY"},
+  {"id": 1, "baseUrl": "https://duplicate.example/api", "envKey": "DUPLICATE_API_KEY"},
+  {"id": 100, "type": "blockscout", "baseUrl": "https://gnosis.blockscout.com", "explorerUrl": "https://gnosis.blockscout.com"},
+  {"id": 8453, "type": "etherscanV2", "explorerUrl": "https://basescan.org"},
+  {"id": 6, "type": "unknown", "baseUrl": "https://unknown.example/api", "envKey": "UNKNOWN_API_KEY"}
+]`
+	assert.NoError(t, os.WriteFile(path, []byte(config), 0o600))
+
+	registry := loadChainRegistry(path)
+	assert.Len(t, registry, 4)
+	assert.Equal(t, &GenericEtherscanAPI{BaseURL: "https://api.etherscan.io/api", EnvKey: "ETHEREUM_API_KEY", ExplorerURL: "https://etherscan.io"}, registry[1])
+	assert.Equal(t, &GenericEtherscanAPI{BaseURL: "https://api.snowtrace.io/api", EnvKey: "AVALANCHE_API_KEY"}, registry[43114])
+	assert.Equal(t, &BlockscoutAPI{BaseURL: "https://gnosis.blockscout.com", ExplorerURL: "https://gnosis.blockscout.com"}, registry[100])
+	assert.Equal(t, &EtherscanV2API{
+		GenericEtherscanAPI: GenericEtherscanAPI{BaseURL: defaultEtherscanV2URL, EnvKey: "ETHERSCAN_API_KEY", ExplorerURL: "https://basescan.org"},
+		ChainID:             8453,
+	}, registry[8453])
+}
+
+func TestLoadChainRegistryFallsBackToDefaults(t *testing.T) {
+	defaults := loadChainRegistry("")
+	assert.Len(t, defaults, len(defaultChainConfigs()))
+	assert.IsType(t, &GenericEtherscanAPI{}, defaults[1])
+	assert.IsType(t, &BlockscoutAPI{}, defaults[100])
+
+	assert.Equal(t, defaults, loadChainRegistry(filepath.Join(t.TempDir(), "missing.json")))
+
+	path := filepath.Join(t.TempDir(), "chains.json")
+	assert.NoError(t, os.WriteFile(path, []byte("not json"), 0o600))
+	assert.Equal(t, defaults, loadChainRegistry(path))
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/time/rate"
+)
+
+// ClientRateLimiter enforces a token bucket per client IP so a single client
+// cannot exhaust the upstream API quotas for everyone. Buckets of clients that
+// have been idle for idleTTL are dropped.
+type ClientRateLimiter struct {
+	mu          sync.Mutex
+	clients     map[string]*clientBucket
+	limit       rate.Limit
+	burst       int
+	idleTTL     time.Duration
+	lastCleanup time.Time
+	now         func() time.Time
+}
+
+type clientBucket struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// NewClientRateLimiter allows each client perMinute requests per minute with
+// bursts of up to burst requests.
+func NewClientRateLimiter(perMinute int, burst int) *ClientRateLimiter {
+	return &ClientRateLimiter{
+		clients: make(map[string]*clientBucket),
+		limit:   rate.Limit(float64(perMinute) / 60),
+		burst:   max(burst, 1),
+		idleTTL: 10 * time.Minute,
+		now:     time.Now,
+	}
+}
+
+// Allow reports whether client may make a request now and, if not, how long
+// it has to wait.
+func (l *ClientRateLimiter) Allow(client string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	if now.Sub(l.lastCleanup) >= l.idleTTL {
+		l.cleanup(now)
+	}
+
+	bucket, ok := l.clients[client]
+	if !ok {
+		bucket = &clientBucket{limiter: rate.NewLimiter(l.limit, l.burst)}
+		l.clients[client] = bucket
+	}
+	bucket.lastSeen = now
+
+	reservation := bucket.limiter.ReserveN(now, 1)
+	if delay := reservation.DelayFrom(now); delay > 0 {
+		reservation.CancelAt(now)
+		return false, delay
+	}
+	return true, 0
+}
+
+// cleanup drops the buckets of clients idle for idleTTL. A bucket idle that
+// long has refilled anyway, so dropping it does not change any decision.
+func (l *ClientRateLimiter) cleanup(now time.Time) {
+	for client, bucket := range l.clients {
+		if now.Sub(bucket.lastSeen) >= l.idleTTL {
+			delete(l.clients, client)
+		}
+	}
+	l.lastCleanup = now
+}
+
+// Middleware rejects requests of clients over their limit with 429 Too Many
+// Requests and a Retry-After header.
+func (l *ClientRateLimiter) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		allowed, retryAfter := l.Allow(c.ClientIP())
+		if !allowed {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			c.Abort()
+			respond(c, http.StatusTooManyRequests, gin.H{"error": "Rate limit exceeded, retry after " + retryAfter.Round(time.Second).String()})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientRateLimiter(t *testing.T) {
+	limiter := NewClientRateLimiter(60, 2)
+	now := time.Date(2024, 8, 8, 12, 0, 0, 0, time.UTC)
+	limiter.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		allowed, _ := limiter.Allow("1.1.1.1")
+		assert.True(t, allowed, "burst request %d", i)
+	}
+	allowed, retryAfter := limiter.Allow("1.1.1.1")
+	assert.False(t, allowed, "the limiter blocks after the burst")
+	assert.Equal(t, time.Second, retryAfter)
+
+	allowed, _ = limiter.Allow("2.2.2.2")
+	assert.True(t, allowed, "clients are limited independently")
+
+	now = now.Add(time.Second)
+	allowed, _ = limiter.Allow("1.1.1.1")
+	assert.True(t, allowed, "the limiter recovers over time")
+	allowed, _ = limiter.Allow("1.1.1.1")
+	assert.False(t, allowed)
+}
+
+func TestClientRateLimiterCleanup(t *testing.T) {
+	limiter := NewClientRateLimiter(60, 1)
+	now := time.Date(2024, 8, 8, 12, 0, 0, 0, time.UTC)
+	limiter.now = func() time.Time { return now }
+
+	limiter.Allow("1.1.1.1")
+	now = now.Add(limiter.idleTTL / 2)
+	limiter.Allow("2.2.2.2")
+	now = now.Add(limiter.idleTTL / 2)
+	limiter.Allow("3.3.3.3")
+
+	assert.NotContains(t, limiter.clients, "1.1.1.1", "idle clients are dropped")
+	assert.Contains(t, limiter.clients, "2.2.2.2")
+	assert.Contains(t, limiter.clients, "3.3.3.3")
+}
+
+func TestClientRateLimiterMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/", NewClientRateLimiter(1, 1).Middleware(), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "60", w.Header().Get("Retry-After"))
+}
+
+func TestRouterRateLimitsByConnection(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("RATE_LIMIT_PER_MINUTE", "1")
+	t.Setenv("RATE_LIMIT_BURST", "1")
+	get := func(router *gin.Engine, path string, forwardedFor string) int {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	router := newRouter(nil)
+	assert.NotEqual(t, http.StatusTooManyRequests, get(router, "/proxy/1/0x1/rpc.example", "198.51.100.1"))
+	assert.Equal(t, http.StatusTooManyRequests, get(router, "/proxy/1/0x1/rpc.example", "198.51.100.2"), "a forged X-Forwarded-For is ignored")
+	assert.Equal(t, http.StatusTooManyRequests, get(router, "/bytecode/1/0x1/rpc.example", "198.51.100.3"), "the limit covers every upstream route")
+
+	// httptest requests come from 192.0.2.1.
+	t.Setenv("TRUSTED_PROXIES", "192.0.2.0/24")
+	router = newRouter(nil)
+	assert.NotEqual(t, http.StatusTooManyRequests, get(router, "/proxy/1/0x1/rpc.example", "198.51.100.1"))
+	assert.NotEqual(t, http.StatusTooManyRequests, get(router, "/proxy/1/0x1/rpc.example", "198.51.100.2"), "trusted proxies name the client")
+	assert.Equal(t, http.StatusTooManyRequests, get(router, "/proxy/1/0x1/rpc.example", "198.51.100.1"))
+}
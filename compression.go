@@ -0,0 +1,79 @@
+package main
+
+import (
+	"compress/gzip"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+var gzipWriters = sync.Pool{
+	New: func() interface{} { return gzip.NewWriter(nil) },
+}
+
+// gzipResponses compresses response bodies for clients whose Accept-Encoding
+// allows gzip. ABIs are JSON and usually shrink to a fraction of their size.
+func gzipResponses() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Vary", "Accept-Encoding")
+		if !acceptsGzip(c.GetHeader("Accept-Encoding")) {
+			c.Next()
+			return
+		}
+
+		gz := gzipWriters.Get().(*gzip.Writer)
+		gz.Reset(c.Writer)
+		writer := &gzipResponseWriter{ResponseWriter: c.Writer, gz: gz}
+		c.Writer = writer
+		defer func() {
+			// Responses without a body are sent as they are, as an empty
+			// gzip stream would still add its header.
+			if writer.compressing {
+				gz.Close()
+			}
+			gzipWriters.Put(gz)
+		}()
+		c.Next()
+	}
+}
+
+// acceptsGzip reports whether an Accept-Encoding header allows gzip.
+func acceptsGzip(acceptEncoding string) bool {
+	for _, coding := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(coding, ";")
+		if name = strings.TrimSpace(name); name != "gzip" && name != "*" {
+			continue
+		}
+		q, found := strings.CutPrefix(strings.TrimSpace(params), "q=")
+		if !found {
+			return true
+		}
+		weight, err := strconv.ParseFloat(q, 64)
+		return err == nil && weight > 0
+	}
+	return false
+}
+
+// gzipResponseWriter compresses the body written through it. The encoding
+// headers are set on the first write, before any of the body is sent.
+type gzipResponseWriter struct {
+	gin.ResponseWriter
+	gz          *gzip.Writer
+	compressing bool
+}
+
+func (w *gzipResponseWriter) Write(data []byte) (int, error) {
+	if !w.compressing {
+		w.compressing = true
+		w.Header().Set("Content-Encoding", "gzip")
+		// The length of the uncompressed body no longer applies.
+		w.Header().Del("Content-Length")
+	}
+	return w.gz.Write(data)
+}
+
+func (w *gzipResponseWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGzipResponses(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	abi := `[` + strings.Repeat(`{"type":"function","name":"transfer","inputs":[]},`, 100) + `{"type":"fallback"}]`
+	router := gin.New()
+	router.Use(gzipResponses())
+	router.GET("/abi", func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"abi": abi}) })
+	router.DELETE("/abi", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+	request := func(method string, acceptEncoding string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, "/abi", nil)
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := request("GET", "br, gzip")
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+	assert.Empty(t, w.Header().Get("Content-Length"))
+	assert.Equal(t, "Accept-Encoding", w.Header().Get("Vary"))
+	compressedSize := w.Body.Len()
+	reader, err := gzip.NewReader(w.Body)
+	if assert.NoError(t, err) {
+		body, err := io.ReadAll(reader)
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"abi":`+strconv.Quote(abi)+`}`, string(body))
+		assert.Less(t, compressedSize, len(body)/5)
+	}
+
+	w = request("GET", "gzip;q=0, identity")
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+	assert.JSONEq(t, `{"abi":`+strconv.Quote(abi)+`}`, w.Body.String())
+
+	w = request("DELETE", "gzip")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Empty(t, w.Header().Get("Content-Encoding"), "empty bodies are not encoded")
+	assert.Zero(t, w.Body.Len())
+}
+
+func TestAcceptsGzip(t *testing.T) {
+	for header, expected := range map[string]bool{
+		"":                  false,
+		"gzip":              true,
+		"deflate, gzip;q=1": true,
+		"gzip;q=0":          false,
+		"*":                 true,
+		"identity":          false,
+	} {
+		assert.Equal(t, expected, acceptsGzip(header), header)
+	}
+}
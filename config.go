@@ -0,0 +1,264 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FetcherConfig holds the tunables of an ABIFetcher.
+type FetcherConfig struct {
+	BytecodeCacheTTL time.Duration
+	ENSCacheTTL      time.Duration
+	DialTimeout      time.Duration
+	// CacheMaxEntries bounds each of the in-memory caches besides the ABI
+	// storage: bytecode, ENS resolutions, upgrade histories and warming jobs.
+	CacheMaxEntries int
+	// RPCConcurrency caps the RPC calls a single request may have in flight.
+	RPCConcurrency int
+	// BatchProxyReads fetches the storage slots and getters proxy detection
+	// reads in one JSON-RPC batch, using Multicall3 for the getters where it
+	// is deployed.
+	BatchProxyReads bool
+	// HistoryBlockRange bounds how many recent blocks are searched for upgrades.
+	HistoryBlockRange uint64
+	// HistoryChunkSize is how many blocks a single log query covers, which
+	// nodes limit.
+	HistoryChunkSize          uint64
+	HistoryMaxImplementations int
+	HistoryCacheTTL           time.Duration
+	// BatchConcurrency caps how many ABIs of a batch are fetched at once.
+	BatchConcurrency int
+	BatchMaxSize     int
+	// WarmConcurrency caps how many ABIs all cache warming jobs together
+	// fetch at once, so warming does not crowd out live requests.
+	WarmConcurrency int
+	WarmMaxSize     int
+	// WarmJobTTL is how long the progress of a warming job can be queried
+	// after it started or finished.
+	WarmJobTTL time.Duration
+	// AllowedChainIDs, when not empty, are the only chains served.
+	// DeniedChainIDs are never served.
+	AllowedChainIDs map[int]bool
+	DeniedChainIDs  map[int]bool
+	// AllowPrivateRPC permits RPC URLs pointing at private, loopback and
+	// link-local addresses.
+	AllowPrivateRPC bool
+	// NegativeCacheTTL is how long it is cached that no source could provide
+	// an ABI for a contract. Zero disables caching such failures.
+	NegativeCacheTTL time.Duration
+	// ExplorerProxyFallback consults the explorer's getsourcecode proxy flag
+	// and prefers the implementation it reports when on-chain detection finds
+	// none or a different one.
+	ExplorerProxyFallback bool
+	// KnownABIsFile is a JSON file of KnownABI entries matching unverified
+	// clones of verified contracts by their bytecode hash.
+	KnownABIsFile string
+	// DisableDecompilation only serves verified ABIs, never falling back to
+	// Heimdall.
+	DisableDecompilation bool
+	// StrictVerified only serves ABIs verified on the explorer or Sourcify,
+	// as if every request set FetchOptions.VerifiedOnly.
+	StrictVerified bool
+	// SourcifyURL is the Sourcify repository consulted when the explorer has
+	// no verified ABI.
+	SourcifyURL string
+	// IPFSGatewayURL is the IPFS gateway metadata.json is fetched from, by
+	// the hash in the contract's bytecode, when neither the explorer nor
+	// Sourcify has a verified ABI. Empty disables the fallback.
+	IPFSGatewayURL string
+	// HeimdallURL is the Heimdall API decompiling unverified contracts, e.g.
+	// a self-hosted deployment.
+	HeimdallURL string
+	// WebhookURL receives an UpgradeNotification when one of WatchTargets
+	// changes its implementation. WatchInterval is how often they are
+	// checked.
+	WebhookURL    string
+	WatchTargets  []WatchTarget
+	WatchInterval time.Duration
+	// Logger receives the fetcher's logs; nil logs to the default slog
+	// logger.
+	Logger Logger
+}
+
+func DefaultFetcherConfig() FetcherConfig {
+	return FetcherConfig{
+		BytecodeCacheTTL: 5 * time.Minute,
+		ENSCacheTTL:      time.Hour,
+		CacheMaxEntries:  5000,
+		DialTimeout:      10 * time.Second,
+		RPCConcurrency:   8,
+
+		HistoryBlockRange:         1_000_000,
+		HistoryChunkSize:          10_000,
+		HistoryMaxImplementations: 10,
+		HistoryCacheTTL:           time.Hour,
+
+		BatchConcurrency: 4,
+		BatchMaxSize:     50,
+
+		WarmConcurrency: 2,
+		WarmMaxSize:     1000,
+		WarmJobTTL:      time.Hour,
+
+		NegativeCacheTTL: 10 * time.Minute,
+		SourcifyURL:      defaultSourcifyURL,
+		IPFSGatewayURL:   defaultIPFSGatewayURL,
+		HeimdallURL:      defaultHeimdallURL,
+
+		WatchInterval: 10 * time.Minute,
+	}
+}
+
+// fetcherConfigFromEnv overrides the defaults with any values set in the environment.
+func fetcherConfigFromEnv() FetcherConfig {
+	config := DefaultFetcherConfig()
+	config.BytecodeCacheTTL = envDuration("BYTECODE_CACHE_TTL", config.BytecodeCacheTTL)
+	config.ENSCacheTTL = envDuration("ENS_CACHE_TTL", config.ENSCacheTTL)
+	config.CacheMaxEntries = envInt("MAX_AUX_CACHE_ENTRIES", config.CacheMaxEntries)
+	config.DialTimeout = envDuration("DIAL_TIMEOUT", config.DialTimeout)
+	config.RPCConcurrency = envInt("RPC_REQUEST_CONCURRENCY", config.RPCConcurrency)
+	config.BatchProxyReads = envBool("PROXY_READ_BATCHING", config.BatchProxyReads)
+	config.HistoryBlockRange = uint64(envInt("HISTORY_BLOCK_RANGE", int(config.HistoryBlockRange)))
+	config.HistoryChunkSize = uint64(envInt("HISTORY_CHUNK_SIZE", int(config.HistoryChunkSize)))
+	config.HistoryMaxImplementations = envInt("HISTORY_MAX_IMPLEMENTATIONS", config.HistoryMaxImplementations)
+	config.HistoryCacheTTL = envDuration("HISTORY_CACHE_TTL", config.HistoryCacheTTL)
+	config.BatchConcurrency = envInt("BATCH_CONCURRENCY", config.BatchConcurrency)
+	config.BatchMaxSize = envInt("BATCH_MAX_SIZE", config.BatchMaxSize)
+	config.WarmConcurrency = envInt("CACHE_WARM_CONCURRENCY", config.WarmConcurrency)
+	config.WarmMaxSize = envInt("CACHE_WARM_MAX_SIZE", config.WarmMaxSize)
+	config.WarmJobTTL = envDuration("CACHE_WARM_JOB_TTL", config.WarmJobTTL)
+	config.AllowedChainIDs = envChainIDs("ALLOWED_CHAIN_IDS")
+	config.DeniedChainIDs = envChainIDs("DENIED_CHAIN_IDS")
+	config.AllowPrivateRPC = envBool("ALLOW_PRIVATE_RPC", config.AllowPrivateRPC)
+	config.NegativeCacheTTL = envDuration("NEGATIVE_CACHE_TTL", config.NegativeCacheTTL)
+	config.DisableDecompilation = envBool("DISABLE_DECOMPILATION", config.DisableDecompilation)
+	config.StrictVerified = envBool("STRICT_VERIFIED", config.StrictVerified)
+	config.ExplorerProxyFallback = envBool("EXPLORER_PROXY_FALLBACK", config.ExplorerProxyFallback)
+	if sourcifyURL := os.Getenv("SOURCIFY_URL"); sourcifyURL != "" {
+		config.SourcifyURL = sourcifyURL
+	}
+	if gatewayURL, ok := os.LookupEnv("IPFS_GATEWAY_URL"); ok {
+		config.IPFSGatewayURL = gatewayURL
+	}
+	config.KnownABIsFile = os.Getenv("KNOWN_ABIS_FILE")
+	if heimdallURL := os.Getenv("HEIMDALL_URL"); heimdallURL != "" {
+		config.HeimdallURL = heimdallURL
+	}
+	config.WebhookURL = os.Getenv("WEBHOOK_URL")
+	config.WatchTargets = parseWatchTargets(os.Getenv("WATCH_ADDRESSES"))
+	config.WatchInterval = envDuration("WATCH_INTERVAL", config.WatchInterval)
+	return config
+}
+
+// storageFromEnv creates the ABI storage selected by STORAGE_BACKEND: the
+// in-memory cache (the default) or Redis at REDIS_URL.
+func storageFromEnv() (StorageBackend, error) {
+	ttl := envDuration("CACHE_TTL", 24*time.Hour)
+	switch backend := os.Getenv("STORAGE_BACKEND"); backend {
+	case "", "memory":
+		storage := NewABIStorage(ttl, envInt("MAX_CACHE_ENTRIES", 10000))
+		storage.StartSweeper(envDuration("CACHE_SWEEP_INTERVAL", 10*time.Minute))
+		return storage, nil
+	case "redis":
+		redisURL := os.Getenv("REDIS_URL")
+		if redisURL == "" {
+			return nil, fmt.Errorf("REDIS_URL must be set when STORAGE_BACKEND is redis")
+		}
+		return NewRedisStorage(redisURL, ttl)
+	default:
+		return nil, fmt.Errorf("unknown STORAGE_BACKEND %q: must be memory or redis", backend)
+	}
+}
+
+// envChainIDs parses the comma-separated chain IDs in key, skipping invalid
+// ones with a warning.
+func envChainIDs(key string) map[int]bool {
+	ids := make(map[int]bool)
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		id, err := strconv.Atoi(value)
+		if err != nil {
+			slog.Warn("invalid chain ID, ignoring it", "key", key, "value", value)
+			continue
+		}
+		ids[id] = true
+	}
+	return ids
+}
+
+// envList parses the comma-separated values in key.
+func envList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+func envString(key string, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+func envInt(key string, fallback int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		slog.Warn("invalid integer, using the default", "key", key, "value", value, "default", fallback)
+		return fallback
+	}
+	return i
+}
+
+func envFloat(key string, fallback float64) float64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		slog.Warn("invalid number, using the default", "key", key, "value", value, "default", fallback)
+		return fallback
+	}
+	return f
+}
+
+func envDuration(key string, fallback time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		slog.Warn("invalid duration, using the default", "key", key, "value", value, "default", fallback)
+		return fallback
+	}
+	return d
+}
+
+func envBool(key string, fallback bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		slog.Warn("invalid boolean, using the default", "key", key, "value", value, "default", fallback)
+		return fallback
+	}
+	return b
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/ugorji/go/codec"
+)
+
+const (
+	MIMECBOR    = "application/cbor"
+	MIMEMsgPack = "application/msgpack"
+)
+
+// binaryEncodings holds the codecs of the binary media types responses can
+// be encoded in besides JSON. Maps are written in canonical key order so
+// equal responses encode identically.
+var binaryEncodings = map[string]codec.Handle{
+	MIMECBOR:                  &codec.CborHandle{BasicHandle: codec.BasicHandle{EncodeOptions: codec.EncodeOptions{Canonical: true}}},
+	MIMEMsgPack:               msgpackHandle,
+	"application/x-msgpack":   msgpackHandle,
+	"application/vnd.msgpack": msgpackHandle,
+}
+
+// msgpackHandle writes strings with the str types of the current msgpack
+// spec rather than as raw bytes.
+var msgpackHandle = &codec.MsgpackHandle{
+	WriteExt:    true,
+	BasicHandle: codec.BasicHandle{EncodeOptions: codec.EncodeOptions{Canonical: true}},
+}
+
+// responseFormats are the media types offered to the Accept header, JSON
+// first so that it is the default.
+var responseFormats = []string{binding.MIMEJSON, MIMECBOR, MIMEMsgPack, "application/x-msgpack", "application/vnd.msgpack"}
+
+// binaryEncoding returns the media type and codec of the binary encoding the
+// request's Accept header asks for, or a nil codec for JSON.
+func binaryEncoding(c *gin.Context) (string, codec.Handle) {
+	format := c.NegotiateFormat(responseFormats...)
+	return format, binaryEncodings[format]
+}
+
+// respondBinary writes obj encoded with handle as mimeType.
+func respondBinary(c *gin.Context, code int, obj interface{}, mimeType string, handle codec.Handle) {
+	data, err := encodeBinary(obj, handle)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to encode response: " + err.Error()})
+		return
+	}
+	c.Data(code, mimeType, data)
+}
+
+func encodeBinary(obj interface{}, handle codec.Handle) ([]byte, error) {
+	plain, err := plainValue(obj)
+	if err != nil {
+		return nil, err
+	}
+	var data []byte
+	if err := codec.NewEncoderBytes(&data, handle).Encode(plain); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// plainValue converts obj to the maps, slices, strings, numbers and booleans
+// of its JSON encoding, so binary encodings carry the same fields as JSON.
+// Embedded JSON, like a structured ABI, becomes nested values instead of
+// bytes.
+func plainValue(obj interface{}) (interface{}, error) {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	return withIntegers(value), nil
+}
+
+// withIntegers replaces the JSON numbers in value with integers where they
+// are whole, and floats otherwise.
+func withIntegers(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = withIntegers(item)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = withIntegers(item)
+		}
+	case json.Number:
+		if integer, err := v.Int64(); err == nil {
+			return integer
+		}
+		float, _ := v.Float64()
+		return float
+	}
+	return value
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/ugorji/go/codec"
+)
+
+// Decoders that read maps with string keys, as clients usually do.
+var (
+	cborDecoder    = &codec.CborHandle{BasicHandle: codec.BasicHandle{DecodeOptions: stringMaps()}}
+	msgpackDecoder = &codec.MsgpackHandle{BasicHandle: codec.BasicHandle{DecodeOptions: stringMaps()}}
+)
+
+func stringMaps() codec.DecodeOptions {
+	return codec.DecodeOptions{MapType: reflect.TypeOf(map[string]interface{}(nil)), RawToString: true}
+}
+
+func TestResponseEncodingNegotiation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	previous := abiFetcher
+	defer func() { abiFetcher = previous }()
+	storage := NewABIStorage(time.Hour, 0)
+	abiFetcher = NewABIFetcher(storage, map[int]ChainAPI{}, DefaultFetcherConfig())
+
+	router := gin.New()
+	router.GET("/abi/:chainId/:address/*rpcUrl", getABI)
+
+	address := "0x1000000000000000000000000000000000000001"
+	abi := `[{"type":"function","name":"transfer","inputs":[],"outputs":[],"stateMutability":"nonpayable"}]`
+	storage.Set("1-"+address, StorageItem{ABI: abi})
+
+	for _, tc := range []struct {
+		accept      string
+		query       string
+		contentType string
+		handle      codec.Handle
+		structured  bool
+	}{
+		{"", "", "application/json; charset=utf-8", nil, false},
+		{"*/*", "", "application/json; charset=utf-8", nil, false},
+		{"application/json", "", "application/json; charset=utf-8", nil, false},
+		{"text/html", "", "application/json; charset=utf-8", nil, false},
+		{"application/cbor", "", MIMECBOR, cborDecoder, true},
+		{"application/cbor", "?format=string", MIMECBOR, cborDecoder, false},
+		{"application/msgpack", "", MIMEMsgPack, msgpackDecoder, true},
+		{"application/x-msgpack", "", "application/x-msgpack", msgpackDecoder, true},
+		{"application/vnd.msgpack", "", "application/vnd.msgpack", msgpackDecoder, true},
+	} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/abi/1/"+address+"/rpc.example"+tc.query, nil)
+		req.Header.Set("Accept", tc.accept)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code, tc.accept)
+		assert.Equal(t, tc.contentType, w.Header().Get("Content-Type"), tc.accept)
+		assert.Contains(t, w.Header().Values("Vary"), "Accept", tc.accept)
+
+		var response map[string]interface{}
+		if tc.handle == nil {
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response), tc.accept)
+		} else {
+			assert.NoError(t, codec.NewDecoderBytes(w.Body.Bytes(), tc.handle).Decode(&response), tc.accept)
+		}
+		assert.Equal(t, false, response["isProxy"], tc.accept)
+		if tc.structured {
+			entries, ok := response["abi"].([]interface{})
+			if assert.True(t, ok, "%s embeds the ABI as nested values", tc.accept) && assert.Len(t, entries, 1) {
+				assert.Equal(t, "transfer", entries[0].(map[string]interface{})["name"])
+			}
+		} else {
+			assert.JSONEq(t, abi, response["abi"].(string), tc.accept)
+		}
+	}
+}
+
+func TestPlainValue(t *testing.T) {
+	value, err := plainValue(gin.H{
+		"abi":   json.RawMessage(`[{"type":"event","anonymous":false}]`),
+		"count": 3,
+		"ratio": 0.5,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"abi":   []interface{}{map[string]interface{}{"type": "event", "anonymous": false}},
+		"count": int64(3),
+		"ratio": 0.5,
+	}, value)
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// ensRegistries holds the ENS registry of each chain ENS is deployed on.
+var ensRegistries = map[string]common.Address{
+	"1":        common.HexToAddress("0x00000000000C2E074eC69A0dFb2997BA6C7d2e1e"),
+	"11155111": common.HexToAddress("0x00000000000C2E074eC69A0dFb2997BA6C7d2e1e"),
+	"17000":    common.HexToAddress("0x00000000000C2E074eC69A0dFb2997BA6C7d2e1e"),
+}
+
+const (
+	// ENSResolverMethod is resolver(bytes32) of the ENS registry.
+	ENSResolverMethod = "0x0178b8bf"
+	// ENSAddrMethod is addr(bytes32) of ENS resolvers.
+	ENSAddrMethod = "0x3b3b57de"
+)
+
+// isENSName reports whether s looks like an ENS name such as usdc.eth rather
+// than a hex address.
+func isENSName(s string) bool {
+	if strings.HasPrefix(s, "0x") || strings.ContainsAny(s, " /") {
+		return false
+	}
+	labels := strings.Split(s, ".")
+	if len(labels) < 2 {
+		return false
+	}
+	for _, label := range labels {
+		if label == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// namehash computes the EIP-137 node of name.
+func namehash(name string) common.Hash {
+	var node common.Hash
+	if name == "" {
+		return node
+	}
+	labels := strings.Split(strings.ToLower(name), ".")
+	for i := len(labels) - 1; i >= 0; i-- {
+		labelHash := crypto.Keccak256([]byte(labels[i]))
+		node = crypto.Keccak256Hash(node.Bytes(), labelHash)
+	}
+	return node
+}
+
+// resolveENSName looks up the resolver of name in registry and returns the
+// address record it holds.
+func resolveENSName(ctx context.Context, client ContractReader, registry common.Address, name string) (common.Address, error) {
+	node := namehash(name)
+	resolver, err := callAddressGetter(ctx, client, registry, append(common.FromHex(ENSResolverMethod), node.Bytes()...))
+	if err != nil {
+		return common.Address{}, fmt.Errorf("failed to look up ENS resolver: %w", err)
+	}
+	if resolver == (common.Address{}) {
+		return common.Address{}, &ENSResolutionError{name: name, reason: "the name is not registered or has no resolver"}
+	}
+	address, err := callAddressGetter(ctx, client, resolver, append(common.FromHex(ENSAddrMethod), node.Bytes()...))
+	if err != nil {
+		return common.Address{}, &ENSResolutionError{name: name, reason: "the resolver did not return an address: " + err.Error()}
+	}
+	if address == (common.Address{}) {
+		return common.Address{}, &ENSResolutionError{name: name, reason: "the name has no address record"}
+	}
+	return address, nil
+}
+
+// callAddressGetter calls a function of contract returning a single address.
+func callAddressGetter(ctx context.Context, client ContractReader, contract common.Address, data []byte) (common.Address, error) {
+	result, err := client.CallContract(ctx, ethereum.CallMsg{To: &contract, Data: data}, nil)
+	if err != nil {
+		return common.Address{}, err
+	}
+	if len(result) < 32 {
+		return common.Address{}, fmt.Errorf("unexpected result length %d", len(result))
+	}
+	return common.BytesToAddress(result[:32]), nil
+}
+
+// ENSCache caches ENS name to address resolutions per chain. Entries expire so
+// that changes to a name's records are eventually picked up.
+type ENSCache struct {
+	cache *ttlCache[common.Address]
+}
+
+func NewENSCache(ttl time.Duration, maxEntries int) *ENSCache {
+	return &ENSCache{cache: newTTLCache[common.Address](ttl, maxEntries)}
+}
+
+func (c *ENSCache) Get(chainId string, name string) (common.Address, bool) {
+	return c.cache.Get(ensCacheKey(chainId, name))
+}
+
+func (c *ENSCache) Set(chainId string, name string, address common.Address) {
+	c.cache.Set(ensCacheKey(chainId, name), address)
+}
+
+func (c *ENSCache) Delete(chainId string, name string) bool {
+	return c.cache.Delete(ensCacheKey(chainId, name))
+}
+
+// ensCacheKey lowercases the name since ENS names are case-insensitive.
+func ensCacheKey(chainId string, name string) string {
+	return chainId + "-" + strings.ToLower(name)
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestENSCache(t *testing.T) {
+	cache := NewENSCache(time.Hour, 0)
+	address := common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48")
+	cache.Set("1", "USDC.eth", address)
+
+	resolved, ok := cache.Get("1", "usdc.eth")
+	assert.True(t, ok)
+	assert.Equal(t, address, resolved)
+
+	_, ok = cache.Get("11155111", "usdc.eth")
+	assert.False(t, ok, "resolutions are cached per chain")
+
+	assert.True(t, cache.Delete("1", "usdc.eth"))
+	_, ok = cache.Get("1", "usdc.eth")
+	assert.False(t, ok)
+}
+
+func TestNamehash(t *testing.T) {
+	assert.Equal(t, common.Hash{}, namehash(""))
+	assert.Equal(t, common.HexToHash("0x93cdeb708b7545dc668eb9280176169d1c33cfd8ed6f04690a0bcc88a93fc4ae"), namehash("eth"))
+	assert.Equal(t, common.HexToHash("0xde9b09fd7c5f901e23a3f19fecc54828e9c848539801e86591bd9801b019f84f"), namehash("foo.eth"))
+	assert.Equal(t, namehash("foo.eth"), namehash("FOO.eth"))
+}
+
+func TestIsENSName(t *testing.T) {
+	for name, expected := range map[string]bool{
+		"usdc.eth":     true,
+		"sub.usdc.eth": true,
+		"0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48": false,
+		"usdc":      false,
+		"usdc..eth": false,
+		".eth":      false,
+	} {
+		assert.Equal(t, expected, isENSName(name), name)
+	}
+}
+
+func TestResolveENSName(t *testing.T) {
+	registry := ensRegistries["1"]
+	resolver := common.HexToAddress("0x2000000000000000000000000000000000000002")
+	target := common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48")
+	node := namehash("usdc.eth")
+	client := newMockContractReader()
+	client.setCall(registry, ENSResolverMethod+common.Bytes2Hex(node.Bytes()), resolver)
+	client.setCall(resolver, ENSAddrMethod+common.Bytes2Hex(node.Bytes()), target)
+
+	address, err := resolveENSName(context.Background(), client, registry, "usdc.eth")
+	assert.NoError(t, err)
+	assert.Equal(t, target, address)
+
+	client.setCall(registry, ENSResolverMethod+common.Bytes2Hex(namehash("missing.eth").Bytes()), common.Address{})
+	_, err = resolveENSName(context.Background(), client, registry, "missing.eth")
+	assert.IsType(t, &ENSResolutionError{}, err)
+}
+
+func TestFetchABIResolvesENSNames(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	storage := NewABIStorage(time.Hour, 0)
+	fetcher := NewABIFetcher(storage, map[int]ChainAPI{}, DefaultFetcherConfig())
+	address := common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48")
+	fetcher.ensCache.Set("1", "usdc.eth", address)
+	storage.Set("1-"+address.Hex(), StorageItem{ABI: "[]"})
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("GET", "/", nil)
+	response, err := fetcher.FetchABI(c, "1", "usdc.eth", "127.0.0.1:1", FetchOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "usdc.eth", response["ensName"])
+	assert.Equal(t, address.Hex(), response["resolvedAddress"])
+
+	_, err = fetcher.FetchABI(c, "10", "usdc.eth", "127.0.0.1:1", FetchOptions{})
+	assert.IsType(t, &InvalidInputError{}, err, "ENS is only resolved on chains it is deployed on")
+}
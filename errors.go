@@ -1,25 +1,234 @@
 package main
 
+import (
+	"errors"
+	"strconv"
+	"time"
+)
+
+// InvalidInputError reports a request parameter that was rejected. Its code
+// tells clients which check failed without parsing the message.
 type InvalidInputError struct {
 	message string
+	code    string
 }
 
+// Codes of InvalidInputError.
+const (
+	CodeInvalidChainID       = "INVALID_CHAIN_ID"
+	CodeChainNotAllowed      = "CHAIN_NOT_ALLOWED"
+	CodeInvalidAddressLength = "INVALID_ADDRESS_LENGTH"
+	CodeInvalidAddressHex    = "INVALID_ADDRESS_HEX"
+	CodeInvalidChecksum      = "INVALID_ADDRESS_CHECKSUM"
+	CodeENSUnsupported       = "ENS_UNSUPPORTED_CHAIN"
+	CodeEmptyRPCURL          = "EMPTY_RPC_URL"
+	CodeInvalidRPCURL        = "INVALID_RPC_URL"
+	CodePrivateRPCURL        = "PRIVATE_RPC_URL"
+	CodeRPCUnreachable       = "RPC_UNREACHABLE"
+	CodeInvalidFormat        = "INVALID_FORMAT"
+	CodeInvalidBlock         = "INVALID_BLOCK"
+	CodeInvalidBatch         = "INVALID_BATCH"
+	CodeBatchTooLarge        = "BATCH_TOO_LARGE"
+	CodeInvalidBody          = "INVALID_BODY"
+	CodeInvalidTxHash        = "INVALID_TX_HASH"
+)
+
 func (e *InvalidInputError) Error() string {
 	return e.message
 }
 
+// ContractNotFoundError reports an address without code. Its code tells an
+// externally owned account apart from an address that never held code or
+// whose contract self-destructed.
 type ContractNotFoundError struct {
 	address string
+	code    string
 }
 
+// Codes of ContractNotFoundError.
+const (
+	CodeNoCode          = "NO_CODE"
+	CodeExternallyOwned = "EXTERNALLY_OWNED_ACCOUNT"
+)
+
 func (e *ContractNotFoundError) Error() string {
-	return "The address: " + e.address + " is not a contract"
+	if e.code == CodeExternallyOwned {
+		return "The address: " + e.address + " is not a contract but an externally owned account"
+	}
+	return "The address: " + e.address + " is not a contract: no code is deployed there; it was never deployed or has self-destructed"
+}
+
+// TransactionNotFoundError reports a transaction hash the RPC node does not
+// know.
+type TransactionNotFoundError struct {
+	hash string
+}
+
+func (e *TransactionNotFoundError) Error() string {
+	return "Transaction " + e.hash + " not found"
+}
+
+// ContractCreationTxError reports a transaction that deployed a contract
+// rather than calling one, so there is no called contract to fetch the ABI of.
+type ContractCreationTxError struct {
+	hash string
+	// contractAddress is the deployed contract, when the receipt is
+	// available.
+	contractAddress string
 }
 
+func (e *ContractCreationTxError) Error() string {
+	message := "Transaction " + e.hash + " creates a contract and calls none"
+	if e.contractAddress != "" {
+		message += "; the created contract is " + e.contractAddress
+	}
+	return message
+}
+
+// ProxyTargetUnresolvableError reports a proxy whose detected implementation
+// has no code, e.g. a broken or deprecated proxy.
+type ProxyTargetUnresolvableError struct {
+	address   string
+	proxyType string
+	target    string
+}
+
+func (e *ProxyTargetUnresolvableError) Error() string {
+	return "The address: " + e.address + " is a " + e.proxyType + " proxy whose target " + e.target + " has no code"
+}
+
+// EtherscanAPIError reports a response the explorer API delivered but rejected,
+// either through an HTTP error status or a non-"1" status field.
 type EtherscanAPIError struct {
-	message string
+	message    string
+	statusCode int
+	// rateLimited is set when the API rejected the request for exceeding its
+	// rate limit, so it may succeed when retried.
+	rateLimited bool
+	// invalidKey is set when the API rejected the API key.
+	invalidKey bool
+	// unverified is set when the API answered that the contract's source code
+	// is not verified, as opposed to failing to answer at all.
+	unverified bool
 }
 
 func (e *EtherscanAPIError) Error() string {
 	return "Etherscan API error: " + e.message
 }
+
+// isUnverifiedContract reports whether err means the explorer has no verified
+// source for the contract.
+func isUnverifiedContract(err error) bool {
+	var apiErr *EtherscanAPIError
+	return errors.As(err, &apiErr) && apiErr.unverified
+}
+
+// ABIUnavailableError reports that no source could provide an ABI for the
+// contract: neither the explorer nor Sourcify has verified source for it and
+// Heimdall failed to decompile it.
+type ABIUnavailableError struct {
+	address string
+	reason  string
+}
+
+func (e *ABIUnavailableError) Error() string {
+	return "No ABI available for " + e.address + ": " + e.reason
+}
+
+// VerifiedABINotFoundError reports that neither the explorer nor Sourcify has
+// a verified ABI for the contract while decompilation is disabled.
+type VerifiedABINotFoundError struct {
+	address string
+}
+
+func (e *VerifiedABINotFoundError) Error() string {
+	return "No verified ABI found for " + e.address + " and decompilation is disabled"
+}
+
+// UnverifiedABIError reports that neither the explorer nor Sourcify has a
+// verified ABI for the contract while only verified ABIs may be served.
+type UnverifiedABIError struct {
+	address string
+}
+
+func (e *UnverifiedABIError) Error() string {
+	return "No verified ABI found for " + e.address + " and only verified ABIs are served"
+}
+
+// CodeUnverified is the code of UnverifiedABIError.
+const CodeUnverified = "UNVERIFIED"
+
+// InvalidABIError reports that a source answered with something that is not a
+// valid ABI, such as an error message or truncated JSON, so it was neither
+// cached nor served.
+type InvalidABIError struct {
+	source string
+	reason string
+}
+
+func (e *InvalidABIError) Error() string {
+	return "The " + e.source + " source returned an invalid ABI: " + e.reason
+}
+
+// DecompilationError reports that Heimdall answered but could not decompile
+// the contract.
+type DecompilationError struct {
+	message string
+}
+
+func (e *DecompilationError) Error() string {
+	return "heimdall API error: " + e.message
+}
+
+// ResponseTooLargeError reports an upstream response body larger than the
+// configured maximum, which was not read any further.
+type ResponseTooLargeError struct {
+	limit int64
+}
+
+func (e *ResponseTooLargeError) Error() string {
+	return "upstream response exceeds the limit of " + strconv.FormatInt(e.limit, 10) + " bytes"
+}
+
+// ENSResolutionError reports an ENS name that does not resolve to an address.
+type ENSResolutionError struct {
+	name   string
+	reason string
+}
+
+func (e *ENSResolutionError) Error() string {
+	return "Could not resolve ENS name " + e.name + ": " + e.reason
+}
+
+// MissingAPIKeyError reports that a chain's explorer API key is not configured.
+type MissingAPIKeyError struct {
+	envKey string
+}
+
+func (e *MissingAPIKeyError) Error() string {
+	return "API key not set for chain: " + e.envKey
+}
+
+// ExplorerRateLimitedError reports that a request to an explorer API was not
+// sent because the local rate limit would have delayed it too long.
+type ExplorerRateLimitedError struct {
+	maxWait time.Duration
+}
+
+func (e *ExplorerRateLimitedError) Error() string {
+	return "explorer rate limit: no request slot available within " + e.maxWait.String()
+}
+
+// NetworkError reports a transport failure (DNS, connection, TLS, timeout)
+// before any response was received from an upstream API.
+type NetworkError struct {
+	err error
+}
+
+func (e *NetworkError) Error() string {
+	return "network error: " + e.err.Error()
+}
+
+func (e *NetworkError) Unwrap() error {
+	return e.err
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// ExplorerLimiter paces requests to an explorer API so bursts queue for a
+// token instead of exhausting the API quota. A request waits at most maxWait
+// and fails fast when its turn would come later than that.
+type ExplorerLimiter struct {
+	limiter *rate.Limiter
+	maxWait time.Duration
+}
+
+func NewExplorerLimiter(perSecond float64, maxWait time.Duration) *ExplorerLimiter {
+	return &ExplorerLimiter{
+		limiter: rate.NewLimiter(rate.Limit(perSecond), 1),
+		maxWait: maxWait,
+	}
+}
+
+// Wait blocks until a request may be sent. It returns an *ExplorerRateLimitedError
+// without waiting when no token is available within maxWait or before ctx expires,
+// and ctx's error when ctx is done first, e.g. because the client went away.
+func (l *ExplorerLimiter) Wait(ctx context.Context) error {
+	waitCtx, cancel := context.WithTimeout(ctx, l.maxWait)
+	defer cancel()
+	if err := l.limiter.Wait(waitCtx); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return &ExplorerRateLimitedError{maxWait: l.maxWait}
+	}
+	return nil
+}
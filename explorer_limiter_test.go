@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExplorerLimiterQueuesWithinMaxWait(t *testing.T) {
+	limiter := NewExplorerLimiter(20, 200*time.Millisecond)
+
+	start := time.Now()
+	assert.NoError(t, limiter.Wait(context.Background()))
+	assert.NoError(t, limiter.Wait(context.Background()), "second request waits for the next token")
+	assert.GreaterOrEqual(t, time.Since(start), 40*time.Millisecond)
+}
+
+func TestExplorerLimiterFailsFastBeyondMaxWait(t *testing.T) {
+	limiter := NewExplorerLimiter(1, 100*time.Millisecond)
+	assert.NoError(t, limiter.Wait(context.Background()))
+
+	start := time.Now()
+	err := limiter.Wait(context.Background())
+	var rateLimited *ExplorerRateLimitedError
+	assert.True(t, errors.As(err, &rateLimited))
+	assert.Less(t, time.Since(start), 50*time.Millisecond, "should not wait when the slot is too far away")
+}
+
+func TestExplorerLimiterHonorsContextDeadline(t *testing.T) {
+	limiter := NewExplorerLimiter(2, time.Second)
+	assert.NoError(t, limiter.Wait(context.Background()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	err := limiter.Wait(ctx)
+	var rateLimited *ExplorerRateLimitedError
+	assert.True(t, errors.As(err, &rateLimited))
+}
+
+func TestExplorerLimiterReportsCancellation(t *testing.T) {
+	limiter := NewExplorerLimiter(2, time.Second)
+	assert.NoError(t, limiter.Wait(context.Background()))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	err := limiter.Wait(ctx)
+	assert.ErrorIs(t, err, context.Canceled, "a cancelled request is not an explorer rate limit")
+	var rateLimited *ExplorerRateLimitedError
+	assert.False(t, errors.As(err, &rateLimited))
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, limiter.Wait(cancelled), context.Canceled)
+}
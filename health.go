@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// heimdallPingTimeout bounds the Heimdall reachability check of the readiness
+// probe, which must answer quickly.
+const heimdallPingTimeout = 5 * time.Second
+
+// getReadiness reports whether the service can serve verified ABIs: at least
+// one chain that needs an explorer API key must have it set. With
+// HEALTH_CHECK_HEIMDALL enabled, Heimdall must also be reachable. It responds
+// with 503 and the details when degraded.
+func getReadiness(c *gin.Context) {
+	chains := chainStatuses(abiFetcher.etherscanAPIs)
+	ready := !missingAllAPIKeys(chains)
+	response := gin.H{"chains": chains}
+
+	if envBool("HEALTH_CHECK_HEIMDALL", false) {
+		if err := pingHeimdall(c.Request.Context(), abiFetcher.config.HeimdallURL); err != nil {
+			ready = false
+			response["heimdall"] = gin.H{"status": "down", "error": err.Error()}
+		} else {
+			response["heimdall"] = gin.H{"status": "up"}
+		}
+	}
+
+	if ready {
+		response["status"] = "ready"
+		respond(c, http.StatusOK, response)
+		return
+	}
+	response["status"] = "degraded"
+	respond(c, http.StatusServiceUnavailable, response)
+}
+
+// missingAllAPIKeys reports whether chains need API keys but none is set.
+func missingAllAPIKeys(chains []ChainStatus) bool {
+	required := false
+	for _, chain := range chains {
+		if chain.APIKeySet {
+			return false
+		}
+		required = required || chain.APIKeyRequired
+	}
+	return required
+}
+
+// pingHeimdall checks that the Heimdall API at baseURL answers without a
+// server error.
+func pingHeimdall(ctx context.Context, baseURL string) error {
+	ctx, cancel := context.WithTimeout(ctx, heimdallPingTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := heimdallHTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("heimdall responded with %s", resp.Status)
+	}
+	return nil
+}
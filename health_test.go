@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadiness(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	previous := abiFetcher
+	defer func() { abiFetcher = previous }()
+	abiFetcher = NewABIFetcher(NewABIStorage(time.Hour, 0), map[int]ChainAPI{
+		1:   &GenericEtherscanAPI{BaseURL: "https://api.etherscan.io/api", EnvKey: "READINESS_TEST_ETHEREUM_KEY"},
+		10:  &GenericEtherscanAPI{BaseURL: "https://api-optimistic.etherscan.io/api", EnvKey: "READINESS_TEST_OPTIMISM_KEY"},
+		100: &BlockscoutAPI{BaseURL: "https://gnosis.blockscout.com"},
+	}, DefaultFetcherConfig())
+
+	router := gin.New()
+	router.GET("/health/ready", getReadiness)
+	check := func() (int, map[string]interface{}) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/health/ready", nil)
+		router.ServeHTTP(w, req)
+		var response map[string]interface{}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return w.Code, response
+	}
+
+	t.Setenv("READINESS_TEST_ETHEREUM_KEY", "")
+	t.Setenv("READINESS_TEST_OPTIMISM_KEY", "")
+	status, response := check()
+	assert.Equal(t, http.StatusServiceUnavailable, status)
+	assert.Equal(t, "degraded", response["status"])
+	assert.Len(t, response["chains"], 3)
+
+	t.Setenv("READINESS_TEST_OPTIMISM_KEY", "key")
+	status, response = check()
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "ready", response["status"])
+	chains := response["chains"].([]interface{})
+	assert.Equal(t, map[string]interface{}{
+		"id": float64(10), "type": "etherscan", "baseUrl": "https://api-optimistic.etherscan.io/api",
+		"envKey": "READINESS_TEST_OPTIMISM_KEY", "apiKeyRequired": true, "apiKeySet": true,
+	}, chains[1])
+	assert.Equal(t, false, chains[0].(map[string]interface{})["apiKeySet"])
+	assert.NotContains(t, response, "heimdall")
+}
+
+func TestPingHeimdall(t *testing.T) {
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer up.Close()
+	assert.NoError(t, pingHeimdall(context.Background(), up.URL))
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer failing.Close()
+	assert.Error(t, pingHeimdall(context.Background(), failing.URL))
+
+	failing.Close()
+	assert.Error(t, pingHeimdall(context.Background(), failing.URL))
+}
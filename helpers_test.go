@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// rpcMessage is a JSON-RPC request as a fake node receives it.
+type rpcMessage struct {
+	ID     json.RawMessage   `json:"id"`
+	Method string            `json:"method"`
+	Params []json.RawMessage `json:"params"`
+}
+
+type rpcReply struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string { return e.Message }
+
+// errExecutionReverted is how a node answers a call that reverts.
+var errExecutionReverted = &rpcError{Code: 3, Message: "execution reverted"}
+
+// rpcHandler answers a JSON-RPC method given its params. A returned
+// *rpcError is sent as is, other errors as a generic server error, and a nil
+// result as null.
+type rpcHandler func(params []json.RawMessage) (interface{}, error)
+
+// fakeNode is a JSON-RPC node answering the methods it has handlers for,
+// singly or in batches. Other methods return "0x0". Every HTTP round trip is
+// counted.
+type fakeNode struct {
+	*httptest.Server
+	handlers   map[string]rpcHandler
+	roundTrips atomic.Int64
+}
+
+func newFakeNode(tb testing.TB, handlers map[string]rpcHandler) *fakeNode {
+	return newSlowFakeNode(tb, 0, handlers)
+}
+
+// newSlowFakeNode returns a fakeNode whose every round trip takes latency.
+func newSlowFakeNode(tb testing.TB, latency time.Duration, handlers map[string]rpcHandler) *fakeNode {
+	node := &fakeNode{handlers: handlers}
+	node.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		node.roundTrips.Add(1)
+		time.Sleep(latency)
+		var body json.RawMessage
+		json.NewDecoder(r.Body).Decode(&body)
+		if strings.HasPrefix(strings.TrimSpace(string(body)), "[") {
+			var batch []rpcMessage
+			json.Unmarshal(body, &batch)
+			replies := make([]rpcReply, len(batch))
+			for i, msg := range batch {
+				replies[i] = node.handle(msg)
+			}
+			json.NewEncoder(w).Encode(replies)
+			return
+		}
+		var msg rpcMessage
+		json.Unmarshal(body, &msg)
+		json.NewEncoder(w).Encode(node.handle(msg))
+	}))
+	tb.Cleanup(node.Close)
+	return node
+}
+
+func (n *fakeNode) handle(msg rpcMessage) rpcReply {
+	reply := rpcReply{JSONRPC: "2.0", ID: msg.ID}
+	handler, ok := n.handlers[msg.Method]
+	if !ok {
+		reply.Result = "0x0"
+		return reply
+	}
+	result, err := handler(msg.Params)
+	var rpcErr *rpcError
+	switch {
+	case errors.As(err, &rpcErr):
+		reply.Error = rpcErr
+	case err != nil:
+		reply.Error = &rpcError{Code: -32000, Message: err.Error()}
+	case result == nil:
+		reply.Result = json.RawMessage("null")
+	default:
+		reply.Result = result
+	}
+	return reply
+}
+
+// newContractNode returns a node on which every address holds code distinct
+// from that of other addresses and no proxy storage, so lookups run the whole
+// pipeline without a chain. Calls revert.
+func newContractNode(tb testing.TB) *fakeNode {
+	return newFakeNode(tb, contractNodeHandlers())
+}
+
+// contractNodeHandlers are the handlers of newContractNode, for nodes
+// answering some calls on top.
+func contractNodeHandlers() map[string]rpcHandler {
+	return map[string]rpcHandler{
+		"eth_getCode": func(params []json.RawMessage) (interface{}, error) {
+			address := rpcParam[common.Address](params, 0)
+			return hexutil.Bytes(append([]byte{0x60, 0x80}, address.Bytes()...)), nil
+		},
+		"eth_getStorageAt": func(params []json.RawMessage) (interface{}, error) {
+			return common.Hash{}, nil
+		},
+		"eth_call": func(params []json.RawMessage) (interface{}, error) {
+			return nil, errExecutionReverted
+		},
+	}
+}
+
+// newTestFetcher returns a fetcher over in-memory storage that may reach fake
+// nodes on loopback addresses. Sourcify knows no contract unless config names
+// another server, and nothing is logged unless config sets a Logger.
+func newTestFetcher(tb testing.TB, config FetcherConfig, chains map[int]ChainAPI) *ABIFetcher {
+	config.AllowPrivateRPC = true
+	if config.SourcifyURL == defaultSourcifyURL {
+		sourcify := httptest.NewServer(http.NotFoundHandler())
+		tb.Cleanup(sourcify.Close)
+		config.SourcifyURL = sourcify.URL
+	}
+	if config.Logger == nil {
+		config.Logger = nopLogger{}
+	}
+	if chains == nil {
+		chains = map[int]ChainAPI{}
+	}
+	return NewABIFetcher(NewABIStorage(time.Hour, 0), chains, config)
+}
+
+// rpcParam decodes the i-th param of a request, or returns the zero value
+// when there is none.
+func rpcParam[T any](params []json.RawMessage, i int) T {
+	var value T
+	if i < len(params) {
+		json.Unmarshal(params[i], &value)
+	}
+	return value
+}
+
+// rpcCall is the call object of an eth_call request.
+type rpcCall struct {
+	To    common.Address `json:"to"`
+	Data  hexutil.Bytes  `json:"data"`
+	Input hexutil.Bytes  `json:"input"`
+}
+
+// calldata returns the call's input, which clients send as input or data.
+func (c rpcCall) calldata() []byte {
+	if len(c.Input) > 0 {
+		return c.Input
+	}
+	return c.Data
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/gin-gonic/gin"
+)
+
+// UpgradedEventTopic is topic0 of the EIP-1967 Upgraded(address indexed implementation) event.
+var UpgradedEventTopic = crypto.Keccak256Hash([]byte("Upgraded(address)"))
+
+// LogReader is the subset of the ethclient API used to read upgrade history.
+type LogReader interface {
+	BlockNumber(ctx context.Context) (uint64, error)
+	FilterLogs(ctx context.Context, q ethereum.FilterQuery) ([]types.Log, error)
+}
+
+// implementationPeriod is a block range during which a proxy pointed at an
+// implementation. ToBlock is nil for the implementation that is still active.
+type implementationPeriod struct {
+	Address   common.Address
+	FromBlock uint64
+	ToBlock   *uint64
+}
+
+// implementationHistory reconstructs a proxy's implementations from its
+// Upgraded events in the last blockRange blocks, keeping at most the
+// maxImplementations most recent ones. Since nodes limit the range of a log
+// query, the blocks are searched backwards in chunks of chunkSize blocks,
+// stopping once enough upgrades were found.
+func implementationHistory(ctx context.Context, client LogReader, proxy common.Address, blockRange uint64, chunkSize uint64, maxImplementations int) ([]implementationPeriod, error) {
+	latest, err := client.BlockNumber(ctx)
+	if err != nil {
+		return nil, err
+	}
+	fromBlock := uint64(0)
+	if latest > blockRange {
+		fromBlock = latest - blockRange
+	}
+	chunkSize = max(chunkSize, 1)
+
+	var logs []types.Log
+	for toBlock := latest; len(logs) < maxImplementations; toBlock -= chunkSize {
+		chunkStart := fromBlock
+		if toBlock-fromBlock >= chunkSize {
+			chunkStart = toBlock - chunkSize + 1
+		}
+		chunk, err := client.FilterLogs(ctx, ethereum.FilterQuery{
+			FromBlock: new(big.Int).SetUint64(chunkStart),
+			ToBlock:   new(big.Int).SetUint64(toBlock),
+			Addresses: []common.Address{proxy},
+			Topics:    [][]common.Hash{{UpgradedEventTopic}},
+		})
+		if err != nil {
+			return nil, err
+		}
+		logs = append(logs, chunk...)
+		if chunkStart == fromBlock {
+			break
+		}
+	}
+	sort.SliceStable(logs, func(i, j int) bool {
+		if logs[i].BlockNumber != logs[j].BlockNumber {
+			return logs[i].BlockNumber < logs[j].BlockNumber
+		}
+		return logs[i].Index < logs[j].Index
+	})
+
+	var periods []implementationPeriod
+	for _, log := range logs {
+		if len(log.Topics) < 2 {
+			continue
+		}
+		if len(periods) > 0 {
+			toBlock := log.BlockNumber
+			if toBlock > periods[len(periods)-1].FromBlock {
+				toBlock--
+			}
+			periods[len(periods)-1].ToBlock = &toBlock
+		}
+		periods = append(periods, implementationPeriod{
+			Address:   common.BytesToAddress(log.Topics[1].Bytes()),
+			FromBlock: log.BlockNumber,
+		})
+	}
+
+	if len(periods) > maxImplementations {
+		periods = periods[len(periods)-maxImplementations:]
+	}
+	return periods, nil
+}
+
+// FetchHistory returns the current ABI of a proxy together with the ABIs of
+// its historical implementations and the blocks during which each was active.
+func (af *ABIFetcher) FetchHistory(c *gin.Context, chainId string, address string, rpcURL string) (gin.H, error) {
+	if err := af.checkChainServed(chainId); err != nil {
+		return nil, err
+	}
+	address, err := validateInput(chainId, address, rpcURL)
+	if err != nil {
+		return nil, err
+	}
+
+	cacheKey := chainId + "-" + address
+	if cached, ok := af.historyCache.Get(cacheKey); ok {
+		return cached, nil
+	}
+
+	response, err := af.FetchABI(c, chainId, address, rpcURL, FetchOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := af.dial(c.Request.Context(), rpcURL)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	periods, err := implementationHistory(c.Request.Context(), client, common.HexToAddress(address), af.config.HistoryBlockRange, af.config.HistoryChunkSize, af.config.HistoryMaxImplementations)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read upgrade history: %w", err)
+	}
+
+	implementations := make([]gin.H, 0, len(periods))
+	for _, period := range periods {
+		entry := gin.H{
+			"address":   period.Address.Hex(),
+			"fromBlock": period.FromBlock,
+			"toBlock":   period.ToBlock,
+		}
+		fetched, err := af.getABI(c.Request.Context(), chainId, period.Address.Hex(), rpcURL, nil, af.decompile(FetchOptions{}))
+		if err != nil {
+			entry["error"] = err.Error()
+		} else {
+			entry["abi"] = fetched.ABI
+			entry["isDecompiled"] = fetched.IsDecompiled
+		}
+		implementations = append(implementations, entry)
+	}
+	response["implementations"] = implementations
+
+	af.historyCache.Set(cacheKey, response)
+	return response, nil
+}
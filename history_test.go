@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockLogReader struct {
+	latest  uint64
+	logs    []types.Log
+	queries []ethereum.FilterQuery
+}
+
+func (m *mockLogReader) BlockNumber(ctx context.Context) (uint64, error) {
+	return m.latest, nil
+}
+
+func (m *mockLogReader) FilterLogs(ctx context.Context, q ethereum.FilterQuery) ([]types.Log, error) {
+	m.queries = append(m.queries, q)
+	var logs []types.Log
+	for _, log := range m.logs {
+		if log.BlockNumber >= q.FromBlock.Uint64() && log.BlockNumber <= q.ToBlock.Uint64() {
+			logs = append(logs, log)
+		}
+	}
+	return logs, nil
+}
+
+// queriedRanges returns the block ranges of the log queries made so far.
+func (m *mockLogReader) queriedRanges() [][2]uint64 {
+	var ranges [][2]uint64
+	for _, q := range m.queries {
+		ranges = append(ranges, [2]uint64{q.FromBlock.Uint64(), q.ToBlock.Uint64()})
+	}
+	return ranges
+}
+
+func upgradedLog(block uint64, implementation common.Address) types.Log {
+	return types.Log{
+		BlockNumber: block,
+		Topics:      []common.Hash{UpgradedEventTopic, common.BytesToHash(implementation.Bytes())},
+	}
+}
+
+func TestImplementationHistory(t *testing.T) {
+	proxy := common.HexToAddress("0x1000000000000000000000000000000000000001")
+	first := common.HexToAddress("0x2000000000000000000000000000000000000002")
+	second := common.HexToAddress("0x3000000000000000000000000000000000000003")
+	third := common.HexToAddress("0x4000000000000000000000000000000000000004")
+
+	client := &mockLogReader{
+		latest: 5000,
+		logs:   []types.Log{upgradedLog(3000, third), upgradedLog(1000, first), upgradedLog(2000, second)},
+	}
+
+	periods, err := implementationHistory(context.Background(), client, proxy, 4500, 2000, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, [][2]uint64{{3001, 5000}, {1001, 3000}, {500, 1000}}, client.queriedRanges(), "the bounded range is searched backwards in chunks")
+	assert.Len(t, periods, 3)
+
+	assert.Equal(t, first, periods[0].Address)
+	assert.Equal(t, uint64(1000), periods[0].FromBlock)
+	assert.Equal(t, uint64(1999), *periods[0].ToBlock)
+	assert.Equal(t, second, periods[1].Address)
+	assert.Equal(t, uint64(2999), *periods[1].ToBlock)
+	assert.Equal(t, third, periods[2].Address)
+	assert.Nil(t, periods[2].ToBlock, "the current implementation is still active")
+
+	client.queries = nil
+	periods, err = implementationHistory(context.Background(), client, proxy, 4500, 2000, 2)
+	assert.NoError(t, err)
+	assert.Len(t, periods, 2, "only the most recent implementations are kept")
+	assert.Equal(t, second, periods[0].Address)
+	assert.Equal(t, uint64(2999), *periods[0].ToBlock)
+	assert.Len(t, client.queries, 2, "the search stops once enough upgrades were found")
+
+	client.queries = nil
+	_, err = implementationHistory(context.Background(), client, proxy, 10_000, 2500, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, [][2]uint64{{2501, 5000}, {1, 2500}, {0, 0}}, client.queriedRanges(), "the search stops at the genesis block")
+}
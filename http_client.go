@@ -0,0 +1,54 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"time"
+)
+
+// Outbound HTTP clients. Requests to explorers and Sourcify are quick lookups,
+// while Heimdall decompiles the contract before answering, so it gets its own,
+// longer timeout. main adjusts them from the environment.
+var (
+	explorerHTTPClient = &http.Client{Timeout: 10 * time.Second}
+	heimdallHTTPClient = &http.Client{Timeout: 30 * time.Second}
+	// ipfsHTTPClient fetches contract metadata from an IPFS gateway, which
+	// may search the network for a long time for content nobody pinned, so
+	// it gives up sooner than the explorer client.
+	ipfsHTTPClient = &http.Client{Timeout: 5 * time.Second}
+	// webhookHTTPClient delivers upgrade notifications of watched proxies.
+	webhookHTTPClient = &http.Client{Timeout: 10 * time.Second}
+)
+
+// maxResponseBodySize bounds how many bytes are read from an upstream response
+// body, so a misbehaving upstream cannot exhaust memory. Even the largest ABIs
+// are a fraction of it. main adjusts it from the environment.
+var maxResponseBodySize int64 = 4 << 20
+
+// limitBody wraps an upstream response body so that reading more than
+// maxResponseBodySize bytes from it fails with *ResponseTooLargeError.
+func limitBody(body io.Reader) io.Reader {
+	return &limitedBody{r: body, limit: maxResponseBodySize, remaining: maxResponseBodySize}
+}
+
+type limitedBody struct {
+	r         io.Reader
+	limit     int64
+	remaining int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	// Reading one byte beyond the limit tells a body of exactly the limit
+	// apart from a larger one.
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+	n, err := b.r.Read(p)
+	if int64(n) > b.remaining {
+		n = int(b.remaining)
+		b.remaining = 0
+		return n, &ResponseTooLargeError{limit: b.limit}
+	}
+	b.remaining -= int64(n)
+	return n, err
+}
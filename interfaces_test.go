@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func supportsInterfaceCall(interfaceID string) string {
+	return supportsInterfaceSelector + common.Bytes2Hex(common.RightPadBytes(common.FromHex(interfaceID), 32))
+}
+
+func TestDetectInterfaces(t *testing.T) {
+	nft := common.HexToAddress("0x1000000000000000000000000000000000000001")
+	plain := common.HexToAddress("0x2000000000000000000000000000000000000002")
+	yesMan := common.HexToAddress("0x3000000000000000000000000000000000000003")
+	client := newMockContractReader()
+	for _, id := range []string{"0x01ffc9a7", "0x80ac58cd", "0x5b5e139f", "0x2a55205a"} {
+		client.setCallResult(nft, supportsInterfaceCall(id), common.LeftPadBytes([]byte{1}, 32))
+	}
+	client.setCallResult(nft, supportsInterfaceCall("0xffffffff"), make([]byte, 32))
+	client.setCallResult(nft, supportsInterfaceCall("0xd9b67a26"), make([]byte, 32))
+	for _, known := range append(knownInterfaces, struct{ Name, ID string }{"", "0xffffffff"}) {
+		client.setCallResult(yesMan, supportsInterfaceCall(known.ID), common.LeftPadBytes([]byte{1}, 32))
+	}
+
+	assert.Equal(t, []string{"ERC165", "ERC721", "ERC721Metadata", "ERC2981"}, detectInterfaces(context.Background(), client, nft))
+	assert.Equal(t, []string{}, detectInterfaces(context.Background(), client, plain), "calls to contracts without ERC-165 revert")
+	assert.Equal(t, []string{}, detectInterfaces(context.Background(), client, yesMan), "contracts claiming 0xffffffff do not implement ERC-165")
+}
+
+func TestFetchABIDetectInterfaces(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	node := newContractNode(t)
+	fetcher := newTestFetcher(t, DefaultFetcherConfig(), nil)
+	address := "0x1000000000000000000000000000000000000001"
+	fetcher.storage.Set("1-"+address, StorageItem{ABI: "[]"})
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("GET", "/", nil)
+	response, err := fetcher.FetchABI(c, "1", address, node.URL, FetchOptions{DetectInterfaces: true})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{}, response["interfaces"])
+
+	response, err = fetcher.FetchABI(c, "1", address, node.URL, FetchOptions{})
+	assert.NoError(t, err)
+	assert.NotContains(t, response, "interfaces")
+}
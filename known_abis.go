@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"os"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// maxLearnedABIs bounds how many ABIs KnownABIs learns from verified
+// contracts, so the database cannot grow without limit.
+const maxLearnedABIs = 10_000
+
+// KnownABI is the verified ABI of contracts with a given runtime bytecode.
+type KnownABI struct {
+	CodeHash common.Hash     `json:"codeHash"`
+	Name     string          `json:"name"`
+	ABI      json.RawMessage `json:"abi"`
+}
+
+// KnownABIs maps runtime bytecode hashes to verified ABIs. Unverified
+// contracts are often exact clones of verified ones, such as token templates,
+// and matching their bytecode yields the real ABI instead of a decompiled
+// approximation. Entries come from a JSON file and from every verified
+// contract whose bytecode the fetcher has seen.
+type KnownABIs struct {
+	mu      sync.RWMutex
+	entries map[common.Hash]KnownABI
+	learned int
+}
+
+func NewKnownABIs() *KnownABIs {
+	return &KnownABIs{entries: make(map[common.Hash]KnownABI)}
+}
+
+// loadKnownABIs reads the JSON array of KnownABI entries at path. A missing or
+// invalid file is logged and yields an empty database.
+func loadKnownABIs(path string) *KnownABIs {
+	known := NewKnownABIs()
+	if path == "" {
+		return known
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		slog.Warn("failed to read known ABIs", "path", path, "error", err)
+		return known
+	}
+	var entries []KnownABI
+	if err := json.Unmarshal(data, &entries); err != nil {
+		slog.Warn("failed to parse known ABIs", "path", path, "error", err)
+		return known
+	}
+	for i, entry := range entries {
+		if entry.CodeHash == (common.Hash{}) || len(entry.ABI) == 0 {
+			slog.Warn("skipping known ABI entry: codeHash and abi are required", "entry", i)
+			continue
+		}
+		// The ABI may be given as a JSON array or as a JSON-encoded string.
+		var abi string
+		if json.Unmarshal(entry.ABI, &abi) == nil {
+			entry.ABI = json.RawMessage(abi)
+		}
+		known.entries[entry.CodeHash] = entry
+	}
+	return known
+}
+
+// Lookup returns the known ABI of contracts with runtime bytecode code.
+func (k *KnownABIs) Lookup(code []byte) (KnownABI, bool) {
+	if len(code) == 0 {
+		return KnownABI{}, false
+	}
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	entry, ok := k.entries[crypto.Keccak256Hash(code)]
+	return entry, ok
+}
+
+// Learn records the verified ABI of a contract with runtime bytecode code,
+// unless the bytecode is already known or the learned entries are full.
+func (k *KnownABIs) Learn(code []byte, name string, abi string) {
+	if len(code) == 0 {
+		return
+	}
+	hash := crypto.Keccak256Hash(code)
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if _, ok := k.entries[hash]; ok || k.learned >= maxLearnedABIs {
+		return
+	}
+	k.entries[hash] = KnownABI{CodeHash: hash, Name: name, ABI: json.RawMessage(abi)}
+	k.learned++
+}
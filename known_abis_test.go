@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadKnownABIs(t *testing.T) {
+	token := []byte{0x60, 0x80, 0x60, 0x40, 0x01}
+	other := []byte{0x60, 0x80, 0x60, 0x40, 0x02}
+	path := filepath.Join(t.TempDir(), "known_abis.json")
+	config := `[
+  {"codeHash": "` + crypto.Keccak256Hash(token).Hex() + `", "name": "Token", "abi": [{"type":"function","name":"transfer"}]},
+  {"codeHash": "` + crypto.Keccak256Hash(other).Hex() + `", "name": "Other", "abi": "[{\"type\":\"fallback\"}]"},
+  {"name": "MissingHash", "abi": []}
+]`
+	assert.NoError(t, os.WriteFile(path, []byte(config), 0o600))
+
+	known := loadKnownABIs(path)
+	entry, ok := known.Lookup(token)
+	assert.True(t, ok)
+	assert.Equal(t, "Token", entry.Name)
+	assert.JSONEq(t, `[{"type":"function","name":"transfer"}]`, string(entry.ABI))
+
+	entry, ok = known.Lookup(other)
+	assert.True(t, ok)
+	assert.JSONEq(t, `[{"type":"fallback"}]`, string(entry.ABI), "string ABIs are decoded")
+
+	_, ok = known.Lookup([]byte{0x00})
+	assert.False(t, ok)
+	_, ok = known.Lookup(nil)
+	assert.False(t, ok)
+
+	assert.Empty(t, loadKnownABIs(filepath.Join(t.TempDir(), "missing.json")).entries)
+}
+
+func TestKnownABIsLearn(t *testing.T) {
+	known := NewKnownABIs()
+	code := []byte{0x60, 0x80}
+	known.Learn(code, "Token", `[{"type":"function","name":"a"}]`)
+	known.Learn(code, "Other", `[{"type":"function","name":"b"}]`)
+	entry, ok := known.Lookup(code)
+	assert.True(t, ok)
+	assert.Equal(t, "Token", entry.Name, "known bytecode is not overwritten")
+	assert.Equal(t, crypto.Keccak256Hash(code), entry.CodeHash)
+
+	known.Learn(nil, "Empty", "[]")
+	assert.Len(t, known.entries, 1)
+}
+
+func TestGetABIMatchesKnownBytecode(t *testing.T) {
+	heimdall := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("a bytecode match is not decompiled")
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer heimdall.Close()
+	sourcify := httptest.NewServer(http.NotFoundHandler())
+	defer sourcify.Close()
+
+	config := DefaultFetcherConfig()
+	config.HeimdallURL = heimdall.URL
+	config.SourcifyURL = sourcify.URL
+	verified := "0x1000000000000000000000000000000000000001"
+	clone := "0x2000000000000000000000000000000000000002"
+	code := []byte{0x60, 0x80, 0x60, 0x40}
+	fetcher := NewABIFetcher(NewABIStorage(time.Hour, 0), map[int]ChainAPI{
+		1: staticChainAPI{verified: `[{"type":"function","name":"transfer"}]`},
+	}, config)
+
+	// Fetching the verified contract teaches the fetcher its bytecode.
+	_, err := fetcher.getABI(context.Background(), "1", verified, "rpc.example/eth", code, true)
+	assert.NoError(t, err)
+
+	fetched, err := fetcher.getABI(context.Background(), "1", clone, "rpc.example/eth", code, true)
+	assert.NoError(t, err)
+	assert.Equal(t, SourceBytecode, fetched.Source)
+	assert.False(t, fetched.IsDecompiled)
+	assert.JSONEq(t, `[{"type":"function","name":"transfer"}]`, fetched.ABI)
+	assert.Equal(t, "bytecode_match", fetched.Metadata.VerificationStatus)
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestIDHeader carries the ID that ties together all log lines of a request.
+const RequestIDHeader = "X-Request-ID"
+
+// Logger is the leveled, structured logger the service writes to. Its
+// arguments are alternating keys and values, as with slog.
+type Logger interface {
+	Debug(msg string, args ...any)
+	Info(msg string, args ...any)
+	Warn(msg string, args ...any)
+	Error(msg string, args ...any)
+	// With returns a logger adding args to every line.
+	With(args ...any) Logger
+}
+
+// slogLogger is the Logger writing to a slog.Logger.
+type slogLogger struct {
+	*slog.Logger
+}
+
+// NewSlogLogger returns a Logger writing to logger.
+func NewSlogLogger(logger *slog.Logger) Logger {
+	return slogLogger{Logger: logger}
+}
+
+func (l slogLogger) With(args ...any) Logger {
+	return slogLogger{Logger: l.Logger.With(args...)}
+}
+
+// nopLogger discards everything, for tests that do not look at the logs.
+type nopLogger struct{}
+
+func (nopLogger) Debug(msg string, args ...any) {}
+func (nopLogger) Info(msg string, args ...any)  {}
+func (nopLogger) Warn(msg string, args ...any)  {}
+func (nopLogger) Error(msg string, args ...any) {}
+func (nopLogger) With(args ...any) Logger       { return nopLogger{} }
+
+type loggerKey struct{}
+
+// requestLogger returns the logger of the request ctx belongs to, or
+// fallback outside of requests.
+func requestLogger(ctx context.Context, fallback Logger) Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(Logger); ok {
+		return logger
+	}
+	return fallback
+}
+
+// withRequestLogger returns a copy of ctx carrying logger.
+func withRequestLogger(ctx context.Context, logger Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// requestLogging propagates the caller's X-Request-ID, or generates one,
+// attaches a logger derived from base and tagged with it to the request
+// context and logs every request once it completes.
+func requestLogging(base Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		requestID := c.GetHeader(RequestIDHeader)
+		if requestID == "" {
+			requestID = newRequestID()
+		}
+		c.Header(RequestIDHeader, requestID)
+
+		logger := base.With("requestId", requestID)
+		c.Request = c.Request.WithContext(withRequestLogger(c.Request.Context(), logger))
+
+		c.Next()
+
+		logger.Info("request",
+			"method", c.Request.Method,
+			"path", c.Request.URL.Path,
+			"status", c.Writer.Status(),
+			"clientIp", c.ClientIP(),
+			"duration", time.Since(start),
+		)
+	}
+}
+
+// newLogger creates the JSON logger of the service at the level named by
+// LOG_LEVEL (debug, info, warn or error; default info).
+func newLogger(w io.Writer) *slog.Logger {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(envString("LOG_LEVEL", "info"))); err != nil {
+		level = slog.LevelInfo
+	}
+	return slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level}))
+}
+
+// fatal logs err and exits, for failures the service cannot run with.
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
+}
+
+func newRequestID() string {
+	var id [16]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(id[:])
+}
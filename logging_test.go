@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestLogging(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logger, logs := captureLogs()
+
+	router := gin.New()
+	router.Use(requestLogging(logger))
+	router.GET("/", func(c *gin.Context) {
+		requestLogger(c.Request.Context(), nopLogger{}).Info("handled")
+		c.Status(http.StatusOK)
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.Header.Set(RequestIDHeader, "caller-id")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, "caller-id", w.Header().Get(RequestIDHeader), "a caller's request ID is propagated")
+
+	lines := strings.Split(strings.TrimSpace(logs.String()), "\n")
+	assert.Len(t, lines, 2)
+	for _, line := range lines {
+		var entry map[string]interface{}
+		assert.NoError(t, json.Unmarshal([]byte(line), &entry))
+		assert.Equal(t, "caller-id", entry["requestId"])
+	}
+	var access map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(lines[1]), &access))
+	assert.Equal(t, "request", access["msg"])
+	assert.Equal(t, float64(http.StatusOK), access["status"])
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/", nil)
+	router.ServeHTTP(w, req)
+	assert.Len(t, w.Header().Get(RequestIDHeader), 32, "a request ID is generated when missing")
+}
+
+func TestNewLoggerLevel(t *testing.T) {
+	var logs bytes.Buffer
+	t.Setenv("LOG_LEVEL", "warn")
+	logger := newLogger(&logs)
+	logger.Info("hidden")
+	logger.Warn("shown")
+	assert.NotContains(t, logs.String(), "hidden")
+	assert.Contains(t, logs.String(), "shown")
+}
+
+// captureLogs returns a logger writing its JSON lines to the returned buffer.
+func captureLogs() (Logger, *bytes.Buffer) {
+	var logs bytes.Buffer
+	return NewSlogLogger(slog.New(slog.NewJSONHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))), &logs
+}
+
+func TestFetcherLogsToItsLogger(t *testing.T) {
+	logger, logs := captureLogs()
+	config := DefaultFetcherConfig()
+	config.Logger = logger
+	fetcher := NewABIFetcher(NewABIStorage(time.Hour, 0), map[int]ChainAPI{}, config)
+
+	fetcher.log(context.Background()).Info("outside a request")
+	requestCtx := withRequestLogger(context.Background(), nopLogger{})
+	fetcher.log(requestCtx).Info("inside a request")
+
+	var entry map[string]interface{}
+	assert.NoError(t, json.Unmarshal(logs.Bytes(), &entry), "only the line logged outside a request is captured")
+	assert.Equal(t, "outside a request", entry["msg"])
+}
+
+func TestConfigurationWarningsAreLogged(t *testing.T) {
+	// Configuration is read at startup, before any fetcher and its logger
+	// exist, so its warnings go to the default logger.
+	var logs bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&logs, nil)))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+	t.Setenv("TEST_CONCURRENCY", "many")
+	assert.Equal(t, 4, envInt("TEST_CONCURRENCY", 4))
+
+	var entry map[string]interface{}
+	assert.NoError(t, json.Unmarshal(logs.Bytes(), &entry))
+	assert.Equal(t, "WARN", entry["level"])
+	assert.Equal(t, "TEST_CONCURRENCY", entry["key"])
+	assert.Equal(t, "many", entry["value"])
+	assert.Equal(t, float64(4), entry["default"])
+}
@@ -1,84 +1,620 @@
 package main
 
 import (
+	"context"
 	"errors"
-	"log"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
 	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
 )
 
 var (
-	storage       *ABIStorage
+	storage       StorageBackend
 	etherscanAPIs map[int]ChainAPI
 	abiFetcher    *ABIFetcher
 )
 
 var ErrABINotFound = errors.New("ABI not found")
 
-// TODO: Remove allow all origins
-
 func init() {
-	if err := godotenv.Load(); err != nil {
-		log.Println("No .env file found, using environment variables")
+	envFileErr := godotenv.Load()
+	// Set up first, so that configuration problems are logged in the same
+	// format and at the same level as everything else.
+	slog.SetDefault(newLogger(os.Stdout))
+	if envFileErr != nil {
+		slog.Info("no .env file found, using environment variables")
+	}
+
+	var err error
+	storage, err = storageFromEnv()
+	if err != nil {
+		fatal("failed to set up storage", err)
+	}
+
+	explorerHTTPClient.Timeout = envDuration("EXPLORER_HTTP_TIMEOUT", explorerHTTPClient.Timeout)
+	heimdallHTTPClient.Timeout = envDuration("HEIMDALL_HTTP_TIMEOUT", heimdallHTTPClient.Timeout)
+	ipfsHTTPClient.Timeout = envDuration("IPFS_HTTP_TIMEOUT", ipfsHTTPClient.Timeout)
+	maxResponseBodySize = int64(envInt("MAX_UPSTREAM_RESPONSE_SIZE", int(maxResponseBodySize)))
+	proxyDetectionBudget = make(chan struct{}, max(1, envInt("PROXY_DETECTION_CONCURRENCY", cap(proxyDetectionBudget))))
+	ImplementationGetters = append(ImplementationGetters, parseImplementationGetters(os.Getenv("PROXY_IMPLEMENTATION_GETTERS"))...)
+	proxyDetectionRetry = RetryPolicy{
+		MaxRetries: envInt("RPC_MAX_RETRIES", proxyDetectionRetry.MaxRetries),
+		BaseDelay:  envDuration("RPC_RETRY_BASE_DELAY", proxyDetectionRetry.BaseDelay),
 	}
 
-	storage = NewABIStorage()
+	etherscanAPIs = loadChainRegistry(os.Getenv("CHAINS_CONFIG"))
 
-	etherscanAPIs = make(map[int]ChainAPI)
-	etherscanAPIs[1] = &GenericEtherscanAPI{BaseURL: "https://api.etherscan.io/api", EnvKey: "ETHEREUM_API_KEY"}
-	etherscanAPIs[11155111] = &GenericEtherscanAPI{BaseURL: "https://api-sepolia.etherscan.io/api", EnvKey: "SEPOLIA_API_KEY"}
-	etherscanAPIs[10] = &GenericEtherscanAPI{BaseURL: "https://api-optimistic.etherscan.io/api", EnvKey: "OPTIMISM_API_KEY"}
-	etherscanAPIs[8453] = &GenericEtherscanAPI{BaseURL: "https://api.basescan.org/api", EnvKey: "BASE_API_KEY"}
-	etherscanAPIs[42161] = &GenericEtherscanAPI{BaseURL: "https://api.arbiscan.io/api", EnvKey: "ARBITRUM_API_KEY"}
-	etherscanAPIs[100] = &GenericEtherscanAPI{BaseURL: "https://api-gnosis.etherscan.io/api", EnvKey: "GNOSIS_API_KEY"}
-	etherscanAPIs[324] = &GenericEtherscanAPI{BaseURL: "https://block-explorer-api.mainnet.zksync.io/api", EnvKey: "ZKSYNC_API_KEY"}
-	etherscanAPIs[534352] = &GenericEtherscanAPI{BaseURL: "https://api.scrollscan.com/api", EnvKey: "SCROLL_API_KEY"}
-	etherscanAPIs[56] = &GenericEtherscanAPI{BaseURL: "https://api.bscscan.com/api", EnvKey: "BSC_API_KEY"}
-	etherscanAPIs[137] = &GenericEtherscanAPI{BaseURL: "https://api.polygonscan.com/api", EnvKey: "POLYGON_API_KEY"}
+	explorerRateLimit := envFloat("EXPLORER_RATE_LIMIT", 5)
+	explorerMaxWait := envDuration("EXPLORER_MAX_WAIT", 2*time.Second)
+	explorerRetry := RetryPolicy{
+		MaxRetries: envInt("EXPLORER_MAX_RETRIES", 3),
+		BaseDelay:  envDuration("EXPLORER_RETRY_BASE_DELAY", 500*time.Millisecond),
+	}
+	// Etherscan V2 chains share one API key and therefore its rate limit.
+	etherscanV2Limiters := make(map[string]*ExplorerLimiter)
+	for _, api := range etherscanAPIs {
+		switch api := api.(type) {
+		case *GenericEtherscanAPI:
+			api.Limiter = NewExplorerLimiter(explorerRateLimit, explorerMaxWait)
+			api.Retry = explorerRetry
+		case *EtherscanV2API:
+			if etherscanV2Limiters[api.EnvKey] == nil {
+				etherscanV2Limiters[api.EnvKey] = NewExplorerLimiter(explorerRateLimit, explorerMaxWait)
+			}
+			api.Limiter = etherscanV2Limiters[api.EnvKey]
+			api.Retry = explorerRetry
+		case *BlockscoutAPI:
+			api.Limiter = NewExplorerLimiter(explorerRateLimit, explorerMaxWait)
+		}
+	}
 
-	abiFetcher = NewABIFetcher(storage, etherscanAPIs)
+	abiFetcher = NewABIFetcher(storage, etherscanAPIs, fetcherConfigFromEnv())
 }
 
 func main() {
-	router := gin.Default()
+	apiKeys, err := loadAPIKeys(os.Getenv("API_KEYS"), os.Getenv("API_KEYS_FILE"))
+	if err != nil {
+		fatal("failed to load API keys", err)
+	}
+	shutdownTracing, err := setupTracing(context.Background())
+	if err != nil {
+		fatal("failed to set up tracing", err)
+	}
+	router := newRouter(apiKeys)
 
-	config := cors.DefaultConfig()
-	config.AllowAllOrigins = true
-	router.Use(cors.New(config))
+	server := &http.Server{Addr: ":8080", Handler: router}
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	abiFetcher.StopJobsOn(ctx)
+	abiFetcher.StartProxyWatcher(ctx)
+	if err := serve(ctx, server, envDuration("SHUTDOWN_TIMEOUT", 15*time.Second)); err != nil {
+		fatal("server failed", err)
+	}
+
+	flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := shutdownTracing(flushCtx); err != nil {
+		abiFetcher.logger.Error("failed to flush traces", "error", err)
+	}
+}
+
+// newRouter registers all routes, requiring one of apiKeys on those that are
+// not public.
+func newRouter(apiKeys []string) *gin.Engine {
+	router := gin.New()
+	// Clients are told apart by IP for rate limiting, so X-Forwarded-For is
+	// only honoured when sent by one of the configured proxies.
+	if err := router.SetTrustedProxies(envList("TRUSTED_PROXIES")); err != nil {
+		abiFetcher.logger.Warn("invalid TRUSTED_PROXIES, trusting no proxy", "error", err)
+		router.SetTrustedProxies(nil)
+	}
+	router.Use(tracing(), requestLogging(abiFetcher.logger), gin.Recovery())
+
+	router.Use(cors.New(corsConfig(os.Getenv("CORS_ALLOWED_ORIGINS"))))
 
 	router.GET("/", healthCheck)
-	router.GET("/abi/:chainId/:address/*rpcUrl", getABI)
+	router.GET("/health/ready", getReadiness)
+	router.GET("/openapi.json", serveOpenAPI(router))
+	router.GET("/docs", serveDocs)
 
-	log.Fatal(router.Run(":8080"))
+	api := router.Group("", requireAPIKey(apiKeys))
+	if envBool("RESPONSE_COMPRESSION", true) {
+		api.Use(gzipResponses())
+	}
+	// Routes that reach the explorers, the RPC nodes or Heimdall are rate
+	// limited per client.
+	limited := api.Group("")
+	if perMinute := envInt("RATE_LIMIT_PER_MINUTE", 60); perMinute > 0 {
+		limited.Use(NewClientRateLimiter(perMinute, envInt("RATE_LIMIT_BURST", 10)).Middleware())
+	}
+	abiRoutes := limited.Group("/abi")
+	abiRoutes.GET("/:chainId/:address", getABI)
+	abiRoutes.GET("/:chainId/:address/*rpcUrl", getABI)
+	abiRoutes.POST("", postABI)
+	abiRoutes.POST("/batch", getABIBatch)
+	abiRoutes.GET("/history/:chainId/:address/*rpcUrl", getABIHistory)
+	abiRoutes.GET("/diff/:chainId/:address", getABIDiff)
+	abiRoutes.GET("/diff/:chainId/:address/*rpcUrl", getABIDiff)
+	abiRoutes.GET("/tx/:chainId/:txHash", getABIByTx)
+	abiRoutes.GET("/tx/:chainId/:txHash/*rpcUrl", getABIByTx)
+	abiRoutes.GET("/raw/:chainId/:address", getRawABI)
+	abiRoutes.GET("/raw/:chainId/:address/*rpcUrl", getRawABI)
+
+	api.GET("/chains", getChains)
+	limited.GET("/proxy/:chainId/:address/*rpcUrl", getProxy)
+	limited.POST("/proxy/batch", getProxyBatch)
+	limited.GET("/bytecode/:chainId/:address/*rpcUrl", getBytecode)
+	api.GET("/stats", getStats)
+	api.GET("/cache/warm/:jobId", getWarmJob)
+	// Admin endpoints authenticate with ADMIN_TOKEN instead of an API key.
+	router.POST("/stats/reset", requireAdmin(), resetStats)
+	router.POST("/cache/warm", requireAdmin(), warmCache)
+	router.DELETE("/abi/:chainId/:address", requireAdmin(), deleteABI)
+	return router
+}
+
+// serve runs server until ctx is cancelled, then stops accepting connections,
+// waits up to timeout for in-flight requests to finish and releases the
+// storage backend.
+func serve(ctx context.Context, server *http.Server, timeout time.Duration) error {
+	serverErr := make(chan error, 1)
+	go func() {
+		abiFetcher.logger.Info("server listening", "addr", server.Addr)
+		serverErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serverErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	abiFetcher.logger.Info("shutting down server", "timeout", timeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		abiFetcher.logger.Error("server shutdown incomplete", "error", err)
+	} else {
+		abiFetcher.logger.Info("server stopped, all in-flight requests finished")
+	}
+
+	if closer, ok := storage.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			abiFetcher.logger.Error("failed to close storage", "error", err)
+		} else {
+			abiFetcher.logger.Info("storage closed")
+		}
+	}
+	abiFetcher.logger.Info("shutdown complete")
+	return nil
+}
+
+// corsConfig allows the comma-separated allowedOrigins to call the API, or
+// every origin when allowedOrigins is empty.
+func corsConfig(allowedOrigins string) cors.Config {
+	config := cors.DefaultConfig()
+	config.AllowMethods = []string{"GET", "POST", "DELETE", "OPTIONS"}
+	config.AddAllowHeaders("Authorization", RequestIDHeader, "traceparent", "tracestate")
+	config.AddExposeHeaders(RequestIDHeader, "Retry-After")
+	for _, origin := range strings.Split(allowedOrigins, ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			config.AllowOrigins = append(config.AllowOrigins, origin)
+		}
+	}
+	config.AllowAllOrigins = len(config.AllowOrigins) == 0
+	return config
 }
 
 func healthCheck(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{
+	respond(c, http.StatusOK, gin.H{
 		"status":  "healthy",
 		"message": "Get-ABI-2000 is up and running",
 	})
 }
 
 func getABI(c *gin.Context) {
+	// The rpcUrl is optional, the chain's default node is used without it.
+	if response, ok := fetchABIForRequest(c, c.Param("chainId"), c.Param("address"), rpcURLParam(c), false); ok {
+		respond(c, http.StatusOK, response)
+	}
+}
+
+// postABI fetches the ABI of the contract named in the JSON body, which
+// spares clients escaping an rpcUrl with slashes, ports or query strings
+// into the path.
+func postABI(c *gin.Context) {
+	var request BatchRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		respondWithError(c, &InvalidInputError{message: "Invalid body: expected a JSON object of {chainId, address, rpcUrl} strings", code: CodeInvalidBody})
+		return
+	}
+	var missing []string
+	if request.ChainID == "" {
+		missing = append(missing, "chainId")
+	}
+	if request.Address == "" {
+		missing = append(missing, "address")
+	}
+	if len(missing) > 0 {
+		respondWithError(c, &InvalidInputError{message: "Invalid body: missing " + strings.Join(missing, " and "), code: CodeInvalidBody})
+		return
+	}
+	if response, ok := fetchABIForRequest(c, request.ChainID, request.Address, request.RPCURL, false); ok {
+		respond(c, http.StatusOK, response)
+	}
+}
+
+// getRawABI responds with just the ABI array, without the fields around it,
+// so it can be piped into a file or abigen.
+func getRawABI(c *gin.Context) {
+	response, ok := fetchABIForRequest(c, c.Param("chainId"), c.Param("address"), rpcURLParam(c), true)
+	if !ok {
+		return
+	}
+	if _, isString := response["abi"].(string); isString {
+		abiFetcher.stats.errors.Add(1)
+		respondWithError(c, errors.New("the ABI is not a valid JSON array"))
+		return
+	}
+	respond(c, http.StatusOK, response["abi"])
+}
+
+// fetchABIForRequest fetches the ABI of address with the options in the
+// query of c, as a JSON array when structured is set. On failure it responds
+// with the error and reports false.
+func fetchABIForRequest(c *gin.Context, chainId string, address string, rpcURL string, structured bool) (gin.H, bool) {
+	opts, err := fetchOptionsFromQuery(c)
+	if err != nil {
+		respondWithError(c, err)
+		return nil, false
+	}
+	if structured {
+		opts.StructuredABI = true
+		// Only the ABI is returned, so the interfaces and candidates are not
+		// worth the RPC calls.
+		opts.DetectInterfaces = false
+		opts.Candidates = false
+	}
+
+	abiFetcher.stats.requests.Add(1)
+	response, err := abiFetcher.FetchABI(c, chainId, address, rpcURL, opts)
+	if err != nil {
+		abiFetcher.stats.errors.Add(1)
+		respondWithError(c, err)
+		return nil, false
+	}
+	return response, true
+}
+
+// fetchOptionsFromQuery reads the ABI response switches from the query string.
+func fetchOptionsFromQuery(c *gin.Context) (FetchOptions, error) {
+	opts := FetchOptions{
+		PostProcessors:     make(map[string]bool),
+		IncludeAdminOwner:  c.Query("includeAdminOwner") == "true",
+		IncludeInterfaceID: c.Query("interfaceId") == "true",
+		IncludeSelectors:   c.Query("includeSelectors") == "true",
+		Raw:                c.Query("raw") == "true",
+		Force:              c.Query("force") == "true",
+		MergeProxyABI:      c.Query("mergeProxyAbi") == "true",
+		SkipDecompilation:  c.Query("decompile") == "false",
+		VerifiedOnly:       c.Query("verifiedOnly") == "true",
+		DetectInterfaces:   c.Query("detectInterfaces") == "true",
+		IncludeCreation:    c.Query("includeCreation") == "true",
+		Candidates:         c.Query("candidates") == "true",
+	}
+	for _, processor := range abiFetcher.postProcessors {
+		opts.PostProcessors[processor.Name()] = c.Query(processor.Name()) == "true"
+	}
+	// Binary encodings embed the ABI as nested values by default rather than
+	// as a JSON string their clients would have to parse separately.
+	defaultFormat := "string"
+	if _, handle := binaryEncoding(c); handle != nil {
+		defaultFormat = "json"
+	}
+	switch format := c.DefaultQuery("format", defaultFormat); format {
+	case "string":
+	case "json":
+		opts.StructuredABI = true
+	default:
+		return FetchOptions{}, &InvalidInputError{message: "Invalid format: must be string or json", code: CodeInvalidFormat}
+	}
+	if block := c.Query("block"); block != "" {
+		number, ok := parseBlockNumber(block)
+		if !ok {
+			return FetchOptions{}, &InvalidInputError{message: "Invalid block: must be a non-negative block number", code: CodeInvalidBlock}
+		}
+		opts.Block = number
+	}
+	return opts, nil
+}
+
+// parseBlockNumber parses a non-negative decimal block number.
+func parseBlockNumber(block string) (*big.Int, bool) {
+	number, ok := new(big.Int).SetString(block, 10)
+	if !ok || number.Sign() < 0 {
+		return nil, false
+	}
+	return number, true
+}
+
+func getABIBatch(c *gin.Context) {
+	var requests []BatchRequest
+	if err := c.ShouldBindJSON(&requests); err != nil {
+		respondWithError(c, &InvalidInputError{message: "Invalid batch: expected a JSON array of {chainId, address, rpcUrl} objects", code: CodeInvalidBatch})
+		return
+	}
+	if len(requests) > abiFetcher.config.BatchMaxSize {
+		respondWithError(c, &InvalidInputError{message: fmt.Sprintf("Invalid batch: at most %d entries are allowed", abiFetcher.config.BatchMaxSize), code: CodeBatchTooLarge})
+		return
+	}
+
+	opts, err := fetchOptionsFromQuery(c)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	abiFetcher.stats.requests.Add(int64(len(requests)))
+	results := abiFetcher.FetchABIBatch(c, requests, opts)
+	response := make([]gin.H, len(results))
+	for i, result := range results {
+		if result.Err != nil {
+			abiFetcher.stats.errors.Add(1)
+			status, body := errorResponse(result.Err)
+			body["status"] = status
+			response[i] = body
+			continue
+		}
+		response[i] = result.Response
+	}
+
+	respond(c, http.StatusOK, response)
+}
+
+func warmCache(c *gin.Context) {
+	var requests []BatchRequest
+	if err := c.ShouldBindJSON(&requests); err != nil {
+		respondWithError(c, &InvalidInputError{message: "Invalid batch: expected a JSON array of {chainId, address, rpcUrl} objects", code: CodeInvalidBatch})
+		return
+	}
+	if len(requests) > abiFetcher.config.WarmMaxSize {
+		respondWithError(c, &InvalidInputError{message: fmt.Sprintf("Invalid batch: at most %d entries are allowed", abiFetcher.config.WarmMaxSize), code: CodeBatchTooLarge})
+		return
+	}
+
+	job := abiFetcher.WarmCache(requests)
+	respond(c, http.StatusAccepted, gin.H{"jobId": job.ID, "total": job.Total})
+}
+
+func getWarmJob(c *gin.Context) {
+	job, ok := abiFetcher.WarmJob(c.Param("jobId"))
+	if !ok {
+		respond(c, http.StatusNotFound, gin.H{"error": "Unknown cache warming job " + c.Param("jobId")})
+		return
+	}
+	respond(c, http.StatusOK, job.Progress())
+}
+
+func deleteABI(c *gin.Context) {
 	chainId := c.Param("chainId")
 	address := c.Param("address")
-	rpcURL := c.Param("rpcUrl")[1:]
 
-	response, err := abiFetcher.FetchABI(c, chainId, address, rpcURL)
+	deleted, err := abiFetcher.InvalidateABI(chainId, address)
 	if err != nil {
-		switch e := err.(type) {
-		case *InvalidInputError:
-			c.JSON(http.StatusBadRequest, gin.H{"error": e.Error()})
-		case *ContractNotFoundError:
-			c.JSON(http.StatusNotFound, gin.H{"error": e.Error()})
-		default:
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondWithError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, gin.H{"deleted": deleted})
+}
+
+func getABIHistory(c *gin.Context) {
+	chainId := c.Param("chainId")
+	address := c.Param("address")
+	rpcURL := rpcURLParam(c)
+
+	response, err := abiFetcher.FetchHistory(c, chainId, address, rpcURL)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, response)
+}
+
+func getABIDiff(c *gin.Context) {
+	chainId := c.Param("chainId")
+	address := c.Param("address")
+	rpcURL := rpcURLParam(c)
+
+	from, fromOK := parseBlockNumber(c.Query("from"))
+	to, toOK := parseBlockNumber(c.Query("to"))
+	if !fromOK || !toOK {
+		respondWithError(c, &InvalidInputError{message: "Invalid block: from and to must both be non-negative block numbers", code: CodeInvalidBlock})
+		return
+	}
+
+	response, err := abiFetcher.FetchABIDiff(c, chainId, address, rpcURL, from, to)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, response)
+}
+
+// getABIByTx fetches the ABI of the contract a transaction called, e.g. to
+// decode its calldata, which the response carries along.
+func getABIByTx(c *gin.Context) {
+	chainId := c.Param("chainId")
+	rpcURL := rpcURLParam(c)
+
+	to, calldata, err := abiFetcher.ResolveTransaction(c.Request.Context(), chainId, c.Param("txHash"), rpcURL)
+	if err != nil {
+		abiFetcher.stats.requests.Add(1)
+		abiFetcher.stats.errors.Add(1)
+		respondWithError(c, err)
+		return
+	}
+	if response, ok := fetchABIForRequest(c, chainId, to.Hex(), rpcURL, false); ok {
+		response["txHash"] = c.Param("txHash")
+		response["to"] = to.Hex()
+		response["input"] = hexutil.Encode(calldata)
+		respond(c, http.StatusOK, response)
+	}
+}
+
+func getBytecode(c *gin.Context) {
+	chainId := c.Param("chainId")
+	address := c.Param("address")
+	rpcURL := rpcURLParam(c)
+
+	response, err := abiFetcher.FetchBytecode(c, chainId, address, rpcURL)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, response)
+}
+
+func getProxy(c *gin.Context) {
+	chainId := c.Param("chainId")
+	address := c.Param("address")
+	rpcURL := rpcURLParam(c)
+
+	response, err := abiFetcher.FetchProxyInfo(c, chainId, address, rpcURL)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+
+	respond(c, http.StatusOK, response)
+}
+
+func getProxyBatch(c *gin.Context) {
+	var request ProxyBatchRequest
+	if err := c.ShouldBindJSON(&request); err != nil || request.Addresses == nil {
+		respondWithError(c, &InvalidInputError{message: "Invalid batch: expected a {chainId, rpcUrl, addresses} object", code: CodeInvalidBatch})
+		return
+	}
+	if len(request.Addresses) > abiFetcher.config.BatchMaxSize {
+		respondWithError(c, &InvalidInputError{message: fmt.Sprintf("Invalid batch: at most %d addresses are allowed", abiFetcher.config.BatchMaxSize), code: CodeBatchTooLarge})
+		return
+	}
+
+	results, err := abiFetcher.FetchProxyInfoBatch(c, request)
+	if err != nil {
+		respondWithError(c, err)
+		return
+	}
+	response := make([]gin.H, len(results))
+	for i, result := range results {
+		if result.Err != nil {
+			status, body := errorResponse(result.Err)
+			body["status"] = status
+			body["address"] = request.Addresses[i]
+			response[i] = body
+			continue
 		}
+		response[i] = result.Response
+	}
+
+	respond(c, http.StatusOK, response)
+}
+
+func getChains(c *gin.Context) {
+	served := make(map[int]ChainAPI)
+	for id, api := range abiFetcher.etherscanAPIs {
+		if abiFetcher.chainServed(id) {
+			served[id] = api
+		}
+	}
+	respond(c, http.StatusOK, chainStatuses(served))
+}
+
+func getStats(c *gin.Context) {
+	respond(c, http.StatusOK, abiFetcher.stats.Snapshot())
+}
+
+func resetStats(c *gin.Context) {
+	abiFetcher.stats.Reset()
+	respond(c, http.StatusOK, abiFetcher.stats.Snapshot())
+}
+
+// respond writes obj as CBOR or msgpack when the Accept header asks for it,
+// and otherwise as JSON, indented when the request asks for ?pretty=true.
+func respond(c *gin.Context, code int, obj interface{}) {
+	c.Writer.Header().Add("Vary", "Accept")
+	if mimeType, handle := binaryEncoding(c); handle != nil {
+		respondBinary(c, code, obj, mimeType, handle)
+		return
+	}
+	if c.Query("pretty") == "true" {
+		c.IndentedJSON(code, obj)
 		return
 	}
+	c.JSON(code, obj)
+}
+
+func respondWithError(c *gin.Context, err error) {
+	status, body := errorResponse(err)
+	respond(c, status, body)
+}
 
-	c.JSON(http.StatusOK, response)
+// errorResponse maps err to an HTTP status and JSON error body.
+func errorResponse(err error) (int, gin.H) {
+	var (
+		invalidInput *InvalidInputError
+		notFound     *ContractNotFoundError
+		unavailable  *ABIUnavailableError
+		notVerified  *VerifiedABINotFoundError
+		unverified   *UnverifiedABIError
+		ensErr       *ENSResolutionError
+		unresolvable *ProxyTargetUnresolvableError
+		txNotFound   *TransactionNotFoundError
+		creationTx   *ContractCreationTxError
+		invalidABI   *InvalidABIError
+	)
+	// Typed errors may be wrapped on their way up from the fetcher.
+	switch {
+	case errors.As(err, &invalidInput):
+		return http.StatusBadRequest, gin.H{"error": invalidInput.Error(), "code": invalidInput.code}
+	case errors.As(err, &notFound):
+		return http.StatusNotFound, gin.H{"error": notFound.Error(), "code": notFound.code}
+	case errors.As(err, &unavailable):
+		return http.StatusNotFound, gin.H{"error": unavailable.Error()}
+	case errors.As(err, &notVerified):
+		return http.StatusNotFound, gin.H{"error": notVerified.Error(), "verified": false}
+	case errors.As(err, &unverified):
+		return http.StatusNotFound, gin.H{"error": unverified.Error(), "code": CodeUnverified, "verified": false}
+	case errors.As(err, &ensErr):
+		return http.StatusNotFound, gin.H{"error": ensErr.Error(), "ensName": ensErr.name}
+	case errors.As(err, &unresolvable):
+		return http.StatusUnprocessableEntity, gin.H{"error": unresolvable.Error(), "proxyType": unresolvable.proxyType, "target": unresolvable.target}
+	case errors.As(err, &txNotFound):
+		return http.StatusNotFound, gin.H{"error": txNotFound.Error(), "txHash": txNotFound.hash}
+	case errors.As(err, &creationTx):
+		body := gin.H{"error": creationTx.Error(), "txHash": creationTx.hash}
+		if creationTx.contractAddress != "" {
+			body["contractAddress"] = creationTx.contractAddress
+		}
+		return http.StatusUnprocessableEntity, body
+	case errors.As(err, &invalidABI):
+		return http.StatusBadGateway, gin.H{"error": invalidABI.Error(), "source": invalidABI.source}
+	default:
+		return http.StatusInternalServerError, gin.H{"error": err.Error()}
+	}
 }
@@ -0,0 +1,497 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// routeDoc documents a route of the OpenAPI spec. The spec is generated from
+// the routes registered with gin, so every route appears in it; routeDocs
+// only adds what cannot be derived from the router.
+type routeDoc struct {
+	Summary     string
+	Description string
+	// Query lists the query parameters besides pretty, which all routes accept.
+	Query []queryParamDoc
+	// RequestBody is a value whose type describes the JSON request body.
+	RequestBody interface{}
+	// Status is the status of a successful response, 200 when zero.
+	Status int
+	// Response is the schema of a successful response.
+	Response gin.H
+	// Errors lists the error statuses the route may respond with.
+	Errors []int
+	// Public routes do not require an API key.
+	Public bool
+}
+
+type queryParamDoc struct {
+	Name        string
+	Description string
+	Enum        []string
+	// Type is the JSON schema type of the value, boolean when empty.
+	Type string
+}
+
+// abiQueryParams are the query parameters shared by the ABI routes.
+func abiQueryParams() []queryParamDoc {
+	params := []queryParamDoc{
+		{Name: "format", Description: "Return abi as a JSON-encoded string (default) or as a JSON array", Enum: []string{"string", "json"}},
+		{Name: "raw", Description: "Return the ABI exactly as the source delivered it"},
+		{Name: "includeSelectors", Description: "Add a selectors object mapping selectors and topic0 hashes to signatures"},
+		{Name: "interfaceId", Description: "Add the ERC-165 interface ID computed from the ABI"},
+		{Name: "mergeProxyAbi", Description: "Merge a proxy's own ABI into its implementation's"},
+		{Name: "force", Description: "Bypass the cache and refetch the ABI"},
+		{Name: "decompile", Description: "Set to false to only return a verified ABI, never a decompiled one"},
+		{Name: "verifiedOnly", Description: "Only return an ABI verified on the explorer or Sourcify, responding with 404 and code UNVERIFIED otherwise"},
+		{Name: "block", Description: "Resolve proxies at this block number instead of the latest block", Type: "integer"},
+		{Name: "includeAdminOwner", Description: "Add the owner of an ownable EIP-1967 proxy admin"},
+		{Name: "detectInterfaces", Description: "Add the standard interfaces the contract reports through ERC-165 supportsInterface"},
+		{Name: "includeCreation", Description: "Add the address that deployed the contract and the creation transaction, when the explorer knows them"},
+		{Name: "candidates", Description: "Add the ABI every source offers for the contract, each with its source and a confidence score"},
+	}
+	for _, processor := range defaultPostProcessors() {
+		params = append(params, queryParamDoc{Name: processor.Name(), Description: "Apply the " + processor.Name() + " post-processor to the ABI"})
+	}
+	return params
+}
+
+// rawABIQueryParams are the query parameters of the raw ABI routes, which
+// always return the ABI as a JSON array.
+func rawABIQueryParams() []queryParamDoc {
+	var params []queryParamDoc
+	for _, param := range abiQueryParams() {
+		if param.Name != "format" && param.Name != "detectInterfaces" && param.Name != "candidates" {
+			params = append(params, param)
+		}
+	}
+	return params
+}
+
+// abiResponseSchema is the schema of ABIResponse, in which the fields that
+// are always present are required.
+var abiResponseSchema = requireFields(schemaOf(reflect.TypeOf(ABIResponse{})), reflect.TypeOf(ABIResponse{}))
+
+var routeDocs = map[string]routeDoc{
+	"GET /": {
+		Summary:  "Liveness probe",
+		Response: objectSchema("status", "message"),
+		Public:   true,
+	},
+	"GET /health/ready": {
+		Summary:     "Readiness probe",
+		Description: "Reports whether a chain requiring an explorer API key has one, and optionally whether Heimdall is reachable.",
+		Response: gin.H{"type": "object", "properties": gin.H{
+			"status": gin.H{"type": "string", "enum": []string{"ready", "degraded"}},
+			"chains": gin.H{"type": "array", "items": schemaRef("ChainStatus")},
+		}},
+		Errors: []int{http.StatusServiceUnavailable},
+		Public: true,
+	},
+	"GET /openapi.json": {
+		Summary:  "This OpenAPI spec",
+		Response: gin.H{"type": "object"},
+		Public:   true,
+	},
+	"GET /docs": {
+		Summary:  "Swagger UI for this spec",
+		Response: gin.H{"type": "string", "format": "html"},
+		Public:   true,
+	},
+	"GET /abi/:chainId/:address": {
+		Summary:     "Fetch ABI using the chain's default RPC URL",
+		Description: "Fetches the verified ABI from the explorer or Sourcify, falling back to decompilation. Proxies resolve to their implementation's ABI.",
+		Query:       abiQueryParams(),
+		Response:    schemaRef("ABIResponse"),
+		Errors:      []int{http.StatusBadRequest, http.StatusNotFound, http.StatusUnprocessableEntity, http.StatusTooManyRequests, http.StatusInternalServerError},
+	},
+	"POST /abi": {
+		Summary:     "Fetch ABI of the contract in the body",
+		Description: "Like GET /abi/{chainId}/{address}/{rpcUrl}, with the contract given as a JSON body so the rpcUrl needs no escaping. rpcUrl may be omitted for chains with a default RPC URL.",
+		Query:       abiQueryParams(),
+		RequestBody: BatchRequest{},
+		Response:    schemaRef("ABIResponse"),
+		Errors:      []int{http.StatusBadRequest, http.StatusNotFound, http.StatusUnprocessableEntity, http.StatusTooManyRequests, http.StatusInternalServerError},
+	},
+	"GET /abi/:chainId/:address/*rpcUrl": {
+		Summary:     "Fetch ABI",
+		Description: "Fetches the verified ABI from the explorer or Sourcify, falling back to decompilation. Proxies resolve to their implementation's ABI. The rpcUrl may contain slashes.",
+		Query:       abiQueryParams(),
+		Response:    schemaRef("ABIResponse"),
+		Errors:      []int{http.StatusBadRequest, http.StatusNotFound, http.StatusUnprocessableEntity, http.StatusTooManyRequests, http.StatusInternalServerError},
+	},
+	"GET /abi/tx/:chainId/:txHash": {
+		Summary:     "Fetch the ABI of the contract a transaction called using the chain's default RPC URL",
+		Description: "Like GET /abi/tx/{chainId}/{txHash}/{rpcUrl}.",
+		Query:       abiQueryParams(),
+		Response:    abiByTxSchema,
+		Errors:      []int{http.StatusBadRequest, http.StatusNotFound, http.StatusUnprocessableEntity, http.StatusTooManyRequests, http.StatusInternalServerError},
+	},
+	"GET /abi/tx/:chainId/:txHash/*rpcUrl": {
+		Summary:     "Fetch the ABI of the contract a transaction called",
+		Description: "Looks up the transaction and fetches the ABI of its to address, adding the transaction's calldata as input. Contract creations respond with 422 and the created contractAddress.",
+		Query:       abiQueryParams(),
+		Response:    abiByTxSchema,
+		Errors:      []int{http.StatusBadRequest, http.StatusNotFound, http.StatusUnprocessableEntity, http.StatusTooManyRequests, http.StatusInternalServerError},
+	},
+	"GET /abi/raw/:chainId/:address": {
+		Summary:     "Fetch only the ABI using the chain's default RPC URL",
+		Description: "Like GET /abi/{chainId}/{address}, but responds with just the ABI array.",
+		Query:       rawABIQueryParams(),
+		Response:    gin.H{"type": "array", "items": gin.H{"type": "object"}},
+		Errors:      []int{http.StatusBadRequest, http.StatusNotFound, http.StatusUnprocessableEntity, http.StatusTooManyRequests, http.StatusInternalServerError},
+	},
+	"GET /abi/raw/:chainId/:address/*rpcUrl": {
+		Summary:     "Fetch only the ABI",
+		Description: "Like GET /abi/{chainId}/{address}/{rpcUrl}, but responds with just the ABI array, e.g. to pipe into abigen.",
+		Query:       rawABIQueryParams(),
+		Response:    gin.H{"type": "array", "items": gin.H{"type": "object"}},
+		Errors:      []int{http.StatusBadRequest, http.StatusNotFound, http.StatusUnprocessableEntity, http.StatusTooManyRequests, http.StatusInternalServerError},
+	},
+	"POST /cache/warm": {
+		Summary:     "Warm the cache",
+		Description: "Starts fetching the ABIs of the given contracts in the background and responds right away. Contracts already cached are skipped. Requires the ADMIN_TOKEN as bearer token.",
+		RequestBody: []BatchRequest{},
+		Status:      http.StatusAccepted,
+		Response:    gin.H{"type": "object", "properties": gin.H{"jobId": gin.H{"type": "string"}, "total": gin.H{"type": "integer"}}},
+		Errors:      []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden},
+		Public:      true,
+	},
+	"GET /cache/warm/:jobId": {
+		Summary:  "Cache warming progress",
+		Response: schemaOf(reflect.TypeOf(WarmProgress{})),
+		Errors:   []int{http.StatusNotFound},
+	},
+	"POST /abi/batch": {
+		Summary:     "Fetch ABIs in batch",
+		Description: "Entries that fail are returned as error objects with their status without failing the batch.",
+		Query:       abiQueryParams(),
+		RequestBody: []BatchRequest{},
+		Response:    gin.H{"type": "array", "items": schemaRef("ABIResponse")},
+		Errors:      []int{http.StatusBadRequest, http.StatusTooManyRequests},
+	},
+	"DELETE /abi/:chainId/:address": {
+		Summary:     "Invalidate a cached ABI",
+		Description: "Also removes the ABIs pinned to a block and, for an ENS name, its cached resolution. Requires the ADMIN_TOKEN as bearer token.",
+		Response:    gin.H{"type": "object", "properties": gin.H{"deleted": gin.H{"type": "boolean"}}},
+		Errors:      []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden},
+		Public:      true,
+	},
+	"GET /abi/history/:chainId/:address/*rpcUrl": {
+		Summary: "Fetch a proxy's upgrade history",
+		Response: gin.H{"allOf": []gin.H{schemaRef("ABIResponse"), {"type": "object", "properties": gin.H{
+			"implementations": gin.H{"type": "array", "items": gin.H{"type": "object", "properties": gin.H{
+				"address":      gin.H{"type": "string"},
+				"abi":          gin.H{"type": "string"},
+				"isDecompiled": gin.H{"type": "boolean"},
+				"fromBlock":    gin.H{"type": "integer"},
+				"toBlock":      gin.H{"type": "integer", "nullable": true},
+			}}},
+		}}}},
+		Errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusTooManyRequests, http.StatusInternalServerError},
+	},
+	"GET /abi/diff/:chainId/:address": {
+		Summary:     "Diff a contract's ABI between two blocks using the chain's default RPC URL",
+		Description: "Like GET /abi/diff/{chainId}/{address}/{rpcUrl}.",
+		Query:       abiDiffQueryParams,
+		Response:    abiDiffSchema,
+		Errors:      []int{http.StatusBadRequest, http.StatusNotFound, http.StatusUnprocessableEntity, http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway},
+	},
+	"GET /abi/diff/:chainId/:address/*rpcUrl": {
+		Summary:     "Diff a contract's ABI between two blocks",
+		Description: "Resolves the proxy implementation at both blocks and compares their ABIs: functions by selector and events by topic0.",
+		Query:       abiDiffQueryParams,
+		Response:    abiDiffSchema,
+		Errors:      []int{http.StatusBadRequest, http.StatusNotFound, http.StatusUnprocessableEntity, http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway},
+	},
+	"GET /chains": {
+		Summary:  "List configured chains",
+		Response: gin.H{"type": "array", "items": schemaRef("ChainStatus")},
+	},
+	"GET /proxy/:chainId/:address/*rpcUrl": {
+		Summary:  "Detect proxy",
+		Response: proxyResponseSchema(),
+		Errors:   []int{http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError},
+	},
+	"POST /proxy/batch": {
+		Summary:     "Detect proxies in batch",
+		Description: "Resolves the proxies of many addresses on one chain over a single RPC connection. Addresses that fail are returned as error objects with their status and address without failing the batch; rpcUrl defaults to the chain's public RPC node.",
+		RequestBody: ProxyBatchRequest{},
+		Response:    gin.H{"type": "array", "items": proxyResponseSchema("address")},
+		Errors:      []int{http.StatusBadRequest, http.StatusInternalServerError},
+	},
+	"GET /bytecode/:chainId/:address/*rpcUrl": {
+		Summary:  "Fetch runtime bytecode",
+		Response: objectSchema("bytecode", "hash"),
+		Errors:   []int{http.StatusBadRequest, http.StatusNotFound, http.StatusInternalServerError},
+	},
+	"GET /stats": {
+		Summary:  "Request and cache counters",
+		Response: statsSchema,
+	},
+	"POST /stats/reset": {
+		Summary:     "Reset the counters",
+		Description: "Requires the ADMIN_TOKEN as bearer token.",
+		Response:    statsSchema,
+		Errors:      []int{http.StatusUnauthorized, http.StatusForbidden},
+		Public:      true,
+	},
+}
+
+var abiByTxSchema = gin.H{"allOf": []gin.H{schemaRef("ABIResponse"), objectSchema("txHash", "to", "input")}}
+
+var abiDiffQueryParams = []queryParamDoc{
+	{Name: "from", Description: "Block number of the old ABI", Type: "integer"},
+	{Name: "to", Description: "Block number of the new ABI", Type: "integer"},
+}
+
+var abiDiffSchema = func() gin.H {
+	side := objectSchema("block", "implementation", "proxyType")
+	side["properties"].(gin.H)["isDecompiled"] = gin.H{"type": "boolean"}
+	changes := gin.H{"type": "array", "items": schemaOf(reflect.TypeOf(ABIChange{}))}
+	return gin.H{"type": "object", "properties": gin.H{
+		"address": gin.H{"type": "string"},
+		"from":    side,
+		"to":      side,
+		"added":   changes,
+		"removed": changes,
+		"changed": changes,
+	}}
+}()
+
+var statsSchema = gin.H{"type": "object", "properties": gin.H{
+	"requests":    gin.H{"type": "integer"},
+	"cacheHits":   gin.H{"type": "integer"},
+	"cacheMisses": gin.H{"type": "integer"},
+	"decompiled":  gin.H{"type": "integer"},
+	"errors":      gin.H{"type": "integer"},
+}}
+
+// objectSchema describes an object of string properties.
+// proxyResponseSchema is the schema of proxy detection responses, with the
+// extra string properties given.
+func proxyResponseSchema(extra ...string) gin.H {
+	schema := objectSchema(append([]string{"proxyType", "target", "admin"}, extra...)...)
+	properties := schema["properties"].(gin.H)
+	properties["isProxy"] = gin.H{"type": "boolean"}
+	properties["immutable"] = gin.H{"type": "boolean"}
+	properties["facets"] = gin.H{"type": "array", "items": gin.H{"type": "string"}}
+	return schema
+}
+
+func objectSchema(properties ...string) gin.H {
+	props := gin.H{}
+	for _, property := range properties {
+		props[property] = gin.H{"type": "string"}
+	}
+	return gin.H{"type": "object", "properties": props}
+}
+
+func schemaRef(name string) gin.H {
+	return gin.H{"$ref": "#/components/schemas/" + name}
+}
+
+// schemaOf derives a JSON schema from a Go type through its json struct tags,
+// documenting fields with their description and enum tags.
+func schemaOf(t reflect.Type) gin.H {
+	if t == reflect.TypeOf(time.Time{}) {
+		return gin.H{"type": "string", "format": "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return schemaOf(t.Elem())
+	case reflect.String:
+		return gin.H{"type": "string"}
+	case reflect.Bool:
+		return gin.H{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return gin.H{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return gin.H{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return gin.H{"type": "array", "items": schemaOf(t.Elem())}
+	case reflect.Map:
+		return gin.H{"type": "object", "additionalProperties": schemaOf(t.Elem())}
+	case reflect.Struct:
+		properties := gin.H{}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "-" {
+				continue
+			}
+			if field.Anonymous && name == "" {
+				for property, schema := range schemaOf(field.Type)["properties"].(gin.H) {
+					properties[property] = schema
+				}
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			schema := schemaOf(field.Type)
+			// Nil pointers without omitempty are encoded as null.
+			if field.Type.Kind() == reflect.Pointer && !strings.Contains(options, "omitempty") {
+				schema["nullable"] = true
+			}
+			if enum := field.Tag.Get("enum"); enum != "" {
+				schema["enum"] = strings.Split(enum, ",")
+			}
+			if description := field.Tag.Get("description"); description != "" {
+				schema["description"] = description
+			}
+			properties[name] = schema
+		}
+		return gin.H{"type": "object", "properties": properties}
+	default:
+		return gin.H{}
+	}
+}
+
+// requireFields marks the fields of struct type t that are encoded even when
+// empty as required in its schema.
+func requireFields(schema gin.H, t reflect.Type) gin.H {
+	var required []string
+	for i := 0; i < t.NumField(); i++ {
+		name, options, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" && !strings.Contains(options, "omitempty") {
+			required = append(required, name)
+		}
+	}
+	schema["required"] = required
+	return schema
+}
+
+// pathParamPattern matches gin's :param and *wildcard path segments.
+var pathParamPattern = regexp.MustCompile(`[:*]([A-Za-z]+)`)
+
+// buildOpenAPISpec generates an OpenAPI 3 spec of routes.
+func buildOpenAPISpec(routes gin.RoutesInfo) gin.H {
+	paths := gin.H{}
+	sort.Slice(routes, func(i, j int) bool { return routes[i].Path < routes[j].Path })
+	for _, route := range routes {
+		doc := routeDocs[route.Method+" "+route.Path]
+		path := pathParamPattern.ReplaceAllString(route.Path, "{$1}")
+		item, ok := paths[path].(gin.H)
+		if !ok {
+			item = gin.H{}
+			paths[path] = item
+		}
+		item[strings.ToLower(route.Method)] = operation(route.Path, doc)
+	}
+
+	return gin.H{
+		"openapi": "3.0.3",
+		"info": gin.H{
+			"title":       "Get-ABI-2000",
+			"description": "Fetches contract ABIs from block explorers, Sourcify or decompilation, resolving proxies to their implementation.",
+			"version":     "1.0.0",
+		},
+		"paths": paths,
+		"components": gin.H{
+			"schemas": gin.H{
+				"ABIResponse":  abiResponseSchema,
+				"BatchRequest": schemaOf(reflect.TypeOf(BatchRequest{})),
+				"ChainStatus":  schemaOf(reflect.TypeOf(ChainStatus{})),
+				"Error":        objectSchema("error", "code"),
+			},
+			"securitySchemes": gin.H{
+				"apiKey": gin.H{"type": "http", "scheme": "bearer", "description": "Required when API_KEYS or API_KEYS_FILE is configured"},
+			},
+		},
+	}
+}
+
+func operation(ginPath string, doc routeDoc) gin.H {
+	parameters := []gin.H{}
+	for _, match := range pathParamPattern.FindAllStringSubmatch(ginPath, -1) {
+		parameters = append(parameters, gin.H{"name": match[1], "in": "path", "required": true, "schema": gin.H{"type": "string"}})
+	}
+	for _, param := range doc.Query {
+		schema := gin.H{"type": "boolean"}
+		if param.Type != "" {
+			schema = gin.H{"type": param.Type}
+		}
+		if len(param.Enum) > 0 {
+			schema = gin.H{"type": "string", "enum": param.Enum}
+		}
+		parameters = append(parameters, gin.H{"name": param.Name, "in": "query", "description": param.Description, "schema": schema})
+	}
+	parameters = append(parameters, gin.H{"name": "pretty", "in": "query", "description": "Indent the JSON response", "schema": gin.H{"type": "boolean"}})
+
+	response := doc.Response
+	if response == nil {
+		response = gin.H{}
+	}
+	status := doc.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	responses := gin.H{
+		strconv.Itoa(status): gin.H{"description": http.StatusText(status), "content": gin.H{"application/json": gin.H{"schema": response}}},
+	}
+	for _, status := range doc.Errors {
+		responses[strconv.Itoa(status)] = gin.H{
+			"description": http.StatusText(status),
+			"content":     gin.H{"application/json": gin.H{"schema": schemaRef("Error")}},
+		}
+	}
+
+	op := gin.H{
+		"summary":    doc.Summary,
+		"parameters": parameters,
+		"responses":  responses,
+	}
+	if doc.Description != "" {
+		op["description"] = doc.Description
+	}
+	if doc.RequestBody != nil {
+		op["requestBody"] = gin.H{
+			"required": true,
+			"content":  gin.H{"application/json": gin.H{"schema": schemaOf(reflect.TypeOf(doc.RequestBody))}},
+		}
+	}
+	if !doc.Public {
+		op["security"] = []gin.H{{"apiKey": []string{}}}
+	}
+	return op
+}
+
+// serveOpenAPI serves the spec of router's routes, generated on first use so
+// that it covers every route registered by then.
+func serveOpenAPI(router *gin.Engine) gin.HandlerFunc {
+	spec := sync.OnceValue(func() gin.H { return buildOpenAPISpec(router.Routes()) })
+	return func(c *gin.Context) {
+		respond(c, http.StatusOK, spec())
+	}
+}
+
+const swaggerUIPage = `<!DOCTYPE html>
+<html>
+<head>
+  <title>Get-ABI-2000 API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+  <script>SwaggerUIBundle({url: "/openapi.json", dom_id: "#swagger-ui"});</script>
+</body>
+</html>`
+
+// serveDocs serves a Swagger UI for /openapi.json.
+func serveDocs(c *gin.Context) {
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(swaggerUIPage))
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenAPISpecCoversAllRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := newRouter([]string{"secret"})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/openapi.json", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code, "the spec is public")
+
+	var spec struct {
+		OpenAPI string                                       `json:"openapi"`
+		Paths   map[string]map[string]map[string]interface{} `json:"paths"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &spec))
+	assert.Equal(t, "3.0.3", spec.OpenAPI)
+
+	for _, route := range router.Routes() {
+		_, documented := routeDocs[route.Method+" "+route.Path]
+		assert.True(t, documented, "route %s %s is documented", route.Method, route.Path)
+	}
+
+	abi := spec.Paths["/abi/{chainId}/{address}/{rpcUrl}"]["get"]
+	if assert.NotNil(t, abi) {
+		var names []string
+		for _, parameter := range abi["parameters"].([]interface{}) {
+			names = append(names, parameter.(map[string]interface{})["name"].(string))
+		}
+		assert.Subset(t, names, []string{"chainId", "address", "rpcUrl", "format", "annotateSelectors", "pretty"})
+		assert.Contains(t, abi["responses"], "404")
+		assert.Contains(t, abi, "security")
+	}
+	assert.NotContains(t, spec.Paths["/"]["get"], "security")
+}
+
+func TestSchemaOf(t *testing.T) {
+	schema := schemaOf(reflect.TypeOf(StorageItem{}))
+	properties := schema["properties"].(gin.H)
+	assert.Equal(t, gin.H{"type": "string"}, properties["abi"])
+	assert.Equal(t, gin.H{"type": "boolean"}, properties["isProxy"])
+	assert.Equal(t, gin.H{"type": "array", "items": gin.H{"type": "string"}}, properties["facets"])
+	assert.Equal(t, gin.H{"type": "string", "format": "date-time"}, properties["storedAt"])
+	assert.Contains(t, properties, "contractName", "embedded struct fields are inlined")
+}
+
+func TestABIResponseSchema(t *testing.T) {
+	properties := abiResponseSchema["properties"].(gin.H)
+	assert.Equal(t, []string{"abi", "implementation", "isProxy", "isDecompiled", "contractName", "compilerVersion", "verificationStatus"}, abiResponseSchema["required"])
+	assert.Equal(t, true, properties["implementation"].(gin.H)["nullable"])
+	assert.NotContains(t, properties["proxyType"], "nullable")
+	candidate := properties["candidates"].(gin.H)["items"].(gin.H)["properties"].(gin.H)
+	assert.ElementsMatch(t, []string{SourceEtherscan, SourceSourcify, SourceIPFS, SourceBytecode, SourceHeimdall, SourceInterfaces, SourceFacets}, candidate["source"].(gin.H)["enum"])
+
+	// Every key of a response with all its parts is documented.
+	fetcher := NewABIFetcher(NewABIStorage(time.Hour, 0), map[int]ChainAPI{1: &GenericEtherscanAPI{ExplorerURL: "https://etherscan.io"}}, DefaultFetcherConfig())
+	item := StorageItem{
+		ABI:               `[{"type":"function","name":"f","inputs":[],"outputs":[],"stateMutability":"view"}]`,
+		Implementation:    "0x2000000000000000000000000000000000000002",
+		IsProxy:           true,
+		ProxyType:         "Eip1967",
+		ResolutionPath:    []string{"0x1000000000000000000000000000000000000001", "0x2000000000000000000000000000000000000002"},
+		Facets:            []string{"0x3000000000000000000000000000000000000003"},
+		ExplorerError:     "rate limited",
+		PartialDelegation: true,
+		Admin:             "0x4000000000000000000000000000000000000004",
+		AdminOwner:        "0x5000000000000000000000000000000000000005",
+		Creation:          &ContractCreation{Creator: "0x6000000000000000000000000000000000000006", TxHash: "0x01"},
+	}
+	response, err := fetcher.createResponse("1", "0x1000000000000000000000000000000000000001", item, FetchOptions{
+		IncludeAdminOwner:  true,
+		IncludeInterfaceID: true,
+		IncludeSelectors:   true,
+		IncludeCreation:    true,
+	})
+	assert.NoError(t, err)
+	response["candidates"], response["interfaces"] = nil, nil
+	response["ensName"], response["resolvedAddress"], response["block"] = nil, nil, nil
+	for key := range response {
+		assert.Contains(t, properties, key)
+	}
+	assert.Len(t, response, len(properties), "every documented property is set")
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// pinnedReader reads all state at a fixed block instead of the block its
+// callers ask for, so proxy detection written against the latest state sees
+// the chain as it was at that block. Reading old state requires an archive
+// node.
+type pinnedReader struct {
+	reader ContractReader
+	block  *big.Int
+}
+
+func (p *pinnedReader) CodeAt(ctx context.Context, account common.Address, _ *big.Int) ([]byte, error) {
+	return p.reader.CodeAt(ctx, account, p.block)
+}
+
+func (p *pinnedReader) StorageAt(ctx context.Context, account common.Address, key common.Hash, _ *big.Int) ([]byte, error) {
+	return p.reader.StorageAt(ctx, account, key, p.block)
+}
+
+func (p *pinnedReader) CallContract(ctx context.Context, msg ethereum.CallMsg, _ *big.Int) ([]byte, error) {
+	return p.reader.CallContract(ctx, msg, p.block)
+}
+
+func (p *pinnedReader) NonceAt(ctx context.Context, account common.Address, _ *big.Int) (uint64, error) {
+	accounts, ok := p.reader.(AccountReader)
+	if !ok {
+		return 0, errors.New("reader cannot read account nonces")
+	}
+	return accounts.NonceAt(ctx, account, p.block)
+}
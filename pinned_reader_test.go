@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+// historicalReader serves the state of past blocks from separate mocks and
+// the latest state for a nil block.
+type historicalReader struct {
+	latest *mockContractReader
+	blocks map[int64]*mockContractReader
+}
+
+func (h *historicalReader) at(blockNumber *big.Int) *mockContractReader {
+	if blockNumber == nil {
+		return h.latest
+	}
+	return h.blocks[blockNumber.Int64()]
+}
+
+func (h *historicalReader) CodeAt(ctx context.Context, account common.Address, blockNumber *big.Int) ([]byte, error) {
+	return h.at(blockNumber).CodeAt(ctx, account, blockNumber)
+}
+
+func (h *historicalReader) StorageAt(ctx context.Context, account common.Address, key common.Hash, blockNumber *big.Int) ([]byte, error) {
+	return h.at(blockNumber).StorageAt(ctx, account, key, blockNumber)
+}
+
+func (h *historicalReader) CallContract(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	return h.at(blockNumber).CallContract(ctx, msg, blockNumber)
+}
+
+func TestPinnedReaderResolvesHistoricalImplementation(t *testing.T) {
+	proxy := common.HexToAddress("0x1000000000000000000000000000000000000001")
+	oldImplementation := common.HexToAddress("0x2000000000000000000000000000000000000002")
+	newImplementation := common.HexToAddress("0x3000000000000000000000000000000000000003")
+
+	past := newMockContractReader()
+	past.setStorage(proxy, EIP1967LogicSlot, oldImplementation)
+	latest := newMockContractReader()
+	latest.setStorage(proxy, EIP1967LogicSlot, newImplementation)
+	reader := &historicalReader{latest: latest, blocks: map[int64]*mockContractReader{100: past}}
+
+	info, err := DetectProxyTarget(context.Background(), &pinnedReader{reader: reader, block: big.NewInt(100)}, proxy)
+	assert.NoError(t, err)
+	assert.Equal(t, oldImplementation, info.Target)
+
+	info, err = DetectProxyTarget(context.Background(), reader, proxy)
+	assert.NoError(t, err)
+	assert.Equal(t, newImplementation, info.Target)
+}
@@ -0,0 +1,40 @@
+package main
+
+import "fmt"
+
+// ABIPostProcessor is an optional transform applied to a fetched ABI before
+// it is returned. Processors are enabled per request through a query
+// parameter named after them.
+type ABIPostProcessor interface {
+	Name() string
+	Process(abi string) (string, error)
+}
+
+// defaultPostProcessors lists the available processors in the order they run.
+func defaultPostProcessors() []ABIPostProcessor {
+	return []ABIPostProcessor{
+		selectorAnnotator{},
+	}
+}
+
+// applyPostProcessors runs every processor whose name is enabled, in order.
+func applyPostProcessors(abi string, processors []ABIPostProcessor, enabled map[string]bool) (string, error) {
+	for _, processor := range processors {
+		if !enabled[processor.Name()] {
+			continue
+		}
+		processed, err := processor.Process(abi)
+		if err != nil {
+			return "", fmt.Errorf("%s failed: %v", processor.Name(), err)
+		}
+		abi = processed
+	}
+	return abi, nil
+}
+
+// selectorAnnotator adds selectors to functions and topic0 hashes to events.
+type selectorAnnotator struct{}
+
+func (selectorAnnotator) Name() string { return "annotateSelectors" }
+
+func (selectorAnnotator) Process(abi string) (string, error) { return annotateSelectors(abi) }
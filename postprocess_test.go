@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type suffixProcessor struct {
+	name   string
+	suffix string
+}
+
+func (p suffixProcessor) Name() string { return p.name }
+
+func (p suffixProcessor) Process(abi string) (string, error) { return abi + p.suffix, nil }
+
+type failingProcessor struct{}
+
+func (failingProcessor) Name() string { return "fail" }
+
+func (failingProcessor) Process(abi string) (string, error) { return "", fmt.Errorf("boom") }
+
+func TestApplyPostProcessors(t *testing.T) {
+	processors := []ABIPostProcessor{
+		suffixProcessor{name: "a", suffix: "-a"},
+		suffixProcessor{name: "b", suffix: "-b"},
+		failingProcessor{},
+	}
+
+	abi, err := applyPostProcessors("abi", processors, map[string]bool{"b": true, "a": true})
+	assert.NoError(t, err)
+	assert.Equal(t, "abi-a-b", abi, "processors run in registration order")
+
+	abi, err = applyPostProcessors("abi", processors, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "abi", abi)
+
+	_, err = applyPostProcessors("abi", processors, map[string]bool{"fail": true})
+	assert.Error(t, err)
+	assert.True(t, strings.HasPrefix(err.Error(), "fail failed"))
+}
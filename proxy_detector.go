@@ -1,14 +1,21 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"log/slog"
 	"math/big"
 	"strings"
+	"sync"
 
 	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
@@ -16,6 +23,10 @@ const (
 	EIP1967BeaconSlot              = "0xa3f0ad74e5423aebfd80d3ef4346578335a9a72aeaee59ff6cb3582b35133d50"
 	EIP1822LogicSlot               = "0xc5f16f0fcc639fa48a6947836d9850f504798523bf8c9a3a87d5876cf622bcf7"
 	OpenZeppelinImplementationSlot = "0x7050c9e0f4ca769c69bd3a8ef740bc37934f8e2c036e5a723fd8ee048ed3f8c3"
+	EIP1967AdminSlot               = "0xb53127684a568b3173ae13b9f8a6016e243e63b6e8ee1178d6a717850b5d6103"
+	OwnerMethod                    = "0x8da5cb5b"
+	// DiamondFacetsMethod is facets() of the EIP-2535 DiamondLoupe interface.
+	DiamondFacetsMethod = "0x7a0ed627"
 )
 
 var (
@@ -26,18 +37,80 @@ var (
 	EIP897Interface           = []string{"0x5c60da1b00000000000000000000000000000000000000000000000000000000"}
 	GnosisSafeProxyInterface  = []string{"0xa619486e00000000000000000000000000000000000000000000000000000000"}
 	ComptrollerProxyInterface = []string{"0xbb82aa5e00000000000000000000000000000000000000000000000000000000"}
-	EIP1167BytecodePrefix     = "0x363d3d373d3d3d363d"
-	EIP1167BytecodeSuffix     = "57fd5bf3"
+	// ImplementationGetters are the selectors of further non-standard
+	// getters proxies report their implementation through. main adds those
+	// configured in PROXY_IMPLEMENTATION_GETTERS.
+	ImplementationGetters = []string{
+		"0xaaf10f42", // getImplementation()
+	}
+)
+
+const (
+	opPush1        = 0x60
+	opPush4        = 0x63
+	opPush32       = 0x7f
+	opDelegateCall = 0xf4
 )
 
+// maxBeaconDepth bounds how many times a beacon's implementation is itself
+// resolved as a proxy before the result is taken as final.
+const maxBeaconDepth = 1
+
 type ProxyInfo struct {
 	Target    common.Address
 	Immutable bool
 	Type      string
+	// Facets lists the facet addresses of an EIP-2535 Diamond, which has no
+	// single Target.
+	Facets []common.Address
+	// Admin is the address in the EIP-1967 admin slot, or the zero address
+	// when the proxy has none.
+	Admin common.Address
+}
+
+// hasTarget reports whether the proxy resolves to something whose ABI can be
+// fetched: a non-zero Target, or the facets of a diamond.
+func (p *ProxyInfo) hasTarget() bool {
+	return p.Target != (common.Address{}) || len(p.Facets) > 0
 }
 
-func DetectProxyTarget(ctx context.Context, client *ethclient.Client, proxyAddress common.Address) (*ProxyInfo, error) {
-	detectUsingBytecode := func() (*ProxyInfo, error) {
+// diamondLoupeABI declares the DiamondLoupe facets() getter.
+var diamondLoupeABI = mustParseABI(`[{"type":"function","name":"facets","inputs":[],"outputs":[{"name":"","type":"tuple[]","components":[{"name":"facetAddress","type":"address"},{"name":"functionSelectors","type":"bytes4[]"}]}],"stateMutability":"view"}]`)
+
+// ContractReader is the subset of the ethclient API used for proxy detection.
+type ContractReader interface {
+	CodeAt(ctx context.Context, account common.Address, blockNumber *big.Int) ([]byte, error)
+	StorageAt(ctx context.Context, account common.Address, key common.Hash, blockNumber *big.Int) ([]byte, error)
+	CallContract(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error)
+}
+
+// AccountReader is implemented by ContractReaders that can also read account
+// state, such as *ethclient.Client.
+type AccountReader interface {
+	NonceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (uint64, error)
+}
+
+// DetectProxyTarget detects whether proxyAddress is a proxy and what it
+// delegates to. A returned ProxyInfo always has a non-zero Target, or Facets
+// for diamonds. Its RPC calls wait for a free slot in proxyDetectionBudget.
+func DetectProxyTarget(ctx context.Context, client ContractReader, proxyAddress common.Address) (*ProxyInfo, error) {
+	ctx, span := tracer.Start(ctx, "DetectProxyTarget", trace.WithAttributes(attribute.String("address", proxyAddress.Hex())))
+	defer span.End()
+	if prefetcher, ok := client.(proxyReadPrefetcher); ok {
+		// On failure, every read is simply made on its own.
+		if err := prefetcher.prefetchProxyReads(ctx, proxyAddress); err != nil {
+			span.RecordError(err)
+		}
+	}
+	info, err := detectProxyTarget(ctx, newDetectionReader(client), proxyAddress, 0)
+	if info != nil {
+		span.SetAttributes(attribute.String("proxyType", info.Type))
+	}
+	return info, err
+}
+
+func detectProxyTarget(ctx context.Context, client ContractReader, proxyAddress common.Address, depth int) (*ProxyInfo, error) {
+	detectUsingBytecode := func(ctx context.Context) (*ProxyInfo, error) {
 		bytecode, err := client.CodeAt(ctx, proxyAddress, nil)
 		if err != nil {
 			return nil, err
@@ -45,7 +118,7 @@ func DetectProxyTarget(ctx context.Context, client *ethclient.Client, proxyAddre
 		return parse1167Bytecode(bytecode)
 	}
 
-	detectUsingEIP1967LogicSlot := func() (*ProxyInfo, error) {
+	detectUsingEIP1967LogicSlot := func(ctx context.Context) (*ProxyInfo, error) {
 		logicAddress, err := client.StorageAt(ctx, proxyAddress, common.HexToHash(EIP1967LogicSlot), nil)
 		if err != nil {
 			return nil, err
@@ -53,14 +126,16 @@ func DetectProxyTarget(ctx context.Context, client *ethclient.Client, proxyAddre
 		if isZeroAddress(logicAddress) {
 			return nil, fmt.Errorf("zero address in EIP1967 logic slot")
 		}
+		admin, _ := DetectProxyAdmin(ctx, client, proxyAddress)
 		return &ProxyInfo{
 			Target:    common.BytesToAddress(logicAddress),
 			Immutable: false,
 			Type:      "Eip1967Direct",
+			Admin:     admin,
 		}, nil
 	}
 
-	detectUsingEIP1967BeaconSlot := func() (*ProxyInfo, error) {
+	detectUsingEIP1967BeaconSlot := func(ctx context.Context) (*ProxyInfo, error) {
 		beaconAddress, err := client.StorageAt(ctx, proxyAddress, common.HexToHash(EIP1967BeaconSlot), nil)
 		if err != nil {
 			return nil, err
@@ -71,18 +146,28 @@ func DetectProxyTarget(ctx context.Context, client *ethclient.Client, proxyAddre
 		resolvedBeaconAddress := common.BytesToAddress(beaconAddress)
 		for _, method := range EIP1167BeaconMethods {
 			data, err := client.CallContract(ctx, ethereum.CallMsg{To: &resolvedBeaconAddress, Data: common.FromHex(method)}, nil)
-			if err == nil && !isZeroAddress(data) {
+			if err == nil && len(data) >= 32 && !isZeroAddress(data[12:32]) {
+				target := common.BytesToAddress(data[12:])
+				// The beacon's implementation may itself be an upgradeable proxy,
+				// in which case the real logic sits one more hop away.
+				if depth < maxBeaconDepth {
+					if inner, err := detectProxyTarget(ctx, client, target, depth+1); err == nil && inner.Target != (common.Address{}) {
+						target = inner.Target
+					}
+				}
+				admin, _ := DetectProxyAdmin(ctx, client, proxyAddress)
 				return &ProxyInfo{
-					Target:    common.BytesToAddress(data[12:]),
+					Target:    target,
 					Immutable: false,
 					Type:      "Eip1967Beacon",
+					Admin:     admin,
 				}, nil
 			}
 		}
 		return nil, fmt.Errorf("beacon method calls failed")
 	}
 
-	detectUsingEIP1822LogicSlot := func() (*ProxyInfo, error) {
+	detectUsingEIP1822LogicSlot := func(ctx context.Context) (*ProxyInfo, error) {
 		logicAddress, err := client.StorageAt(ctx, proxyAddress, common.HexToHash(EIP1822LogicSlot), nil)
 		if err != nil {
 			return nil, err
@@ -97,7 +182,7 @@ func DetectProxyTarget(ctx context.Context, client *ethclient.Client, proxyAddre
 		}, nil
 	}
 
-	detectUsingInterfaceCalls := func(data string) (*ProxyInfo, error) {
+	detectUsingInterfaceCalls := func(ctx context.Context, data string) (*ProxyInfo, error) {
 		result, err := client.CallContract(ctx, ethereum.CallMsg{To: &proxyAddress, Data: common.FromHex(data)}, nil)
 		if err != nil {
 			return nil, err
@@ -105,6 +190,9 @@ func DetectProxyTarget(ctx context.Context, client *ethclient.Client, proxyAddre
 		if len(result) < 32 {
 			return nil, fmt.Errorf("invalid result length")
 		}
+		if isZeroAddress(result[12:32]) {
+			return nil, fmt.Errorf("zero address returned by interface call")
+		}
 		return &ProxyInfo{
 			Target:    common.BytesToAddress(result[12:]),
 			Immutable: false,
@@ -112,7 +200,32 @@ func DetectProxyTarget(ctx context.Context, client *ethclient.Client, proxyAddre
 		}, nil
 	}
 
-	detectUsingOpenZeppelinSlot := func() (*ProxyInfo, error) {
+	// Custom getters are less established than the interfaces above, so the
+	// returned word must be exactly an address that holds code.
+	detectUsingImplementationGetter := func(ctx context.Context, selector string) (*ProxyInfo, error) {
+		result, err := client.CallContract(ctx, ethereum.CallMsg{To: &proxyAddress, Data: common.FromHex(selector)}, nil)
+		if err != nil {
+			return nil, err
+		}
+		if !isAddressWord(result) || isZeroAddress(result) {
+			return nil, fmt.Errorf("getter %s returned no address", selector)
+		}
+		target := common.BytesToAddress(result)
+		code, err := client.CodeAt(ctx, target, nil)
+		if err != nil {
+			return nil, err
+		}
+		if len(code) == 0 {
+			return nil, fmt.Errorf("getter %s returned an address without code", selector)
+		}
+		return &ProxyInfo{
+			Target:    target,
+			Immutable: false,
+			Type:      "InterfaceCall",
+		}, nil
+	}
+
+	detectUsingOpenZeppelinSlot := func(ctx context.Context) (*ProxyInfo, error) {
 		implementationAddr, err := client.StorageAt(ctx, proxyAddress, common.HexToHash(OpenZeppelinImplementationSlot), nil)
 		if err != nil {
 			return nil, err
@@ -126,73 +239,339 @@ func DetectProxyTarget(ctx context.Context, client *ethclient.Client, proxyAddre
 			Type:      "OpenZeppelin",
 		}, nil
 	}
-	detectionMethods := []func() (*ProxyInfo, error){
-		detectUsingBytecode,
-		detectUsingEIP1967LogicSlot,
-		detectUsingEIP1967BeaconSlot,
-		detectUsingOpenZeppelinSlot,
-		detectUsingEIP1822LogicSlot,
-		func() (*ProxyInfo, error) { return detectUsingInterfaceCalls(EIP897Interface[0]) },
-		func() (*ProxyInfo, error) { return detectUsingInterfaceCalls(GnosisSafeProxyInterface[0]) },
-		func() (*ProxyInfo, error) { return detectUsingInterfaceCalls(ComptrollerProxyInterface[0]) },
-	}
-
-	results := make(chan *ProxyInfo, len(detectionMethods))
-	errors := make(chan error, len(detectionMethods))
-
-	for _, method := range detectionMethods {
-		go func(m func() (*ProxyInfo, error)) {
-			result, err := m()
-			if err != nil {
-				errors <- err
-			} else if result != nil {
-				results <- result
+	// Several proxy frameworks (e.g. Gnosis Safe, Dharma) keep the
+	// implementation in storage slot 0. Since plenty of contracts store other
+	// addresses there, only accept it for proxies that can delegate at all.
+	detectUsingSlot0 := func(ctx context.Context) (*ProxyInfo, error) {
+		slotValue, err := client.StorageAt(ctx, proxyAddress, common.Hash{}, nil)
+		if err != nil {
+			return nil, err
+		}
+		if isZeroAddress(slotValue) || !isAddressWord(slotValue) {
+			return nil, fmt.Errorf("no address in slot 0")
+		}
+		target := common.BytesToAddress(slotValue)
+		if target == proxyAddress {
+			return nil, fmt.Errorf("slot 0 points to the proxy itself")
+		}
+		targetCode, err := client.CodeAt(ctx, target, nil)
+		if err != nil {
+			return nil, err
+		}
+		if len(targetCode) == 0 {
+			return nil, fmt.Errorf("slot 0 address has no code")
+		}
+		proxyCode, err := client.CodeAt(ctx, proxyAddress, nil)
+		if err != nil {
+			return nil, err
+		}
+		if !containsOpcode(proxyCode, opDelegateCall) {
+			return nil, fmt.Errorf("bytecode has no DELEGATECALL")
+		}
+		return &ProxyInfo{
+			Target:    target,
+			Immutable: false,
+			Type:      "Slot0",
+		}, nil
+	}
+
+	detectUsingDiamondLoupe := func(ctx context.Context) (*ProxyInfo, error) {
+		result, err := client.CallContract(ctx, ethereum.CallMsg{To: &proxyAddress, Data: common.FromHex(DiamondFacetsMethod)}, nil)
+		if err != nil {
+			return nil, err
+		}
+		facets, err := decodeDiamondFacets(result)
+		if err != nil {
+			return nil, err
+		}
+		return &ProxyInfo{
+			Immutable: false,
+			Type:      "Diamond",
+			Facets:    facets,
+		}, nil
+	}
+
+	// The methods are ordered by precedence: when several of them match, the
+	// most authoritative one wins, regardless of which finishes first. The
+	// slot 0 heuristic comes last as it is the easiest to match by accident.
+	type detectionMethod struct {
+		name   string
+		detect func(ctx context.Context) (*ProxyInfo, error)
+	}
+	detectionMethods := []detectionMethod{
+		{"bytecode", detectUsingBytecode},
+		{"eip1967Logic", detectUsingEIP1967LogicSlot},
+		{"eip1967Beacon", detectUsingEIP1967BeaconSlot},
+		{"openZeppelin", detectUsingOpenZeppelinSlot},
+		{"eip1822", detectUsingEIP1822LogicSlot},
+		{"eip897", func(ctx context.Context) (*ProxyInfo, error) {
+			return detectUsingInterfaceCalls(ctx, EIP897Interface[0])
+		}},
+		{"gnosisSafe", func(ctx context.Context) (*ProxyInfo, error) {
+			return detectUsingInterfaceCalls(ctx, GnosisSafeProxyInterface[0])
+		}},
+		{"comptroller", func(ctx context.Context) (*ProxyInfo, error) {
+			return detectUsingInterfaceCalls(ctx, ComptrollerProxyInterface[0])
+		}},
+	}
+	for _, selector := range ImplementationGetters {
+		detectionMethods = append(detectionMethods, detectionMethod{"getter." + selector, func(ctx context.Context) (*ProxyInfo, error) {
+			return detectUsingImplementationGetter(ctx, selector)
+		}})
+	}
+	detectionMethods = append(detectionMethods,
+		detectionMethod{"diamondLoupe", detectUsingDiamondLoupe},
+		detectionMethod{"slot0", detectUsingSlot0},
+	)
+
+	// The methods still run concurrently. Once every method ranked above a
+	// match has failed, the match is returned and the rest are cancelled, so
+	// they stop issuing RPC calls. outcomes is buffered for every method, so
+	// the cancelled goroutines can still report and exit, and they are waited
+	// for so that none still reads from client once detection returns.
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type outcome struct {
+		index int
+		info  *ProxyInfo
+	}
+	outcomes := make(chan outcome, len(detectionMethods))
+	for i, method := range detectionMethods {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if ctx.Err() != nil {
+				outcomes <- outcome{index: i}
+				return
+			}
+			methodCtx, span := tracer.Start(ctx, "detect."+method.name)
+			info, err := withRPCRetry(methodCtx, proxyDetectionRetry, func() (*ProxyInfo, error) {
+				return method.detect(methodCtx)
+			})
+			// A proxy pointing nowhere would have the ABI of the zero
+			// address fetched for it, so it does not count as a match.
+			if err != nil || (info != nil && !info.hasTarget()) {
+				info = nil
 			}
-		}(method)
+			span.SetAttributes(attribute.Bool("detected", info != nil))
+			span.End()
+			outcomes <- outcome{index: i, info: info}
+		}(i)
 	}
 
-	for i := 0; i < len(detectionMethods); i++ {
+	finished := make([]bool, len(detectionMethods))
+	found := make([]*ProxyInfo, len(detectionMethods))
+	next := 0
+	for received := 0; received < len(detectionMethods); received++ {
 		select {
-		case result := <-results:
-			return result, nil
-		case <-errors:
-			// Just ignore individual errors and continue
+		case o := <-outcomes:
+			finished[o.index] = true
+			found[o.index] = o.info
 		case <-ctx.Done():
 			return nil, ctx.Err()
 		}
+		for next < len(detectionMethods) && finished[next] {
+			if found[next] != nil {
+				return found[next], nil
+			}
+			next++
+		}
 	}
 
 	return nil, fmt.Errorf("unable to detect proxy target")
 }
 
+// decodeDiamondFacets decodes the result of facets() into the distinct,
+// non-zero facet addresses in the order the diamond lists them.
+func decodeDiamondFacets(result []byte) ([]common.Address, error) {
+	var facets []struct {
+		FacetAddress      common.Address
+		FunctionSelectors [][4]byte
+	}
+	if err := diamondLoupeABI.UnpackIntoInterface(&facets, "facets", result); err != nil {
+		return nil, fmt.Errorf("invalid facets() result: %v", err)
+	}
+
+	seen := make(map[common.Address]bool)
+	var addresses []common.Address
+	for _, facet := range facets {
+		if facet.FacetAddress == (common.Address{}) || seen[facet.FacetAddress] {
+			continue
+		}
+		seen[facet.FacetAddress] = true
+		addresses = append(addresses, facet.FacetAddress)
+	}
+	if len(addresses) == 0 {
+		return nil, fmt.Errorf("diamond has no facets")
+	}
+	return addresses, nil
+}
+
+// DetectProxyAdmin reads the admin address from the EIP-1967 admin slot.
+func DetectProxyAdmin(ctx context.Context, client ContractReader, proxyAddress common.Address) (common.Address, error) {
+	adminAddress, err := client.StorageAt(ctx, proxyAddress, common.HexToHash(EIP1967AdminSlot), nil)
+	if err != nil {
+		return common.Address{}, err
+	}
+	if isZeroAddress(adminAddress) {
+		return common.Address{}, fmt.Errorf("zero address in EIP1967 admin slot")
+	}
+	return common.BytesToAddress(adminAddress), nil
+}
+
+// ResolveOwner calls owner() on address, e.g. a ProxyAdmin contract. It
+// reports false for accounts that are not ownable.
+func ResolveOwner(ctx context.Context, client ContractReader, address common.Address) (common.Address, bool) {
+	result, err := client.CallContract(ctx, ethereum.CallMsg{To: &address, Data: common.FromHex(OwnerMethod)}, nil)
+	if err != nil || len(result) < 32 || isZeroAddress(result) {
+		return common.Address{}, false
+	}
+	return common.BytesToAddress(result[12:32]), true
+}
+
+// isAuthoritativeProxyType reports whether a proxy type is detected from a
+// standardized storage slot or bytecode layout, as opposed to a heuristic
+// interface call that may match non-proxy contracts.
+func isAuthoritativeProxyType(proxyType string) bool {
+	switch proxyType {
+	case "Eip1167", "Eip1967Direct", "Eip1967Beacon", "Eip1822", "OpenZeppelin":
+		return true
+	}
+	return false
+}
+
+// parseImplementationGetters parses comma-separated getters given either as a
+// 4-byte selector such as 0xaaf10f42 or as a signature such as
+// getImplementation(), skipping invalid ones with a warning.
+func parseImplementationGetters(value string) []string {
+	var selectors []string
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if strings.HasSuffix(entry, ")") && strings.Contains(entry, "(") {
+			selectors = append(selectors, hexutil.Encode(crypto.Keccak256([]byte(entry))[:4]))
+			continue
+		}
+		selector, err := hexutil.Decode(entry)
+		if err != nil || len(selector) != 4 {
+			slog.Warn("invalid implementation getter, ignoring it: expected a 4-byte selector or a signature", "value", entry)
+			continue
+		}
+		selectors = append(selectors, hexutil.Encode(selector))
+	}
+	return selectors
+}
+
+// isAddressWord reports whether a 32-byte storage word holds nothing but a
+// right-aligned 20-byte address.
+func isAddressWord(word []byte) bool {
+	if len(word) != 32 {
+		return false
+	}
+	for _, b := range word[:12] {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// containsOpcode reports whether op occurs as an instruction in code, skipping
+// the immediate data of PUSH instructions.
+func containsOpcode(code []byte, op byte) bool {
+	for i := 0; i < len(code); i++ {
+		if code[i] == op {
+			return true
+		}
+		if code[i] >= opPush1 && code[i] <= opPush32 {
+			i += int(code[i]-opPush1) + 1
+		}
+	}
+	return false
+}
+
+func mustParseABI(definition string) abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader(definition))
+	if err != nil {
+		panic(err)
+	}
+	return parsed
+}
+
 func isZeroAddress(addr []byte) bool {
 	return new(big.Int).SetBytes(addr).Cmp(big.NewInt(0)) == 0
 }
 
 func parse1167Bytecode(bytecode []byte) (*ProxyInfo, error) {
-	bytecodeHex := common.Bytes2Hex(bytecode)
-	if !strings.HasPrefix(bytecodeHex, strings.TrimPrefix(EIP1167BytecodePrefix, "0x")) {
-		return nil, fmt.Errorf("not an EIP-1167 bytecode")
+	for _, layout := range minimalProxyLayouts {
+		if target, ok := layout.match(bytecode); ok {
+			return &ProxyInfo{
+				Target:    target,
+				Immutable: true,
+				Type:      "Eip1167",
+			}, nil
+		}
 	}
+	return nil, fmt.Errorf("not an EIP-1167 bytecode")
+}
 
-	pushNHex := bytecodeHex[len(EIP1167BytecodePrefix)-2 : len(EIP1167BytecodePrefix)]
-	addressLength := int(new(big.Int).SetBytes(common.FromHex(pushNHex)).Int64()) - 0x5f
-
-	if addressLength < 1 || addressLength > 20 {
-		return nil, fmt.Errorf("invalid address length in EIP-1167 bytecode")
-	}
+// minimalProxyLayout describes the runtime code of an EIP-1167 style minimal
+// proxy: prefix, a PUSH1 to PUSH20 of the implementation address, then beforeJump,
+// the JUMPI destination and tail. The destination moves with the length of
+// the address push, so jumpDest is the one for a full 20-byte address.
+type minimalProxyLayout struct {
+	prefix     []byte
+	beforeJump []byte
+	jumpDest   byte
+	tail       []byte
+}
 
-	addressFromBytecode := bytecodeHex[len(EIP1167BytecodePrefix) : len(EIP1167BytecodePrefix)+addressLength*2]
-	suffixStartIndex := len(EIP1167BytecodePrefix) + addressLength*2 + 22
+// minimalProxyLayouts lists the supported minimal proxy variants. Code
+// following a matching proxy, such as Solady's immutable args, is ignored.
+var minimalProxyLayouts = []minimalProxyLayout{
+	// EIP-1167, including vanity addresses pushed with fewer than 20 bytes.
+	{
+		prefix:     common.FromHex("363d3d373d3d3d363d"),
+		beforeJump: common.FromHex("5af43d82803e903d9160"),
+		jumpDest:   0x2b,
+		tail:       common.FromHex("57fd5bf3"),
+	},
+	// 0age's more-minimal proxy.
+	{
+		prefix:     common.FromHex("3d3d3d3d363d3d37363d"),
+		beforeJump: common.FromHex("5af43d3d93803e60"),
+		jumpDest:   0x2a,
+		tail:       common.FromHex("57fd5bf3"),
+	},
+	// Solady's PUSH0 clone.
+	{
+		prefix:     common.FromHex("5f5f365f5f37365f"),
+		beforeJump: common.FromHex("5af43d5f5f3e60"),
+		jumpDest:   0x29,
+		tail:       common.FromHex("573d5ffd5b3d5ff3"),
+	},
+}
 
-	if len(bytecodeHex) < suffixStartIndex+len(EIP1167BytecodeSuffix) ||
-		!strings.HasSuffix(bytecodeHex[suffixStartIndex:], EIP1167BytecodeSuffix) {
-		return nil, fmt.Errorf("invalid EIP-1167 bytecode suffix")
+func (l minimalProxyLayout) match(bytecode []byte) (common.Address, bool) {
+	if !bytes.HasPrefix(bytecode, l.prefix) || len(bytecode) == len(l.prefix) {
+		return common.Address{}, false
+	}
+	rest := bytecode[len(l.prefix):]
+	addressLength := int(rest[0]) - 0x5f
+	if addressLength < 1 || addressLength > 20 || len(rest) < 1+addressLength {
+		return common.Address{}, false
 	}
+	address := rest[1 : 1+addressLength]
+	rest = rest[1+addressLength:]
 
-	return &ProxyInfo{
-		Target:    common.HexToAddress(addressFromBytecode),
-		Immutable: true,
-		Type:      "Eip1167",
-	}, nil
+	expected := append(append(append([]byte{}, l.beforeJump...), l.jumpDest-byte(20-addressLength)), l.tail...)
+	if !bytes.HasPrefix(rest, expected) {
+		return common.Address{}, false
+	}
+	return common.BytesToAddress(address), true
 }
@@ -2,14 +2,79 @@ package main
 
 import (
 	"context"
+	"fmt"
+	"math/big"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
+// mockContractReader serves canned code, storage and call results so proxy
+// topologies can be modeled without an RPC node.
+type mockContractReader struct {
+	code    map[common.Address][]byte
+	storage map[common.Address]map[common.Hash][]byte
+	calls   map[common.Address]map[string][]byte
+	nonces  map[common.Address]uint64
+}
+
+func newMockContractReader() *mockContractReader {
+	return &mockContractReader{
+		code:    make(map[common.Address][]byte),
+		storage: make(map[common.Address]map[common.Hash][]byte),
+		calls:   make(map[common.Address]map[string][]byte),
+		nonces:  make(map[common.Address]uint64),
+	}
+}
+
+func (m *mockContractReader) setStorage(account common.Address, slot string, value common.Address) {
+	if m.storage[account] == nil {
+		m.storage[account] = make(map[common.Hash][]byte)
+	}
+	m.storage[account][common.HexToHash(slot)] = common.LeftPadBytes(value.Bytes(), 32)
+}
+
+func (m *mockContractReader) setCall(account common.Address, data string, result common.Address) {
+	m.setCallResult(account, data, common.LeftPadBytes(result.Bytes(), 32))
+}
+
+func (m *mockContractReader) setCallResult(account common.Address, data string, result []byte) {
+	if m.calls[account] == nil {
+		m.calls[account] = make(map[string][]byte)
+	}
+	m.calls[account][common.Bytes2Hex(common.FromHex(data))] = result
+}
+
+func (m *mockContractReader) CodeAt(ctx context.Context, account common.Address, blockNumber *big.Int) ([]byte, error) {
+	return m.code[account], nil
+}
+
+func (m *mockContractReader) StorageAt(ctx context.Context, account common.Address, key common.Hash, blockNumber *big.Int) ([]byte, error) {
+	if value, ok := m.storage[account][key]; ok {
+		return value, nil
+	}
+	return make([]byte, 32), nil
+}
+
+func (m *mockContractReader) NonceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (uint64, error) {
+	return m.nonces[account], nil
+}
+
+func (m *mockContractReader) CallContract(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	if result, ok := m.calls[*msg.To][common.Bytes2Hex(msg.Data)]; ok {
+		return result, nil
+	}
+	return nil, fmt.Errorf("execution reverted")
+}
+
 func TestProxyDetection(t *testing.T) {
 	if testing.Short() {
 		t.Skip("Skipping integration tests in short mode")
@@ -97,3 +162,396 @@ func TestProxyDetection(t *testing.T) {
 		})
 	}
 }
+
+func TestBeaconImplementationIsProxy(t *testing.T) {
+	proxy := common.HexToAddress("0x1000000000000000000000000000000000000001")
+	beacon := common.HexToAddress("0x2000000000000000000000000000000000000002")
+	beaconLogic := common.HexToAddress("0x3000000000000000000000000000000000000003")
+	implementation := common.HexToAddress("0x4000000000000000000000000000000000000004")
+
+	client := newMockContractReader()
+	client.setStorage(proxy, EIP1967BeaconSlot, beacon)
+	client.setCall(beacon, EIP1167BeaconMethods[0], beaconLogic)
+	client.setStorage(beaconLogic, EIP1967LogicSlot, implementation)
+
+	proxyInfo, err := DetectProxyTarget(context.Background(), client, proxy)
+	assert.NoError(t, err)
+	assert.Equal(t, implementation, proxyInfo.Target)
+	assert.Equal(t, "Eip1967Beacon", proxyInfo.Type)
+}
+
+func TestBeaconImplementationResolutionIsBounded(t *testing.T) {
+	proxy := common.HexToAddress("0x1000000000000000000000000000000000000001")
+	beacon := common.HexToAddress("0x2000000000000000000000000000000000000002")
+	innerProxy := common.HexToAddress("0x3000000000000000000000000000000000000003")
+	innerBeacon := common.HexToAddress("0x4000000000000000000000000000000000000004")
+	deepImplementation := common.HexToAddress("0x5000000000000000000000000000000000000005")
+
+	client := newMockContractReader()
+	client.setStorage(proxy, EIP1967BeaconSlot, beacon)
+	client.setCall(beacon, EIP1167BeaconMethods[0], innerProxy)
+	client.setStorage(innerProxy, EIP1967BeaconSlot, innerBeacon)
+	client.setCall(innerBeacon, EIP1167BeaconMethods[0], deepImplementation)
+	client.setStorage(deepImplementation, EIP1967LogicSlot, common.HexToAddress("0x6000000000000000000000000000000000000006"))
+
+	proxyInfo, err := DetectProxyTarget(context.Background(), client, proxy)
+	assert.NoError(t, err)
+	assert.Equal(t, deepImplementation, proxyInfo.Target, "resolution should stop after one extra hop")
+}
+
+func TestEIP1967AdminDetection(t *testing.T) {
+	proxy := common.HexToAddress("0x1000000000000000000000000000000000000001")
+	implementation := common.HexToAddress("0x2000000000000000000000000000000000000002")
+	admin := common.HexToAddress("0x3000000000000000000000000000000000000003")
+
+	client := newMockContractReader()
+	client.setStorage(proxy, EIP1967LogicSlot, implementation)
+	info, err := DetectProxyTarget(context.Background(), client, proxy)
+	assert.NoError(t, err)
+	assert.Equal(t, common.Address{}, info.Admin, "an empty admin slot leaves Admin unset")
+
+	client = newMockContractReader()
+	client.setStorage(proxy, EIP1967LogicSlot, implementation)
+	client.setStorage(proxy, EIP1967AdminSlot, admin)
+	info, err = DetectProxyTarget(context.Background(), client, proxy)
+	assert.NoError(t, err)
+	assert.Equal(t, implementation, info.Target)
+	assert.Equal(t, admin, info.Admin)
+}
+
+func TestDetectProxyTargetRejectsZeroTargets(t *testing.T) {
+	proxy := common.HexToAddress("0x1000000000000000000000000000000000000001")
+	beacon := common.HexToAddress("0x2000000000000000000000000000000000000002")
+
+	client := newMockContractReader()
+	client.setCall(proxy, EIP897Interface[0], common.Address{})
+	client.setStorage(proxy, EIP1967BeaconSlot, beacon)
+	client.setCallResult(beacon, EIP1167BeaconMethods[0], []byte{0x01, 0x02, 0x03, 0x04})
+	client.code[proxy] = common.FromHex("363d3d373d3d3d363d7300000000000000000000000000000000000000005af43d82803e903d91602b57fd5bf3")
+	_, err := DetectProxyTarget(context.Background(), client, proxy)
+	assert.Error(t, err, "getters, beacons and bytecode pointing to the zero address are not proxies")
+
+	implementation := common.HexToAddress("0x3000000000000000000000000000000000000003")
+	client.setStorage(proxy, EIP1967LogicSlot, implementation)
+	info, err := DetectProxyTarget(context.Background(), client, proxy)
+	assert.NoError(t, err)
+	assert.Equal(t, implementation, info.Target)
+}
+
+func TestGetTargetAddressIgnoresZeroTarget(t *testing.T) {
+	fetcher := NewABIFetcher(NewABIStorage(time.Hour, 0), map[int]ChainAPI{}, DefaultFetcherConfig())
+	address := "0x1000000000000000000000000000000000000001"
+
+	target, implementation := fetcher.getTargetAddress(address, &ProxyInfo{Type: "InterfaceCall"})
+	assert.Equal(t, address, target)
+	assert.Nil(t, implementation)
+	assert.False(t, (&ProxyInfo{Type: "InterfaceCall"}).hasTarget())
+	assert.True(t, (&ProxyInfo{Type: "Diamond", Facets: []common.Address{common.HexToAddress(address)}}).hasTarget(), "diamonds have facets instead")
+}
+
+func TestParse1167Bytecode(t *testing.T) {
+	implementation := "bebebebebebebebebebebebebebebebebebebebe"
+	tests := []struct {
+		name     string
+		bytecode string
+		target   string
+	}{
+		{
+			name:     "EIP-1167",
+			bytecode: "363d3d373d3d3d363d73" + implementation + "5af43d82803e903d91602b57fd5bf3",
+			target:   "0x" + implementation,
+		},
+		{
+			name:     "EIP-1167 with vanity address",
+			bytecode: "363d3d373d3d3d363d6e00112233445566778899aabbccddee5af43d82803e903d91602657fd5bf3",
+			target:   "0x0000000000112233445566778899aAbbccDdee",
+		},
+		{
+			name:     "EIP-1167 with immutable args",
+			bytecode: "363d3d373d3d3d363d73" + implementation + "5af43d82803e903d91602b57fd5bf3" + "000000000000000000000000000000000000000000000000000000000000002a",
+			target:   "0x" + implementation,
+		},
+		{
+			name:     "0age more-minimal",
+			bytecode: "3d3d3d3d363d3d37363d73" + implementation + "5af43d3d93803e602a57fd5bf3",
+			target:   "0x" + implementation,
+		},
+		{
+			name:     "Solady PUSH0",
+			bytecode: "5f5f365f5f37365f73" + implementation + "5af43d5f5f3e6029573d5ffd5b3d5ff3",
+			target:   "0x" + implementation,
+		},
+		{
+			name:     "wrong jump destination",
+			bytecode: "363d3d373d3d3d363d73" + implementation + "5af43d82803e903d91602a57fd5bf3",
+		},
+		{
+			name:     "truncated",
+			bytecode: "363d3d373d3d3d363d73bebebebe",
+		},
+		{
+			name:     "prefix only",
+			bytecode: "363d3d373d3d3d363d",
+		},
+		{
+			name:     "regular contract",
+			bytecode: "6080604052348015600f57600080fd5b50",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := parse1167Bytecode(common.FromHex(tt.bytecode))
+			if tt.target == "" {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, common.HexToAddress(tt.target), info.Target)
+			assert.Equal(t, "Eip1167", info.Type)
+			assert.True(t, info.Immutable)
+		})
+	}
+}
+
+// slowCodeReader delays CodeAt, so bytecode-based detection finishes last.
+type slowCodeReader struct {
+	*mockContractReader
+	delay time.Duration
+}
+
+func (r slowCodeReader) CodeAt(ctx context.Context, account common.Address, blockNumber *big.Int) ([]byte, error) {
+	time.Sleep(r.delay)
+	return r.mockContractReader.CodeAt(ctx, account, blockNumber)
+}
+
+func TestDetectionPrecedence(t *testing.T) {
+	proxy := common.HexToAddress("0x1000000000000000000000000000000000000001")
+	cloneTarget := common.HexToAddress("0x2000000000000000000000000000000000000002")
+	slotTarget := common.HexToAddress("0x3000000000000000000000000000000000000003")
+	interfaceTarget := common.HexToAddress("0x4000000000000000000000000000000000000004")
+
+	mock := newMockContractReader()
+	mock.code[proxy] = common.FromHex("363d3d373d3d3d363d73" + common.Bytes2Hex(cloneTarget.Bytes()) + "5af43d82803e903d91602b57fd5bf3")
+	mock.setStorage(proxy, EIP1967LogicSlot, slotTarget)
+	mock.setCall(proxy, EIP897Interface[0], interfaceTarget)
+	client := slowCodeReader{mockContractReader: mock, delay: 20 * time.Millisecond}
+
+	for i := 0; i < 5; i++ {
+		info, err := DetectProxyTarget(context.Background(), client, proxy)
+		assert.NoError(t, err)
+		assert.Equal(t, "Eip1167", info.Type, "the bytecode match outranks faster methods")
+		assert.Equal(t, cloneTarget, info.Target)
+	}
+
+	delete(mock.code, proxy)
+	info, err := DetectProxyTarget(context.Background(), client, proxy)
+	assert.NoError(t, err)
+	assert.Equal(t, "Eip1967Direct", info.Type, "the EIP-1967 slot outranks interface calls")
+	assert.Equal(t, slotTarget, info.Target)
+}
+
+// slowNodeReader answers CodeAt immediately but takes delay for every other
+// call, unless the call's context is cancelled first. It counts the calls the
+// node answered.
+type slowNodeReader struct {
+	*mockContractReader
+	delay    time.Duration
+	answered atomic.Int32
+}
+
+func (r *slowNodeReader) wait(ctx context.Context) error {
+	select {
+	case <-time.After(r.delay):
+		r.answered.Add(1)
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (r *slowNodeReader) CodeAt(ctx context.Context, account common.Address, blockNumber *big.Int) ([]byte, error) {
+	r.answered.Add(1)
+	return r.mockContractReader.CodeAt(ctx, account, blockNumber)
+}
+
+func (r *slowNodeReader) StorageAt(ctx context.Context, account common.Address, key common.Hash, blockNumber *big.Int) ([]byte, error) {
+	if err := r.wait(ctx); err != nil {
+		return nil, err
+	}
+	return r.mockContractReader.StorageAt(ctx, account, key, blockNumber)
+}
+
+func (r *slowNodeReader) CallContract(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	if err := r.wait(ctx); err != nil {
+		return nil, err
+	}
+	return r.mockContractReader.CallContract(ctx, msg, blockNumber)
+}
+
+func TestDetectionCancelsRemainingMethods(t *testing.T) {
+	proxy := common.HexToAddress("0x1000000000000000000000000000000000000001")
+	implementation := common.HexToAddress("0x2000000000000000000000000000000000000002")
+	mock := newMockContractReader()
+	mock.code[proxy] = common.FromHex("363d3d373d3d3d363d73" + common.Bytes2Hex(implementation.Bytes()) + "5af43d82803e903d91602b57fd5bf3")
+	// Detection waits for every method, so it only returns before the delay
+	// if the slower ones were cancelled.
+	client := &slowNodeReader{mockContractReader: mock, delay: time.Minute}
+
+	start := time.Now()
+	info, err := DetectProxyTarget(context.Background(), client, proxy)
+	assert.NoError(t, err)
+	assert.Equal(t, "Eip1167", info.Type)
+	assert.Less(t, time.Since(start), client.delay, "the bytecode match does not wait for slower methods")
+	assert.Equal(t, int32(1), client.answered.Load(), "no RPC call is answered after the first hit")
+}
+
+func TestSlot0Detection(t *testing.T) {
+	proxy := common.HexToAddress("0x1000000000000000000000000000000000000001")
+	masterCopy := common.HexToAddress("0x2000000000000000000000000000000000000002")
+	// PUSH1 0x00, DELEGATECALL
+	delegatingCode := []byte{0x60, 0x00, 0xf4}
+	// PUSH1 0xf4, STOP: 0xf4 only appears as push data
+	nonDelegatingCode := []byte{0x60, 0xf4, 0x00}
+
+	tests := []struct {
+		name        string
+		proxyCode   []byte
+		targetCode  []byte
+		expectProxy bool
+	}{
+		{name: "delegating proxy with deployed master copy", proxyCode: delegatingCode, targetCode: []byte{0x00}, expectProxy: true},
+		{name: "contract without DELEGATECALL", proxyCode: nonDelegatingCode, targetCode: []byte{0x00}, expectProxy: false},
+		{name: "slot 0 holds an account without code", proxyCode: delegatingCode, targetCode: nil, expectProxy: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newMockContractReader()
+			client.code[proxy] = tt.proxyCode
+			client.code[masterCopy] = tt.targetCode
+			client.setStorage(proxy, "0x0", masterCopy)
+
+			proxyInfo, err := DetectProxyTarget(context.Background(), client, proxy)
+			if !tt.expectProxy {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, masterCopy, proxyInfo.Target)
+			assert.Equal(t, "Slot0", proxyInfo.Type)
+		})
+	}
+}
+
+func TestContainsOpcode(t *testing.T) {
+	assert.True(t, containsOpcode([]byte{0xf4}, opDelegateCall))
+	assert.False(t, containsOpcode([]byte{0x7f, 0xf4}, opDelegateCall), "truncated PUSH32 data is skipped")
+	assert.True(t, containsOpcode(append(append([]byte{0x61}, 0xf4, 0xf4), 0xf4), opDelegateCall))
+	assert.False(t, containsOpcode([]byte{0x61, 0xf4, 0xf4}, opDelegateCall))
+}
+
+// encodeDiamondFacets ABI-encodes a facets() result with one selector per facet.
+func encodeDiamondFacets(t *testing.T, facets ...common.Address) []byte {
+	type facet struct {
+		FacetAddress      common.Address
+		FunctionSelectors [][4]byte
+	}
+	values := make([]facet, 0, len(facets))
+	for i, address := range facets {
+		values = append(values, facet{FacetAddress: address, FunctionSelectors: [][4]byte{{byte(i), 1, 2, 3}}})
+	}
+	encoded, err := diamondLoupeABI.Methods["facets"].Outputs.Pack(values)
+	assert.NoError(t, err)
+	return encoded
+}
+
+func TestDiamondDetection(t *testing.T) {
+	diamond := common.HexToAddress("0xd1a0000000000000000000000000000000000001")
+	cutFacet := common.HexToAddress("0xfa00000000000000000000000000000000000001")
+	loupeFacet := common.HexToAddress("0xfa00000000000000000000000000000000000002")
+
+	client := newMockContractReader()
+	client.code[diamond] = common.FromHex("0x6080")
+	client.setCallResult(diamond, DiamondFacetsMethod, encodeDiamondFacets(t, cutFacet, loupeFacet, cutFacet, common.Address{}))
+
+	proxyInfo, err := DetectProxyTarget(context.Background(), client, diamond)
+	assert.NoError(t, err)
+	assert.Equal(t, "Diamond", proxyInfo.Type)
+	assert.Equal(t, []common.Address{cutFacet, loupeFacet}, proxyInfo.Facets, "duplicate and zero facets are dropped")
+	assert.Equal(t, common.Address{}, proxyInfo.Target)
+
+	_, err = decodeDiamondFacets(encodeDiamondFacets(t))
+	assert.Error(t, err, "a diamond without facets is not a proxy")
+	_, err = decodeDiamondFacets([]byte{1, 2, 3})
+	assert.Error(t, err)
+}
+
+func TestRealDiamondDetection(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration tests in short mode")
+	}
+
+	client, err := ethclient.Dial("https://rpc.ankr.com/eth")
+	if err != nil {
+		t.Fatalf("Failed to connect to the Ethereum client: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// Beanstalk is an EIP-2535 diamond.
+	proxyInfo, err := DetectProxyTarget(ctx, client, common.HexToAddress("0xC1E088fC1323b20BCBee9bd1B9fC9546db5624C5"))
+	require.NoError(t, err)
+	require.NotNil(t, proxyInfo)
+	assert.Equal(t, "Diamond", proxyInfo.Type)
+	assert.Greater(t, len(proxyInfo.Facets), 1)
+}
+
+func TestImplementationGetterDetection(t *testing.T) {
+	proxy := common.HexToAddress("0x1000000000000000000000000000000000000001")
+	implementation := common.HexToAddress("0x2000000000000000000000000000000000000002")
+	assert.Equal(t, "0xaaf10f42", hexutil.Encode(crypto.Keccak256([]byte("getImplementation()"))[:4]))
+
+	previous := ImplementationGetters
+	defer func() { ImplementationGetters = previous }()
+	ImplementationGetters = append(ImplementationGetters, parseImplementationGetters("logic()")...)
+
+	tests := []struct {
+		name        string
+		selector    string
+		result      []byte
+		targetCode  []byte
+		expectProxy bool
+	}{
+		{name: "getImplementation()", selector: "0xaaf10f42", result: common.LeftPadBytes(implementation.Bytes(), 32), targetCode: []byte{0x00}, expectProxy: true},
+		{name: "configured getter", selector: hexutil.Encode(crypto.Keccak256([]byte("logic()"))[:4]), result: common.LeftPadBytes(implementation.Bytes(), 32), targetCode: []byte{0x00}, expectProxy: true},
+		{name: "target without code", selector: "0xaaf10f42", result: common.LeftPadBytes(implementation.Bytes(), 32), expectProxy: false},
+		{name: "word that is not an address", selector: "0xaaf10f42", result: common.RightPadBytes([]byte{0x01}, 32), targetCode: []byte{0x00}, expectProxy: false},
+		{name: "dynamic return value", selector: "0xaaf10f42", result: make([]byte, 64), targetCode: []byte{0x00}, expectProxy: false},
+		{name: "zero address", selector: "0xaaf10f42", result: make([]byte, 32), targetCode: []byte{0x00}, expectProxy: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newMockContractReader()
+			client.code[implementation] = tt.targetCode
+			client.setCallResult(proxy, tt.selector, tt.result)
+
+			proxyInfo, err := DetectProxyTarget(context.Background(), client, proxy)
+			if !tt.expectProxy {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, implementation, proxyInfo.Target)
+			assert.Equal(t, "InterfaceCall", proxyInfo.Type)
+			assert.False(t, proxyInfo.Immutable)
+		})
+	}
+}
+
+func TestParseImplementationGetters(t *testing.T) {
+	assert.Equal(t, []string{"0xaaf10f42", "0xd7dc8cd6", "0x5c60da1b"},
+		parseImplementationGetters(" getImplementation(), 0xD7DC8CD6,,implementation(),0x1234,logic,0xzzzzzzzz"))
+	assert.Empty(t, parseImplementationGetters(""))
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// Multicall3Address is the address Multicall3 is deployed at on most EVM
+// chains.
+var Multicall3Address = common.HexToAddress("0xcA11bde05977b3631167028862bE2a173976CA11")
+
+var multicall3ABI = mustParseABI(`[{"type":"function","name":"aggregate3","stateMutability":"payable","inputs":[{"name":"calls","type":"tuple[]","components":[{"name":"target","type":"address"},{"name":"allowFailure","type":"bool"},{"name":"callData","type":"bytes"}]}],"outputs":[{"name":"returnData","type":"tuple[]","components":[{"name":"success","type":"bool"},{"name":"returnData","type":"bytes"}]}]}]`)
+
+// proxyDetectionSlots are the storage slots DetectProxyTarget reads on a
+// proxy.
+var proxyDetectionSlots = []common.Hash{
+	common.HexToHash(EIP1967LogicSlot),
+	common.HexToHash(EIP1967BeaconSlot),
+	common.HexToHash(OpenZeppelinImplementationSlot),
+	common.HexToHash(EIP1822LogicSlot),
+	common.HexToHash(EIP1967AdminSlot),
+	{},
+}
+
+// proxyDetectionCalls returns the getters DetectProxyTarget calls on a proxy.
+func proxyDetectionCalls() [][]byte {
+	calls := [][]byte{
+		common.FromHex(EIP897Interface[0]),
+		common.FromHex(GnosisSafeProxyInterface[0]),
+		common.FromHex(ComptrollerProxyInterface[0]),
+		common.FromHex(DiamondFacetsMethod),
+	}
+	for _, selector := range ImplementationGetters {
+		calls = append(calls, common.FromHex(selector))
+	}
+	return calls
+}
+
+// BatchCaller sends several JSON-RPC requests in one round trip, like
+// *rpc.Client.
+type BatchCaller interface {
+	BatchCallContext(ctx context.Context, batch []rpc.BatchElem) error
+}
+
+// proxyReadPrefetcher is implemented by readers that can fetch the reads of
+// proxy detection up front.
+type proxyReadPrefetcher interface {
+	prefetchProxyReads(ctx context.Context, address common.Address) error
+}
+
+type callResult struct {
+	data []byte
+	err  error
+}
+
+// batchingReader serves the reads of proxy detection from a single JSON-RPC
+// batch instead of a round trip each, which matters on high-latency nodes.
+// Multicall3 cannot read another contract's storage, so storage slots and
+// code are batched as plain RPC requests, while the getters are aggregated
+// into one Multicall3 call when the chain has it. Reads that were not
+// prefetched, or whose prefetch failed, go to the wrapped reader.
+type batchingReader struct {
+	ContractReader
+	batcher BatchCaller
+	// block is the block prefetched reads are made at, or nil for latest.
+	block *big.Int
+
+	mu      sync.Mutex
+	code    map[common.Address][]byte
+	storage map[common.Address]map[common.Hash][]byte
+	calls   map[common.Address]map[string]callResult
+}
+
+func newBatchingReader(reader ContractReader, batcher BatchCaller, block *big.Int) *batchingReader {
+	return &batchingReader{
+		ContractReader: reader,
+		batcher:        batcher,
+		block:          block,
+		code:           make(map[common.Address][]byte),
+		storage:        make(map[common.Address]map[common.Hash][]byte),
+		calls:          make(map[common.Address]map[string]callResult),
+	}
+}
+
+func (b *batchingReader) prefetchProxyReads(ctx context.Context, address common.Address) error {
+	blockArg := "latest"
+	if b.block != nil {
+		blockArg = hexutil.EncodeBig(b.block)
+	}
+	calls := proxyDetectionCalls()
+	aggregate := make([]struct {
+		Target       common.Address
+		AllowFailure bool
+		CallData     []byte
+	}, len(calls))
+	for i, data := range calls {
+		aggregate[i].Target = address
+		aggregate[i].AllowFailure = true
+		aggregate[i].CallData = data
+	}
+	multicallData, err := multicall3ABI.Pack("aggregate3", aggregate)
+	if err != nil {
+		return err
+	}
+
+	// Multicall3 is looked up in the same batch: calling an address without
+	// code succeeds with empty data, which is discarded below.
+	var code, multicallCode, multicallResult hexutil.Bytes
+	batch := []rpc.BatchElem{
+		{Method: "eth_getCode", Args: []interface{}{address, blockArg}, Result: &code},
+		{Method: "eth_getCode", Args: []interface{}{Multicall3Address, blockArg}, Result: &multicallCode},
+		{Method: "eth_call", Args: []interface{}{map[string]interface{}{"to": Multicall3Address, "data": hexutil.Bytes(multicallData)}, blockArg}, Result: &multicallResult},
+	}
+	slots := make([]hexutil.Bytes, len(proxyDetectionSlots))
+	for i, slot := range proxyDetectionSlots {
+		batch = append(batch, rpc.BatchElem{Method: "eth_getStorageAt", Args: []interface{}{address, slot, blockArg}, Result: &slots[i]})
+	}
+	if err := b.batcher.BatchCallContext(ctx, batch); err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if batch[0].Error == nil {
+		b.code[address] = code
+	}
+	for i, slot := range proxyDetectionSlots {
+		if batch[3+i].Error == nil {
+			if b.storage[address] == nil {
+				b.storage[address] = make(map[common.Hash][]byte)
+			}
+			b.storage[address][slot] = slots[i]
+		}
+	}
+	if batch[1].Error != nil || len(multicallCode) == 0 || batch[2].Error != nil {
+		return nil
+	}
+	var results []struct {
+		Success    bool
+		ReturnData []byte
+	}
+	if err := multicall3ABI.UnpackIntoInterface(&results, "aggregate3", multicallResult); err != nil || len(results) != len(calls) {
+		return nil
+	}
+	b.calls[address] = make(map[string]callResult)
+	for i, result := range results {
+		if result.Success {
+			b.calls[address][string(calls[i])] = callResult{data: result.ReturnData}
+		} else {
+			b.calls[address][string(calls[i])] = callResult{err: errors.New("execution reverted")}
+		}
+	}
+	return nil
+}
+
+func (b *batchingReader) CodeAt(ctx context.Context, account common.Address, blockNumber *big.Int) ([]byte, error) {
+	if blockNumber == nil {
+		b.mu.Lock()
+		code, ok := b.code[account]
+		b.mu.Unlock()
+		if ok {
+			return code, nil
+		}
+	}
+	return b.ContractReader.CodeAt(ctx, account, blockNumber)
+}
+
+func (b *batchingReader) StorageAt(ctx context.Context, account common.Address, key common.Hash, blockNumber *big.Int) ([]byte, error) {
+	if blockNumber == nil {
+		b.mu.Lock()
+		value, ok := b.storage[account][key]
+		b.mu.Unlock()
+		if ok {
+			return value, nil
+		}
+	}
+	return b.ContractReader.StorageAt(ctx, account, key, blockNumber)
+}
+
+func (b *batchingReader) CallContract(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	if blockNumber == nil && msg.To != nil && msg.From == (common.Address{}) && msg.Value == nil {
+		b.mu.Lock()
+		result, ok := b.calls[*msg.To][string(msg.Data)]
+		b.mu.Unlock()
+		if ok {
+			return result.data, result.err
+		}
+	}
+	return b.ContractReader.CallContract(ctx, msg, blockNumber)
+}
+
+func (b *batchingReader) NonceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (uint64, error) {
+	accounts, ok := b.ContractReader.(AccountReader)
+	if !ok {
+		return 0, errors.New("reader cannot read account nonces")
+	}
+	return accounts.NonceAt(ctx, account, blockNumber)
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	prefetchProxy          = common.HexToAddress("0x1000000000000000000000000000000000000001")
+	prefetchImplementation = common.HexToAddress("0x2000000000000000000000000000000000000002")
+)
+
+// proxyNodeHandlers serve an EIP-1967 proxy and, when multicall is set,
+// Multicall3's aggregate3.
+func proxyNodeHandlers(multicall bool) map[string]rpcHandler {
+	return map[string]rpcHandler{
+		"eth_getCode": func(params []json.RawMessage) (interface{}, error) {
+			switch address := rpcParam[common.Address](params, 0); {
+			case address == prefetchProxy || address == prefetchImplementation:
+				return hexutil.Bytes(append([]byte{0x60, 0x80}, address.Bytes()...)), nil
+			case address == Multicall3Address && multicall:
+				return hexutil.Bytes{0x60, 0x80}, nil
+			default:
+				return hexutil.Bytes{}, nil
+			}
+		},
+		"eth_getStorageAt": func(params []json.RawMessage) (interface{}, error) {
+			address, slot := rpcParam[common.Address](params, 0), rpcParam[common.Hash](params, 1)
+			if address == prefetchProxy && slot == common.HexToHash(EIP1967LogicSlot) {
+				return common.BytesToHash(prefetchImplementation.Bytes()), nil
+			}
+			return common.Hash{}, nil
+		},
+		"eth_call": func(params []json.RawMessage) (interface{}, error) {
+			call := rpcParam[rpcCall](params, 0)
+			if call.To != Multicall3Address {
+				return nil, errExecutionReverted
+			}
+			if !multicall {
+				// Calling an address without code succeeds with no data.
+				return hexutil.Bytes{}, nil
+			}
+			args, err := multicall3ABI.Methods["aggregate3"].Inputs.Unpack(call.calldata()[4:])
+			if err != nil {
+				return nil, err
+			}
+			// None of the getters exist on the proxy, so every call reverts.
+			results := make([]struct {
+				Success    bool
+				ReturnData []byte
+			}, reflect.ValueOf(args[0]).Len())
+			data, _ := multicall3ABI.Methods["aggregate3"].Outputs.Pack(results)
+			return hexutil.Bytes(data), nil
+		},
+	}
+}
+
+func detectOverNode(tb testing.TB, node *fakeNode, batching bool) *ProxyInfo {
+	client, err := ethclient.Dial(node.URL)
+	require.NoError(tb, err)
+	defer client.Close()
+	budgeted := newBudgetedReader(client, 4)
+	var reader ContractReader = budgeted
+	if batching {
+		reader = newBatchingReader(reader, newBudgetedBatcher(client.Client(), budgeted), nil)
+	}
+	info, err := DetectProxyTarget(context.Background(), reader, prefetchProxy)
+	require.NoError(tb, err)
+	return info
+}
+
+func TestBatchingReaderDetectsProxyInFewerRoundTrips(t *testing.T) {
+	for _, multicall := range []bool{true, false} {
+		individual := newFakeNode(t, proxyNodeHandlers(multicall))
+		batched := newFakeNode(t, proxyNodeHandlers(multicall))
+
+		want := detectOverNode(t, individual, false)
+		got := detectOverNode(t, batched, true)
+		assert.Equal(t, want, got, "multicall %v", multicall)
+		assert.Equal(t, prefetchImplementation, got.Target)
+		assert.Equal(t, "Eip1967Direct", got.Type)
+		assert.Less(t, batched.roundTrips.Load(), individual.roundTrips.Load(), "multicall %v", multicall)
+	}
+}
+
+func TestBatchingReaderFallsBackWithoutMulticall(t *testing.T) {
+	node := newFakeNode(t, proxyNodeHandlers(false))
+	client, err := ethclient.Dial(node.URL)
+	require.NoError(t, err)
+	defer client.Close()
+
+	reader := newBatchingReader(client, client.Client(), nil)
+	require.NoError(t, reader.prefetchProxyReads(context.Background(), prefetchProxy))
+	assert.Empty(t, reader.calls, "getter results are not taken from an address without code")
+	assert.Len(t, reader.storage[prefetchProxy], len(proxyDetectionSlots))
+
+	before := node.roundTrips.Load()
+	_, err = reader.CallContract(context.Background(), ethereum.CallMsg{To: &prefetchProxy, Data: common.FromHex(EIP897Interface[0])}, nil)
+	assert.Error(t, err, "the getter is called on the proxy itself")
+	assert.Equal(t, before+1, node.roundTrips.Load())
+}
+
+// countingBatcher counts the batches sent through it without sending them.
+type countingBatcher struct {
+	batches atomic.Int32
+}
+
+func (c *countingBatcher) BatchCallContext(ctx context.Context, batch []rpc.BatchElem) error {
+	c.batches.Add(1)
+	return nil
+}
+
+func TestBatchingReaderStaysWithinBudgets(t *testing.T) {
+	previous := proxyDetectionBudget
+	proxyDetectionBudget = make(chan struct{}, 1)
+	defer func() { proxyDetectionBudget = previous }()
+
+	t.Run("request budget spent", func(t *testing.T) {
+		batcher := &countingBatcher{}
+		budgeted := newBudgetedReader(newMockContractReader(), 1)
+		require.NoError(t, budgeted.acquire(context.Background()))
+		reader := newBatchingReader(budgeted, newBudgetedBatcher(batcher, budgeted), nil)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		assert.ErrorIs(t, reader.prefetchProxyReads(ctx, prefetchProxy), context.DeadlineExceeded)
+		assert.Zero(t, batcher.batches.Load(), "the batch is not sent")
+		assert.Empty(t, proxyDetectionBudget, "the global slot is given back")
+
+		budgeted.release()
+		assert.NoError(t, reader.prefetchProxyReads(context.Background(), prefetchProxy))
+		assert.Equal(t, int32(1), batcher.batches.Load(), "a batch counts as one call")
+	})
+
+	t.Run("global budget spent", func(t *testing.T) {
+		batcher := &countingBatcher{}
+		budgeted := newBudgetedReader(newMockContractReader(), 1)
+		reader := newBatchingReader(budgeted, newBudgetedBatcher(batcher, budgeted), nil)
+		proxyDetectionBudget <- struct{}{}
+		defer func() { <-proxyDetectionBudget }()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		assert.ErrorIs(t, reader.prefetchProxyReads(ctx, prefetchProxy), context.DeadlineExceeded)
+		assert.Zero(t, batcher.batches.Load())
+	})
+}
+
+func benchmarkProxyDetection(b *testing.B, batching bool) {
+	node := newSlowFakeNode(b, 20*time.Millisecond, proxyNodeHandlers(true))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		detectOverNode(b, node, batching)
+	}
+	b.ReportMetric(float64(node.roundTrips.Load())/float64(b.N), "roundtrips/op")
+}
+
+func BenchmarkProxyDetectionIndividualReads(b *testing.B) { benchmarkProxyDetection(b, false) }
+
+func BenchmarkProxyDetectionBatchedReads(b *testing.B) { benchmarkProxyDetection(b, true) }
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// minWatchInterval bounds how often watched proxies are re-checked, so a
+// misconfiguration cannot turn the watcher into a load test of the RPC nodes.
+const minWatchInterval = time.Minute
+
+// WatchTarget is a proxy whose implementation is watched for upgrades.
+type WatchTarget struct {
+	ChainID string
+	Address string
+}
+
+// UpgradeNotification is posted to the webhook when a watched proxy's
+// implementation changed.
+type UpgradeNotification struct {
+	ChainID           string    `json:"chainId"`
+	Address           string    `json:"address"`
+	ProxyType         string    `json:"proxyType"`
+	OldImplementation string    `json:"oldImplementation"`
+	NewImplementation string    `json:"newImplementation"`
+	DetectedAt        time.Time `json:"detectedAt"`
+}
+
+// parseWatchTargets parses comma-separated chainId:address pairs, skipping
+// invalid ones with a warning.
+func parseWatchTargets(value string) []WatchTarget {
+	var targets []WatchTarget
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		chainId, address, found := strings.Cut(entry, ":")
+		if _, err := strconv.Atoi(chainId); err != nil || !found || !common.IsHexAddress(address) {
+			slog.Warn("invalid watch target, ignoring it: expected chainId:address", "value", entry)
+			continue
+		}
+		targets = append(targets, WatchTarget{ChainID: chainId, Address: common.HexToAddress(address).Hex()})
+	}
+	return targets
+}
+
+// StartProxyWatcher re-checks the implementation of every watched proxy each
+// config.WatchInterval until ctx is cancelled, posting an UpgradeNotification
+// to config.WebhookURL when it changed. It does nothing unless both a webhook
+// and watch targets are configured. The proxies are checked one at a time
+// using their chain's default RPC URL.
+func (af *ABIFetcher) StartProxyWatcher(ctx context.Context) {
+	if af.config.WebhookURL == "" || len(af.config.WatchTargets) == 0 {
+		return
+	}
+	interval := af.config.WatchInterval
+	if interval < minWatchInterval {
+		af.logger.Warn("watch interval too short, using the minimum", "interval", interval, "minimum", minWatchInterval)
+		interval = minWatchInterval
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			af.checkWatchedProxies(ctx)
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// checkWatchedProxies checks every watch target once, logging failures.
+func (af *ABIFetcher) checkWatchedProxies(ctx context.Context) {
+	for _, target := range af.config.WatchTargets {
+		if ctx.Err() != nil {
+			return
+		}
+		checkCtx, cancel := context.WithTimeout(ctx, af.config.DialTimeout+time.Minute)
+		if err := af.checkWatchedProxy(checkCtx, target); err != nil {
+			af.logger.Warn("failed to check watched proxy", "chainId", target.ChainID, "address", target.Address, "error", err)
+		}
+		cancel()
+	}
+}
+
+// checkWatchedProxy compares the cached implementation of target with the one
+// it points to now. A target not cached yet is fetched, which records the
+// implementation to compare against next time. Once the webhook accepted a
+// notification, the cached entry is refetched so it serves the new
+// implementation's ABI.
+func (af *ABIFetcher) checkWatchedProxy(ctx context.Context, target WatchTarget) error {
+	rpcURL := af.defaultRPCURL(target.ChainID)
+	if rpcURL == "" {
+		return fmt.Errorf("chain %s has no default RPC URL", target.ChainID)
+	}
+	key := abiCacheKey(target.ChainID, target.Address, nil)
+	item, ok := af.storage.Get(key)
+	if !ok {
+		_, _, err := af.lookupABI(ctx, target.ChainID, target.Address, rpcURL, FetchOptions{})
+		return err
+	}
+	previous, ok := item.Implementation.(string)
+	if !ok || !item.IsProxy || item.Error != "" {
+		// Diamonds, contracts that are not proxies and contracts without an
+		// ABI have no implementation to watch.
+		return nil
+	}
+
+	client, err := af.dial(ctx, rpcURL)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	proxyInfo, _ := resolveProxyChain(ctx, af.newReader(client, nil), common.HexToAddress(target.Address))
+	if proxyInfo == nil || proxyInfo.Target == (common.Address{}) || proxyInfo.Target.Hex() == previous {
+		return nil
+	}
+
+	notification := UpgradeNotification{
+		ChainID:           target.ChainID,
+		Address:           target.Address,
+		ProxyType:         proxyInfo.Type,
+		OldImplementation: previous,
+		NewImplementation: proxyInfo.Target.Hex(),
+		DetectedAt:        time.Now().UTC(),
+	}
+	af.logger.Info("watched proxy upgraded", "chainId", target.ChainID, "address", target.Address, "oldImplementation", previous, "newImplementation", notification.NewImplementation)
+	if err := postWebhook(ctx, af.config.WebhookURL, notification); err != nil {
+		return err
+	}
+	if _, _, err := af.lookupABI(ctx, target.ChainID, target.Address, rpcURL, FetchOptions{Force: true}); err != nil {
+		// Without the stale entry the next check starts over rather than
+		// notifying about the same upgrade again.
+		af.storage.Delete(key)
+		return err
+	}
+	return nil
+}
+
+// postWebhook posts notification as JSON to url, failing unless it responds
+// with a 2xx status.
+func postWebhook(ctx context.Context, url string, notification UpgradeNotification) error {
+	body, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := webhookHTTPClient.Do(req)
+	if err != nil {
+		return &NetworkError{err: err}
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// rpcChainAPI is a staticChainAPI whose chain has a default RPC URL.
+type rpcChainAPI struct {
+	staticChainAPI
+	rpcURL string
+}
+
+func (r rpcChainAPI) DefaultRPCURL() string {
+	return r.rpcURL
+}
+
+// upgradeableProxyNode is a node serving an EIP-1967 proxy whose
+// implementation can be changed.
+func upgradeableProxyNode(t *testing.T, proxy common.Address, implementation *atomic.Value) *fakeNode {
+	return newFakeNode(t, map[string]rpcHandler{
+		"eth_getCode": func(params []json.RawMessage) (interface{}, error) {
+			return hexutil.Bytes{0x60, 0x80}, nil
+		},
+		"eth_getStorageAt": func(params []json.RawMessage) (interface{}, error) {
+			address, slot := rpcParam[common.Address](params, 0), rpcParam[common.Hash](params, 1)
+			if address == proxy && slot == common.HexToHash(EIP1967LogicSlot) {
+				return common.BytesToHash(implementation.Load().(common.Address).Bytes()), nil
+			}
+			return common.Hash{}, nil
+		},
+		"eth_call": func(params []json.RawMessage) (interface{}, error) {
+			return nil, errExecutionReverted
+		},
+	})
+}
+
+func TestCheckWatchedProxy(t *testing.T) {
+	proxy := common.HexToAddress("0x1000000000000000000000000000000000000001")
+	first := common.HexToAddress("0x2000000000000000000000000000000000000002")
+	second := common.HexToAddress("0x3000000000000000000000000000000000000003")
+	third := common.HexToAddress("0x4000000000000000000000000000000000000004")
+	var implementation atomic.Value
+	implementation.Store(first)
+	node := upgradeableProxyNode(t, proxy, &implementation)
+
+	var mu sync.Mutex
+	var notifications []UpgradeNotification
+	var webhookStatus atomic.Int32
+	webhookStatus.Store(http.StatusNoContent)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var notification UpgradeNotification
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&notification))
+		mu.Lock()
+		notifications = append(notifications, notification)
+		mu.Unlock()
+		w.WriteHeader(int(webhookStatus.Load()))
+	}))
+	defer webhook.Close()
+
+	config := DefaultFetcherConfig()
+	config.DisableDecompilation = true
+	config.WebhookURL = webhook.URL
+	explorer := rpcChainAPI{rpcURL: node.URL, staticChainAPI: staticChainAPI{
+		first.Hex():  `[{"type":"function","name":"first","inputs":[],"outputs":[],"stateMutability":"view"}]`,
+		second.Hex(): `[{"type":"function","name":"second","inputs":[],"outputs":[],"stateMutability":"view"}]`,
+		third.Hex():  `[{"type":"function","name":"third","inputs":[],"outputs":[],"stateMutability":"view"}]`,
+	}}
+	fetcher := newTestFetcher(t, config, map[int]ChainAPI{1: explorer})
+	target := WatchTarget{ChainID: "1", Address: proxy.Hex()}
+	cached := func() StorageItem {
+		item, _ := fetcher.storage.Get(abiCacheKey("1", proxy.Hex(), nil))
+		return item
+	}
+
+	// The first check records the implementation to compare against.
+	assert.NoError(t, fetcher.checkWatchedProxy(context.Background(), target))
+	assert.Equal(t, first.Hex(), cached().Implementation)
+	assert.NoError(t, fetcher.checkWatchedProxy(context.Background(), target))
+	assert.Empty(t, notifications, "an unchanged implementation is not reported")
+
+	implementation.Store(second)
+	assert.NoError(t, fetcher.checkWatchedProxy(context.Background(), target))
+	if assert.Len(t, notifications, 1) {
+		assert.Equal(t, "1", notifications[0].ChainID)
+		assert.Equal(t, proxy.Hex(), notifications[0].Address)
+		assert.Equal(t, "Eip1967Direct", notifications[0].ProxyType)
+		assert.Equal(t, first.Hex(), notifications[0].OldImplementation)
+		assert.Equal(t, second.Hex(), notifications[0].NewImplementation)
+	}
+	assert.Equal(t, second.Hex(), cached().Implementation, "the cache serves the new implementation")
+	assert.Contains(t, cached().ABI, "second")
+	assert.NoError(t, fetcher.checkWatchedProxy(context.Background(), target))
+	assert.Len(t, notifications, 1, "an upgrade is reported once")
+
+	// An upgrade the webhook did not accept is reported again next time.
+	implementation.Store(third)
+	webhookStatus.Store(http.StatusInternalServerError)
+	assert.Error(t, fetcher.checkWatchedProxy(context.Background(), target))
+	assert.Equal(t, second.Hex(), cached().Implementation)
+	webhookStatus.Store(http.StatusOK)
+	assert.NoError(t, fetcher.checkWatchedProxy(context.Background(), target))
+	assert.Len(t, notifications, 3)
+	assert.Equal(t, third.Hex(), cached().Implementation)
+}
+
+func TestParseWatchTargets(t *testing.T) {
+	targets := parseWatchTargets(" 1:0x1000000000000000000000000000000000000001, 137:0xabcdef0000000000000000000000000000000002,mainnet:0x1000000000000000000000000000000000000001,1:0x1234,1,")
+	assert.Equal(t, []WatchTarget{
+		{ChainID: "1", Address: "0x1000000000000000000000000000000000000001"},
+		{ChainID: "137", Address: common.HexToAddress("0xabcdef0000000000000000000000000000000002").Hex()},
+	}, targets)
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisKeyPrefix namespaces the service's keys in a shared Redis instance.
+const redisKeyPrefix = "get-abi:"
+
+// RedisStorage stores ABIs in Redis so that several replicas share one cache.
+// Entries expire through Redis' own key expiry. Redis failures are logged and
+// treated as cache misses, so an unavailable Redis degrades to refetching.
+type RedisStorage struct {
+	client  *redis.Client
+	ttl     time.Duration
+	timeout time.Duration
+}
+
+// NewRedisStorage connects to the Redis server at redisURL, e.g.
+// redis://localhost:6379/0. A ttl of zero keeps entries forever.
+func NewRedisStorage(redisURL string, ttl time.Duration) (*RedisStorage, error) {
+	options, err := redis.ParseURL(redisURL)
+	if err != nil {
+		return nil, err
+	}
+	return &RedisStorage{
+		client:  redis.NewClient(options),
+		ttl:     ttl,
+		timeout: 2 * time.Second,
+	}, nil
+}
+
+func (s *RedisStorage) Get(key string) (StorageItem, bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	data, err := s.client.Get(ctx, redisKeyPrefix+key).Bytes()
+	if err != nil {
+		if !errors.Is(err, redis.Nil) {
+			slog.Error("failed to read from Redis", "key", key, "error", err)
+		}
+		return StorageItem{}, false
+	}
+	var item StorageItem
+	if err := json.Unmarshal(data, &item); err != nil {
+		slog.Error("failed to decode item from Redis", "key", key, "error", err)
+		return StorageItem{}, false
+	}
+	return item, true
+}
+
+func (s *RedisStorage) Set(key string, item StorageItem) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	if item.StoredAt.IsZero() {
+		item.StoredAt = time.Now()
+	}
+	data, err := json.Marshal(item)
+	if err != nil {
+		slog.Error("failed to encode item for Redis", "key", key, "error", err)
+		return
+	}
+	ttl := s.ttl
+	if item.TTL > 0 {
+		ttl = item.TTL
+	}
+	// Items expire relative to StoredAt, like in ABIStorage, so re-storing
+	// an item to add derived fields does not extend its lifetime.
+	if ttl > 0 {
+		ttl -= time.Since(item.StoredAt)
+		if ttl <= 0 {
+			return
+		}
+	}
+	if err := s.client.Set(ctx, redisKeyPrefix+key, data, ttl).Err(); err != nil {
+		slog.Error("failed to write to Redis", "key", key, "error", err)
+	}
+}
+
+func (s *RedisStorage) Delete(key string) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	deleted, err := s.client.Del(ctx, redisKeyPrefix+key).Result()
+	if err != nil {
+		slog.Error("failed to delete from Redis", "key", key, "error", err)
+		return false
+	}
+	return deleted > 0
+}
+
+func (s *RedisStorage) DeletePrefix(prefix string) int {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	deleted := 0
+	iter := s.client.Scan(ctx, 0, redisKeyPrefix+prefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		n, err := s.client.Del(ctx, iter.Val()).Result()
+		if err != nil {
+			slog.Error("failed to delete from Redis", "key", iter.Val(), "error", err)
+			continue
+		}
+		deleted += int(n)
+	}
+	if err := iter.Err(); err != nil {
+		slog.Error("failed to scan Redis", "prefix", prefix, "error", err)
+	}
+	return deleted
+}
+
+// Close releases the Redis connection pool.
+func (s *RedisStorage) Close() error {
+	return s.client.Close()
+}
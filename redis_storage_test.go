@@ -0,0 +1,104 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestRedisStorage(t *testing.T, ttl time.Duration) (*RedisStorage, *miniredis.Miniredis) {
+	server := miniredis.RunT(t)
+	storage, err := NewRedisStorage("redis://"+server.Addr(), ttl)
+	require.NoError(t, err)
+	t.Cleanup(func() { storage.Close() })
+	return storage, server
+}
+
+func TestRedisStorageParity(t *testing.T) {
+	redisStorage, _ := newTestRedisStorage(t, time.Hour)
+	backends := map[string]StorageBackend{
+		"memory": NewABIStorage(time.Hour, 0),
+		"redis":  redisStorage,
+	}
+
+	storedAt := time.Now().UTC().Truncate(time.Second)
+	items := map[string]StorageItem{
+		"1-0xproxy": {
+			ABI:               `[{"type":"function","name":"foo"}]`,
+			Implementation:    "0x0000000000000000000000000000000000000001",
+			IsProxy:           true,
+			Admin:             "0x0000000000000000000000000000000000000002",
+			AdminOwner:        "0x0000000000000000000000000000000000000003",
+			PartialDelegation: true,
+			StoredAt:          storedAt,
+		},
+		"1-0xplain": {
+			ABI:          `[]`,
+			IsDecompiled: true,
+			RawABI:       `[{"type":"function"}]`,
+			StoredAt:     storedAt,
+		},
+	}
+
+	for name, backend := range backends {
+		t.Run(name, func(t *testing.T) {
+			for key, item := range items {
+				backend.Set(key, item)
+			}
+			for key, want := range items {
+				got, ok := backend.Get(key)
+				assert.True(t, ok, key)
+				assert.Equal(t, want, got, key)
+			}
+
+			_, ok := backend.Get("1-0xmissing")
+			assert.False(t, ok)
+
+			assert.True(t, backend.Delete("1-0xplain"))
+			assert.False(t, backend.Delete("1-0xplain"))
+			_, ok = backend.Get("1-0xplain")
+			assert.False(t, ok)
+
+			backend.Set("1-0xproxy@100", StorageItem{ABI: "[]"})
+			backend.Set("1-0xproxy@200", StorageItem{ABI: "[]"})
+			assert.Equal(t, 2, backend.DeletePrefix("1-0xproxy@"))
+			assert.Zero(t, backend.DeletePrefix("1-0xproxy@"))
+			_, ok = backend.Get("1-0xproxy")
+			assert.True(t, ok, "only keys with the prefix are deleted")
+		})
+	}
+}
+
+func TestRedisStorageExpiry(t *testing.T) {
+	storage, server := newTestRedisStorage(t, time.Hour)
+	storage.Set("key", StorageItem{ABI: "abi"})
+
+	item, ok := storage.Get("key")
+	assert.True(t, ok)
+	assert.False(t, item.StoredAt.IsZero(), "Set stamps the storage time")
+
+	server.FastForward(time.Hour)
+	_, ok = storage.Get("key")
+	assert.False(t, ok, "Redis expires the entry after the TTL")
+
+	item.StoredAt = time.Now().Add(-50 * time.Minute)
+	storage.Set("key", item)
+	assert.InDelta(t, 10*time.Minute, server.TTL(redisKeyPrefix+"key"), float64(time.Second), "re-storing an item keeps its expiry")
+
+	item.StoredAt = time.Now().Add(-2 * time.Hour)
+	storage.Set("stale", item)
+	_, ok = storage.Get("stale")
+	assert.False(t, ok, "an item past its TTL is not stored again")
+}
+
+func TestRedisStorageUnavailable(t *testing.T) {
+	storage, server := newTestRedisStorage(t, time.Hour)
+	server.Close()
+
+	storage.Set("key", StorageItem{ABI: "abi"})
+	_, ok := storage.Get("key")
+	assert.False(t, ok, "an unreachable Redis behaves like an empty cache")
+}
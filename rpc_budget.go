@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// budgetedReader caps the number of RPC calls in flight through it. One is
+// created per request so that no contract, however many slots or facets it
+// makes us probe, can fan out beyond the budget. Calls over the budget wait
+// for a free slot instead of failing.
+type budgetedReader struct {
+	reader ContractReader
+	slots  chan struct{}
+}
+
+// proxyDetectionBudget caps the RPC calls proxy detection has in flight across
+// all requests. Every detection still probes its methods in parallel, but
+// under load they queue here instead of flooding the RPC nodes. main sizes it
+// from the environment.
+var proxyDetectionBudget = make(chan struct{}, 256)
+
+// newDetectionReader returns reader with its calls counted against
+// proxyDetectionBudget.
+func newDetectionReader(reader ContractReader) *budgetedReader {
+	return &budgetedReader{reader: reader, slots: proxyDetectionBudget}
+}
+
+func newBudgetedReader(reader ContractReader, budget int) *budgetedReader {
+	if budget < 1 {
+		budget = 1
+	}
+	return &budgetedReader{reader: reader, slots: make(chan struct{}, budget)}
+}
+
+func (b *budgetedReader) acquire(ctx context.Context) error {
+	return acquireSlot(ctx, b.slots)
+}
+
+func (b *budgetedReader) release() {
+	<-b.slots
+}
+
+// acquireSlot waits for a free slot in slots, or for ctx to be done.
+func acquireSlot(ctx context.Context, slots chan struct{}) error {
+	select {
+	case slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// budgetedBatcher sends JSON-RPC batches within the budget of a request and
+// proxyDetectionBudget. A batch counts as one call against each, however
+// many requests it carries: it is a single round trip, which is what the
+// budgets bound.
+type budgetedBatcher struct {
+	batcher BatchCaller
+	// budgets are acquired in order, proxyDetectionBudget first like for
+	// the reads of detection, so that waiting batches and reads cannot
+	// deadlock.
+	budgets []chan struct{}
+}
+
+// newBudgetedBatcher returns batcher with its batches counted against the
+// budget of request and proxyDetectionBudget.
+func newBudgetedBatcher(batcher BatchCaller, request *budgetedReader) *budgetedBatcher {
+	return &budgetedBatcher{batcher: batcher, budgets: []chan struct{}{proxyDetectionBudget, request.slots}}
+}
+
+func (b *budgetedBatcher) BatchCallContext(ctx context.Context, batch []rpc.BatchElem) error {
+	for _, slots := range b.budgets {
+		if err := acquireSlot(ctx, slots); err != nil {
+			return err
+		}
+		defer func(slots chan struct{}) { <-slots }(slots)
+	}
+	return b.batcher.BatchCallContext(ctx, batch)
+}
+
+func (b *budgetedReader) CodeAt(ctx context.Context, account common.Address, blockNumber *big.Int) ([]byte, error) {
+	if err := b.acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer b.release()
+	return b.reader.CodeAt(ctx, account, blockNumber)
+}
+
+func (b *budgetedReader) StorageAt(ctx context.Context, account common.Address, key common.Hash, blockNumber *big.Int) ([]byte, error) {
+	if err := b.acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer b.release()
+	return b.reader.StorageAt(ctx, account, key, blockNumber)
+}
+
+func (b *budgetedReader) CallContract(ctx context.Context, msg ethereum.CallMsg, blockNumber *big.Int) ([]byte, error) {
+	if err := b.acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer b.release()
+	return b.reader.CallContract(ctx, msg, blockNumber)
+}
+
+func (b *budgetedReader) NonceAt(ctx context.Context, account common.Address, blockNumber *big.Int) (uint64, error) {
+	accounts, ok := b.reader.(AccountReader)
+	if !ok {
+		return 0, errors.New("reader cannot read account nonces")
+	}
+	if err := b.acquire(ctx); err != nil {
+		return 0, err
+	}
+	defer b.release()
+	return accounts.NonceAt(ctx, account, blockNumber)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"math/big"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+)
+
+// slowContractReader records the peak number of concurrent StorageAt calls.
+type slowContractReader struct {
+	*mockContractReader
+	inFlight atomic.Int32
+	peak     atomic.Int32
+}
+
+func (s *slowContractReader) StorageAt(ctx context.Context, account common.Address, key common.Hash, blockNumber *big.Int) ([]byte, error) {
+	current := s.inFlight.Add(1)
+	defer s.inFlight.Add(-1)
+	for {
+		peak := s.peak.Load()
+		if current <= peak || s.peak.CompareAndSwap(peak, current) {
+			break
+		}
+	}
+	time.Sleep(10 * time.Millisecond)
+	return s.mockContractReader.StorageAt(ctx, account, key, blockNumber)
+}
+
+func TestBudgetedReaderCapsConcurrency(t *testing.T) {
+	slow := &slowContractReader{mockContractReader: newMockContractReader()}
+	reader := newBudgetedReader(slow, 2)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := reader.StorageAt(context.Background(), common.Address{}, common.Hash{}, nil)
+			assert.NoError(t, err, "calls over budget wait rather than fail")
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(2), slow.peak.Load())
+}
+
+func TestBudgetedReaderRespectsContext(t *testing.T) {
+	reader := newBudgetedReader(newMockContractReader(), 1)
+	assert.NoError(t, reader.acquire(context.Background()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := reader.CodeAt(ctx, common.Address{}, nil)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestProxyDetectionBudgetCapsConcurrentRequests(t *testing.T) {
+	previous := proxyDetectionBudget
+	proxyDetectionBudget = make(chan struct{}, 3)
+	defer func() { proxyDetectionBudget = previous }()
+
+	slow := &slowContractReader{mockContractReader: newMockContractReader()}
+	proxy := common.HexToAddress("0x1000000000000000000000000000000000000001")
+	implementation := common.HexToAddress("0x2000000000000000000000000000000000000002")
+	slow.code[proxy] = []byte{0x60, 0x80}
+	slow.setStorage(proxy, EIP1967LogicSlot, implementation)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// Each request has a budget of its own, which alone would let
+			// the requests together make far more calls at once.
+			info, err := DetectProxyTarget(context.Background(), newBudgetedReader(slow, 8), proxy)
+			if assert.NoError(t, err) {
+				assert.Equal(t, implementation, info.Target)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.LessOrEqual(t, slow.peak.Load(), int32(3))
+	assert.Empty(t, proxyDetectionBudget, "every slot is released")
+}
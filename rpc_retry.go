@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// proxyDetectionRetry retries proxy detection methods that failed on a
+// transient RPC error, which free-tier nodes return intermittently.
+var proxyDetectionRetry = RetryPolicy{MaxRetries: 2, BaseDelay: 100 * time.Millisecond}
+
+// withRPCRetry runs call, retrying it with backoff as long as it fails with a
+// transient RPC error and policy allows. Any other failure, such as a revert
+// or an empty slot, is returned at once.
+func withRPCRetry[T any](ctx context.Context, policy RetryPolicy, call func() (T, error)) (T, error) {
+	for retry := 0; ; retry++ {
+		result, err := call()
+		if err == nil || retry >= policy.MaxRetries || ctx.Err() != nil || !isTransientRPCError(err) {
+			return result, err
+		}
+		select {
+		case <-time.After(policy.backoff(retry)):
+		case <-ctx.Done():
+			return result, err
+		}
+	}
+}
+
+// isTransientRPCError reports whether err is an RPC failure that may go away
+// on its own: rate limiting, an overloaded or unavailable node, a timeout or a
+// dropped connection.
+func isTransientRPCError(err error) bool {
+	var httpErr rpc.HTTPError
+	if errors.As(err, &httpErr) {
+		switch httpErr.StatusCode {
+		case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+	var rpcErr rpc.Error
+	if errors.As(err, &rpcErr) {
+		// -32005 is the "limit exceeded" code of EIP-1474, though some nodes
+		// only say so in the message.
+		return rpcErr.ErrorCode() == -32005 || strings.Contains(strings.ToLower(rpcErr.Error()), "rate limit")
+	}
+	var netErr net.Error
+	return errors.Is(err, context.DeadlineExceeded) ||
+		errors.As(err, &netErr) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET)
+}
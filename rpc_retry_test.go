@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/assert"
+)
+
+// flakyReader fails the first reads of a storage slot with err.
+type flakyReader struct {
+	*mockContractReader
+	slot     common.Hash
+	failures atomic.Int64
+	err      error
+	reads    atomic.Int64
+}
+
+func (r *flakyReader) StorageAt(ctx context.Context, account common.Address, key common.Hash, blockNumber *big.Int) ([]byte, error) {
+	if key == r.slot {
+		r.reads.Add(1)
+		if r.failures.Add(-1) >= 0 {
+			return nil, r.err
+		}
+	}
+	return r.mockContractReader.StorageAt(ctx, account, key, blockNumber)
+}
+
+type rpcCodeError struct {
+	code    int
+	message string
+}
+
+func (e rpcCodeError) Error() string  { return e.message }
+func (e rpcCodeError) ErrorCode() int { return e.code }
+
+func TestDetectProxyTargetRetriesTransientErrors(t *testing.T) {
+	previous := proxyDetectionRetry
+	defer func() { proxyDetectionRetry = previous }()
+	proxyDetectionRetry = RetryPolicy{MaxRetries: 2, BaseDelay: time.Millisecond}
+
+	proxy := common.HexToAddress("0x1000000000000000000000000000000000000001")
+	implementation := common.HexToAddress("0x2000000000000000000000000000000000000002")
+	newReader := func(failures int64, err error) *flakyReader {
+		reader := &flakyReader{mockContractReader: newMockContractReader(), slot: common.HexToHash(EIP1967LogicSlot), err: err}
+		reader.setStorage(proxy, EIP1967LogicSlot, implementation)
+		reader.failures.Store(failures)
+		return reader
+	}
+
+	reader := newReader(1, rpc.HTTPError{StatusCode: http.StatusTooManyRequests, Status: "429 Too Many Requests"})
+	info, err := DetectProxyTarget(context.Background(), reader, proxy)
+	if assert.NoError(t, err, "the proxy is found once the node recovers") {
+		assert.Equal(t, implementation, info.Target)
+		assert.Equal(t, "Eip1967Direct", info.Type)
+	}
+	assert.Equal(t, int64(2), reader.reads.Load())
+
+	reader = newReader(3, rpc.HTTPError{StatusCode: http.StatusServiceUnavailable})
+	_, err = DetectProxyTarget(context.Background(), reader, proxy)
+	assert.Error(t, err, "retries are bounded")
+	assert.Equal(t, int64(3), reader.reads.Load())
+
+	reader = newReader(1, rpcCodeError{code: 3, message: "execution reverted"})
+	_, err = DetectProxyTarget(context.Background(), reader, proxy)
+	assert.Error(t, err, "definitive failures are not retried")
+	assert.Equal(t, int64(1), reader.reads.Load())
+}
+
+func TestIsTransientRPCError(t *testing.T) {
+	for _, err := range []error{
+		rpc.HTTPError{StatusCode: http.StatusTooManyRequests},
+		rpc.HTTPError{StatusCode: http.StatusServiceUnavailable},
+		rpcCodeError{code: -32005, message: "limit exceeded"},
+		rpcCodeError{code: -32000, message: "Rate limit reached"},
+		fmt.Errorf("reading slot: %w", context.DeadlineExceeded),
+		io.ErrUnexpectedEOF,
+	} {
+		assert.True(t, isTransientRPCError(err), err.Error())
+	}
+	for _, err := range []error{
+		rpc.HTTPError{StatusCode: http.StatusUnauthorized},
+		rpcCodeError{code: 3, message: "execution reverted"},
+		errors.New("zero address in EIP1967 logic slot"),
+		context.Canceled,
+	} {
+		assert.False(t, isTransientRPCError(err), err.Error())
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// rpcSchemes are the URL schemes accepted for RPC endpoints.
+var rpcSchemes = []string{"http", "https", "ws", "wss"}
+
+// serviceQueryParams are the query parameters read by the service itself
+// rather than by an RPC endpoint. The post-processor switches, named after
+// their processors, are added to them in isServiceQueryParam.
+var serviceQueryParams = map[string]bool{
+	"format":            true,
+	"raw":               true,
+	"includeSelectors":  true,
+	"interfaceId":       true,
+	"mergeProxyAbi":     true,
+	"force":             true,
+	"decompile":         true,
+	"verifiedOnly":      true,
+	"block":             true,
+	"includeAdminOwner": true,
+	"detectInterfaces":  true,
+	"includeCreation":   true,
+	"candidates":        true,
+	"from":              true,
+	"to":                true,
+	"pretty":            true,
+}
+
+// isServiceQueryParam reports whether name is one of the service's own query
+// parameters.
+func isServiceQueryParam(name string) bool {
+	if serviceQueryParams[name] {
+		return true
+	}
+	for _, processor := range defaultPostProcessors() {
+		if processor.Name() == name {
+			return true
+		}
+	}
+	return false
+}
+
+// rpcURLParam returns the RPC URL given in the *rpcUrl wildcard of the route,
+// or "" when there is none. The wildcard captures the URL's path and port as
+// they are, but its query string is parsed as the request's: query
+// parameters that are not the service's own, such as an RPC provider's API
+// key, are therefore taken as the RPC URL's and appended to it.
+func rpcURLParam(c *gin.Context) string {
+	rpcURL := strings.TrimPrefix(c.Param("rpcUrl"), "/")
+	if rpcURL == "" {
+		return ""
+	}
+	var own []string
+	for _, pair := range strings.Split(c.Request.URL.RawQuery, "&") {
+		name, _, _ := strings.Cut(pair, "=")
+		if decoded, err := url.QueryUnescape(name); pair != "" && (err != nil || !isServiceQueryParam(decoded)) {
+			own = append(own, pair)
+		}
+	}
+	if len(own) > 0 {
+		separator := "?"
+		if strings.Contains(rpcURL, "?") {
+			separator = "&"
+		}
+		rpcURL += separator + strings.Join(own, "&")
+	}
+	return rpcURL
+}
+
+// normalizeRPCURL turns the rpcUrl path parameter into a URL to dial. URLs
+// without a scheme are dialed over HTTPS. Since some clients and proxies
+// collapse the double slash of a URL embedded in a path, "wss:/host" is read
+// as "wss://host".
+func normalizeRPCURL(rpcURL string) (string, error) {
+	lower := strings.ToLower(rpcURL)
+	for _, scheme := range rpcSchemes {
+		if strings.HasPrefix(lower, scheme+"://") {
+			return rpcURL, nil
+		}
+		if strings.HasPrefix(lower, scheme+":/") {
+			return scheme + "://" + rpcURL[len(scheme)+2:], nil
+		}
+	}
+	if scheme, _, ok := strings.Cut(lower, "://"); ok && !strings.ContainsAny(scheme, "/.") {
+		return "", &InvalidInputError{message: "Invalid rpcURL: scheme must be one of http, https, ws or wss", code: CodeInvalidRPCURL}
+	}
+	return "https://" + rpcURL, nil
+}
+
+// checkRPCHost rejects RPC endpoints whose host is or resolves to a loopback,
+// private, link-local or unspecified address, so callers cannot make the
+// service reach internal networks or cloud metadata endpoints.
+func checkRPCHost(ctx context.Context, endpoint string) error {
+	parsed, err := url.Parse(endpoint)
+	if err != nil || parsed.Hostname() == "" {
+		return &InvalidInputError{message: "Invalid rpcURL: cannot parse host", code: CodeInvalidRPCURL}
+	}
+	host := parsed.Hostname()
+
+	var ips []net.IP
+	if ip := net.ParseIP(host); ip != nil {
+		ips = []net.IP{ip}
+	} else {
+		addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			return &InvalidInputError{message: "Invalid rpcURL: cannot resolve host " + host, code: CodeInvalidRPCURL}
+		}
+		for _, addr := range addrs {
+			ips = append(ips, addr.IP)
+		}
+	}
+	for _, ip := range ips {
+		if isInternalIP(ip) {
+			return &InvalidInputError{message: "Invalid rpcURL: " + host + " is a private, loopback or link-local address", code: CodePrivateRPCURL}
+		}
+	}
+	return nil
+}
+
+// sharedAddressSpace is the range carrier-grade NATs use (RFC 6598), which
+// net.IP does not count as private but is just as internal.
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+func isInternalIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() ||
+		sharedAddressSpace.Contains(ip)
+}
+
+// errInternalRPCHost is returned when connecting to an RPC endpoint would
+// reach an internal address.
+var errInternalRPCHost = errors.New("RPC host is a private, loopback or link-local address")
+
+// rpcClientOptions returns the options RPC clients are dialed with. Redirects
+// are never followed. Unless allowPrivate is set, every connection is checked
+// against the address it is actually made to, so that neither a DNS answer
+// changing after checkRPCHost nor a websocket upgrade can reach internal
+// networks.
+func rpcClientOptions(allowPrivate bool) []rpc.ClientOption {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	if !allowPrivate {
+		dialer.Control = func(network string, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || isInternalIP(ip) {
+				return errInternalRPCHost
+			}
+			return nil
+		}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	client := &http.Client{
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	return []rpc.ClientOption{
+		rpc.WithHTTPClient(client),
+		rpc.WithWebsocketDialer(websocket.Dialer{
+			NetDialContext:   dialer.DialContext,
+			Proxy:            http.ProxyFromEnvironment,
+			HandshakeTimeout: 45 * time.Second,
+			ReadBufferSize:   1024,
+			WriteBufferSize:  1024,
+		}),
+	}
+}
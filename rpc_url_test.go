@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeRPCURL(t *testing.T) {
+	tests := []struct {
+		rpcURL   string
+		expected string
+	}{
+		{"rpc.ankr.com/eth", "https://rpc.ankr.com/eth"},
+		{"localhost:8545", "https://localhost:8545"},
+		{"https://rpc.ankr.com/eth", "https://rpc.ankr.com/eth"},
+		{"http://localhost:8545", "http://localhost:8545"},
+		{"ws://localhost:8546", "ws://localhost:8546"},
+		{"wss://mainnet.infura.io/ws/v3/key", "wss://mainnet.infura.io/ws/v3/key"},
+		{"WSS://node.example", "WSS://node.example"},
+		{"wss:/node.example/ws", "wss://node.example/ws"},
+		{"http:/localhost:8545", "http://localhost:8545"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rpcURL, func(t *testing.T) {
+			endpoint, err := normalizeRPCURL(tt.rpcURL)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, endpoint)
+		})
+	}
+
+	for _, rpcURL := range []string{"ipc:///var/run/geth.ipc", "file:///etc/passwd"} {
+		_, err := normalizeRPCURL(rpcURL)
+		var inputErr *InvalidInputError
+		assert.True(t, errors.As(err, &inputErr), rpcURL)
+	}
+}
+
+func TestRPCURLRouteParameter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	handler := func(c *gin.Context) {
+		rpcURL := rpcURLParam(c)
+		if rpcURL == "" {
+			c.String(http.StatusOK, "")
+			return
+		}
+		endpoint, err := normalizeRPCURL(rpcURL)
+		assert.NoError(t, err)
+		c.String(http.StatusOK, endpoint)
+	}
+	router.GET("/abi/:chainId/:address", handler)
+	router.GET("/abi/:chainId/:address/*rpcUrl", handler)
+	router.GET("/proxy/:chainId/:address/*rpcUrl", handler)
+
+	address := "0x1000000000000000000000000000000000000001"
+	for path, expected := range map[string]string{
+		"/abi/1/" + address + "/rpc.ankr.com/eth":                                  "https://rpc.ankr.com/eth",
+		"/abi/1/" + address + "/wss://node.example/ws/key":                         "wss://node.example/ws/key",
+		"/abi/1/" + address + "/http://localhost:8545":                             "http://localhost:8545",
+		"/abi/1/" + address + "/mainnet.infura.io/v3/0123456789abcdef":             "https://mainnet.infura.io/v3/0123456789abcdef",
+		"/abi/1/" + address + "/https://mainnet.infura.io/v3/0123456789abcdef":     "https://mainnet.infura.io/v3/0123456789abcdef",
+		"/abi/1/" + address + "/https:/mainnet.infura.io/v3/0123456789abcdef":      "https://mainnet.infura.io/v3/0123456789abcdef",
+		"/abi/1/" + address + "/node.example.com:8545":                             "https://node.example.com:8545",
+		"/abi/1/" + address + "/http://node.example.com:8545/rpc/":                 "http://node.example.com:8545/rpc/",
+		"/abi/1/" + address + "/https%3A%2F%2Fnode.example.com%3A8545%2Frpc":       "https://node.example.com:8545/rpc",
+		"/abi/1/" + address + "/rpc.example/v1%3Fkey%3Dsecret":                     "https://rpc.example/v1?key=secret",
+		"/abi/1/" + address + "/rpc.example/v1?apikey=secret&format=json&pretty=1": "https://rpc.example/v1?apikey=secret",
+		"/abi/1/" + address + "/rpc.example/v1?format=json&apikey=a%26b":           "https://rpc.example/v1?apikey=a%26b",
+		"/abi/1/" + address + "/rpc.example/v1%3Fnetwork%3Dmainnet?apikey=secret":  "https://rpc.example/v1?network=mainnet&apikey=secret",
+		"/proxy/1/" + address + "/rpc.example/v1?format=json&network=mainnet":      "https://rpc.example/v1?network=mainnet",
+		"/abi/1/" + address + "/rpc.example/v1?annotateSelectors=true&key=secret":  "https://rpc.example/v1?key=secret",
+		"/abi/1/" + address + "?apikey=secret":                                     "",
+		"/abi/1/" + address + "/":                                                  "",
+		"/proxy/1/" + address + "/":                                                "",
+	} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code, path)
+		assert.Equal(t, expected, w.Body.String(), path)
+	}
+}
+
+func TestServiceQueryParamsCoverRouteDocs(t *testing.T) {
+	for route, doc := range routeDocs {
+		for _, param := range doc.Query {
+			assert.True(t, isServiceQueryParam(param.Name), "%s of %s is not kept out of RPC URLs", param.Name, route)
+		}
+	}
+	assert.False(t, isServiceQueryParam("apikey"))
+}
+
+func TestCheckRPCHost(t *testing.T) {
+	for _, endpoint := range []string{
+		"https://127.0.0.1:8545",
+		"http://localhost:8545",
+		"https://169.254.169.254/latest/meta-data",
+		"https://10.0.0.1",
+		"https://192.168.1.10:8545",
+		"https://[::1]:8545",
+		"https://0.0.0.0",
+		"https://100.64.0.1",
+	} {
+		err := checkRPCHost(context.Background(), endpoint)
+		var inputErr *InvalidInputError
+		assert.True(t, errors.As(err, &inputErr), endpoint)
+	}
+
+	assert.NoError(t, checkRPCHost(context.Background(), "https://1.1.1.1"))
+	assert.NoError(t, checkRPCHost(context.Background(), "wss://[2606:4700:4700::1111]/ws"))
+}
+
+func TestDialRejectsPrivateRPC(t *testing.T) {
+	fetcher := NewABIFetcher(NewABIStorage(0, 0), map[int]ChainAPI{}, DefaultFetcherConfig())
+	_, err := fetcher.dial(context.Background(), "127.0.0.1:8545")
+	var inputErr *InvalidInputError
+	assert.True(t, errors.As(err, &inputErr))
+	assert.Contains(t, err.Error(), "private")
+
+	config := DefaultFetcherConfig()
+	config.AllowPrivateRPC = true
+	fetcher = NewABIFetcher(NewABIStorage(0, 0), map[int]ChainAPI{}, config)
+	client, err := fetcher.dial(context.Background(), "http://127.0.0.1:8545")
+	assert.NoError(t, err, "HTTP clients connect lazily, so dialing succeeds")
+	client.Close()
+}
+
+func TestRPCClientOptions(t *testing.T) {
+	var reached atomic.Bool
+	internal := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached.Store(true)
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"0x1"}`))
+	}))
+	defer internal.Close()
+
+	t.Run("connections to internal addresses", func(t *testing.T) {
+		client, err := rpc.DialOptions(context.Background(), internal.URL, rpcClientOptions(false)...)
+		assert.NoError(t, err)
+		defer client.Close()
+		var result string
+		err = client.Call(&result, "eth_chainId")
+		assert.ErrorIs(t, err, errInternalRPCHost, "the address connected to is checked, not the one resolved before")
+		assert.False(t, reached.Load())
+
+		_, err = rpc.DialOptions(context.Background(), "ws"+strings.TrimPrefix(internal.URL, "http"), rpcClientOptions(false)...)
+		assert.ErrorIs(t, err, errInternalRPCHost)
+		assert.False(t, reached.Load())
+	})
+
+	t.Run("redirects", func(t *testing.T) {
+		redirecting := httptest.NewServer(http.RedirectHandler(internal.URL, http.StatusTemporaryRedirect))
+		defer redirecting.Close()
+		client, err := rpc.DialOptions(context.Background(), redirecting.URL, rpcClientOptions(true)...)
+		assert.NoError(t, err)
+		defer client.Close()
+		var result string
+		assert.Error(t, client.Call(&result, "eth_chainId"))
+		assert.False(t, reached.Load(), "redirects are not followed")
+	})
+}
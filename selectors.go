@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+)
+
+// entrySelector parses a single ABI entry and returns its canonical signature
+// together with its selector: the 4-byte selector for functions and errors and
+// the topic0 hash for events. Entries of any other type report ok=false.
+func entrySelector(entry json.RawMessage) (signature string, selector string, ok bool) {
+	parsed, err := abi.JSON(bytes.NewReader(append(append([]byte("["), entry...), ']')))
+	if err != nil {
+		return "", "", false
+	}
+	for _, method := range parsed.Methods {
+		return method.Sig, hexutil.Encode(method.ID), true
+	}
+	for _, event := range parsed.Events {
+		return event.Sig, event.ID.Hex(), true
+	}
+	for _, abiError := range parsed.Errors {
+		return abiError.Sig, hexutil.Encode(abiError.ID[:4]), true
+	}
+	return "", "", false
+}
+
+// annotateSelectors adds a "selector" field to every function entry and a
+// "topic0" field to every event entry of the given ABI.
+func annotateSelectors(abiJSON string) (string, error) {
+	var entries []json.RawMessage
+	if err := json.Unmarshal([]byte(abiJSON), &entries); err != nil {
+		return "", fmt.Errorf("failed to parse ABI: %v", err)
+	}
+
+	annotated := make([]map[string]interface{}, 0, len(entries))
+	for _, raw := range entries {
+		var entry map[string]interface{}
+		if err := json.Unmarshal(raw, &entry); err != nil {
+			return "", fmt.Errorf("failed to parse ABI entry: %v", err)
+		}
+		if _, selector, ok := entrySelector(raw); ok {
+			switch entry["type"] {
+			case "function":
+				entry["selector"] = selector
+			case "event":
+				entry["topic0"] = selector
+			}
+		}
+		annotated = append(annotated, entry)
+	}
+
+	out, err := json.Marshal(annotated)
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+// computeInterfaceID returns the ERC-165 interface identifier of an ABI: the
+// XOR of the selectors of all its functions. Events, errors, constructors and
+// fallback functions do not contribute.
+func computeInterfaceID(abiJSON string) (string, error) {
+	selectors, err := functionSelectors(abiJSON)
+	if err != nil {
+		return "", err
+	}
+	var interfaceID [4]byte
+	for selector := range selectors {
+		for i, b := range common.FromHex(selector) {
+			interfaceID[i] ^= b
+		}
+	}
+	return hexutil.Encode(interfaceID[:]), nil
+}
+
+// selectorSignatures maps the selector of every function and the topic0 hash
+// of every event of the given ABI to its canonical signature. Entries that do
+// not parse are skipped, and an ABI that is not a JSON array yields an empty
+// map.
+func selectorSignatures(abiJSON string) map[string]string {
+	signatures := make(map[string]string)
+	var entries []json.RawMessage
+	if err := json.Unmarshal([]byte(abiJSON), &entries); err != nil {
+		return signatures
+	}
+	for _, raw := range entries {
+		var entry struct {
+			Type string `json:"type"`
+		}
+		if err := json.Unmarshal(raw, &entry); err != nil || (entry.Type != "function" && entry.Type != "event") {
+			continue
+		}
+		if signature, selector, ok := entrySelector(raw); ok {
+			signatures[selector] = signature
+		}
+	}
+	return signatures
+}
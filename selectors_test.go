@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const erc20TransferABI = `[
+  {"type":"function","name":"transfer","inputs":[{"name":"to","type":"address"},{"name":"amount","type":"uint256"}],"outputs":[{"name":"","type":"bool"}],"stateMutability":"nonpayable"},
+  {"type":"event","name":"Transfer","inputs":[{"name":"from","type":"address","indexed":true},{"name":"to","type":"address","indexed":true},{"name":"value","type":"uint256","indexed":false}],"anonymous":false},
+  {"type":"constructor","inputs":[],"stateMutability":"nonpayable"}
+]`
+
+func TestAnnotateSelectors(t *testing.T) {
+	annotated, err := annotateSelectors(erc20TransferABI)
+	assert.NoError(t, err)
+
+	var entries []map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(annotated), &entries))
+	assert.Len(t, entries, 3)
+
+	assert.Equal(t, "0xa9059cbb", entries[0]["selector"])
+	assert.Equal(t, "0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef", entries[1]["topic0"])
+	assert.NotContains(t, entries[2], "selector")
+}
+
+func TestAnnotateSelectorsInvalidABI(t *testing.T) {
+	_, err := annotateSelectors("not an abi")
+	assert.Error(t, err)
+}
+
+func TestComputeInterfaceID(t *testing.T) {
+	// ERC-721 as defined in the standard, which yields the well-known 0x80ac58cd.
+	erc721ABI := `[
+  {"type":"function","name":"balanceOf","inputs":[{"name":"owner","type":"address"}],"outputs":[{"name":"","type":"uint256"}],"stateMutability":"view"},
+  {"type":"function","name":"ownerOf","inputs":[{"name":"tokenId","type":"uint256"}],"outputs":[{"name":"","type":"address"}],"stateMutability":"view"},
+  {"type":"function","name":"safeTransferFrom","inputs":[{"name":"from","type":"address"},{"name":"to","type":"address"},{"name":"tokenId","type":"uint256"},{"name":"data","type":"bytes"}],"outputs":[],"stateMutability":"payable"},
+  {"type":"function","name":"safeTransferFrom","inputs":[{"name":"from","type":"address"},{"name":"to","type":"address"},{"name":"tokenId","type":"uint256"}],"outputs":[],"stateMutability":"payable"},
+  {"type":"function","name":"transferFrom","inputs":[{"name":"from","type":"address"},{"name":"to","type":"address"},{"name":"tokenId","type":"uint256"}],"outputs":[],"stateMutability":"payable"},
+  {"type":"function","name":"approve","inputs":[{"name":"approved","type":"address"},{"name":"tokenId","type":"uint256"}],"outputs":[],"stateMutability":"payable"},
+  {"type":"function","name":"setApprovalForAll","inputs":[{"name":"operator","type":"address"},{"name":"approved","type":"bool"}],"outputs":[],"stateMutability":"nonpayable"},
+  {"type":"function","name":"getApproved","inputs":[{"name":"tokenId","type":"uint256"}],"outputs":[{"name":"","type":"address"}],"stateMutability":"view"},
+  {"type":"function","name":"isApprovedForAll","inputs":[{"name":"owner","type":"address"},{"name":"operator","type":"address"}],"outputs":[{"name":"","type":"bool"}],"stateMutability":"view"},
+  {"type":"event","name":"Transfer","inputs":[{"name":"from","type":"address","indexed":true},{"name":"to","type":"address","indexed":true},{"name":"tokenId","type":"uint256","indexed":true}],"anonymous":false}
+]`
+
+	interfaceID, err := computeInterfaceID(erc721ABI)
+	assert.NoError(t, err)
+	assert.Equal(t, "0x80ac58cd", interfaceID)
+
+	interfaceID, err = computeInterfaceID(`[{"type":"function","name":"supportsInterface","inputs":[{"name":"interfaceId","type":"bytes4"}],"outputs":[{"name":"","type":"bool"}],"stateMutability":"view"}]`)
+	assert.NoError(t, err)
+	assert.Equal(t, "0x01ffc9a7", interfaceID)
+}
+
+func TestSelectorSignatures(t *testing.T) {
+	abiWithInvalidEntry := erc20TransferABI[:len(erc20TransferABI)-1] + `,
+  {"type":"function","name":"broken","inputs":[{"name":"x","type":"notatype"}]},
+  {"type":"error","name":"Unauthorized","inputs":[]}
+]`
+	assert.Equal(t, map[string]string{
+		"0xa9059cbb": "transfer(address,uint256)",
+		"0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef": "Transfer(address,address,uint256)",
+	}, selectorSignatures(abiWithInvalidEntry))
+
+	assert.Empty(t, selectorSignatures("not an abi"))
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const defaultSourcifyURL = "https://repo.sourcify.dev"
+
+// SourcifyAPI fetches verified ABIs from a Sourcify repository, which often
+// has contracts that are not verified on the chain's explorer.
+type SourcifyAPI struct {
+	BaseURL string
+}
+
+// GetABI returns the ABI and contract metadata from the contract's
+// metadata.json, preferring a full match over a partial one.
+func (s *SourcifyAPI) GetABI(ctx context.Context, chainId int, address string) (string, ContractMetadata, error) {
+	var lastErr error
+	for _, match := range []string{"full_match", "partial_match"} {
+		metadataURL := strings.Join([]string{
+			strings.TrimSuffix(s.BaseURL, "/"), "contracts", match,
+			strconv.Itoa(chainId), common.HexToAddress(address).Hex(), "metadata.json",
+		}, "/")
+		abi, metadata, err := fetchMetadataABI(ctx, explorerHTTPClient, metadataURL)
+		if err == nil {
+			metadata.VerificationStatus = match
+			return abi, metadata, nil
+		}
+		lastErr = err
+	}
+	return "", ContractMetadata{}, lastErr
+}
+
+// fetchMetadataABI returns the ABI and contract metadata from the Solidity
+// metadata.json at metadataURL, as served by Sourcify or an IPFS gateway.
+func fetchMetadataABI(ctx context.Context, client *http.Client, metadataURL string) (string, ContractMetadata, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, metadataURL, nil)
+	if err != nil {
+		return "", ContractMetadata{}, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", ContractMetadata{}, &NetworkError{err: err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", ContractMetadata{}, fmt.Errorf("metadata error: %s from %s", resp.Status, metadataURL)
+	}
+
+	var metadata struct {
+		Compiler struct {
+			Version string `json:"version"`
+		} `json:"compiler"`
+		Settings struct {
+			// CompilationTarget maps the source file to the contract's name.
+			CompilationTarget map[string]string `json:"compilationTarget"`
+		} `json:"settings"`
+		Output struct {
+			ABI json.RawMessage `json:"abi"`
+		} `json:"output"`
+	}
+	if err := json.NewDecoder(limitBody(resp.Body)).Decode(&metadata); err != nil {
+		return "", ContractMetadata{}, fmt.Errorf("failed to decode metadata: %v", err)
+	}
+	if len(metadata.Output.ABI) == 0 || string(metadata.Output.ABI) == "null" {
+		return "", ContractMetadata{}, fmt.Errorf("metadata has no ABI")
+	}
+
+	contract := ContractMetadata{CompilerVersion: metadata.Compiler.Version}
+	for _, name := range metadata.Settings.CompilationTarget {
+		contract.ContractName = name
+	}
+	return string(metadata.Output.ABI), contract, nil
+}
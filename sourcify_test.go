@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSourcifyAPI(t *testing.T) {
+	fullMatch := "0x1000000000000000000000000000000000000001"
+	partialMatch := "0xabcdef0000000000000000000000000000000002"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/contracts/full_match/1/" + fullMatch + "/metadata.json":
+			fmt.Fprint(w, `{"compiler":{"version":"0.8.19+commit.7dd6d404"},"settings":{"compilationTarget":{"contracts/Full.sol":"Full"}},"output":{"abi":[{"type":"function","name":"full"}]}}`)
+		case "/contracts/partial_match/1/0xaBcDef0000000000000000000000000000000002/metadata.json":
+			fmt.Fprint(w, `{"output":{"abi":[{"type":"function","name":"partial"}]}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	sourcify := &SourcifyAPI{BaseURL: server.URL}
+
+	abi, metadata, err := sourcify.GetABI(context.Background(), 1, fullMatch)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `[{"type":"function","name":"full"}]`, abi)
+	assert.Equal(t, ContractMetadata{ContractName: "Full", CompilerVersion: "0.8.19+commit.7dd6d404", VerificationStatus: "full_match"}, metadata)
+
+	abi, metadata, err = sourcify.GetABI(context.Background(), 1, partialMatch)
+	assert.NoError(t, err, "addresses are checksummed and partial matches are used")
+	assert.JSONEq(t, `[{"type":"function","name":"partial"}]`, abi)
+	assert.Equal(t, ContractMetadata{VerificationStatus: "partial_match"}, metadata)
+
+	_, _, err = sourcify.GetABI(context.Background(), 10, fullMatch)
+	assert.Error(t, err)
+}
+
+func TestGetABIPrefersSourcifyOverDecompilation(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"output":{"abi":[{"type":"function","name":"verified"}]}}`)
+	}))
+	defer server.Close()
+
+	config := DefaultFetcherConfig()
+	config.SourcifyURL = server.URL
+	fetcher := NewABIFetcher(NewABIStorage(time.Hour, 0), map[int]ChainAPI{
+		1: staticChainAPI{},
+	}, config)
+
+	fetched, err := fetcher.getABI(context.Background(), "1", "0x1000000000000000000000000000000000000001", "127.0.0.1:1", nil, true)
+	assert.NoError(t, err)
+	assert.False(t, fetched.IsDecompiled)
+	assert.JSONEq(t, `[{"type":"function","name":"verified"}]`, fetched.ABI)
+}
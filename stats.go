@@ -0,0 +1,61 @@
+package main
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Stats holds service-wide counters. All fields are updated atomically so
+// they can be read and reset while requests are in flight.
+type Stats struct {
+	requests    atomic.Int64
+	cacheHits   atomic.Int64
+	cacheMisses atomic.Int64
+	decompiled  atomic.Int64
+	errors      atomic.Int64
+}
+
+func (s *Stats) Snapshot() gin.H {
+	return gin.H{
+		"requests":    s.requests.Load(),
+		"cacheHits":   s.cacheHits.Load(),
+		"cacheMisses": s.cacheMisses.Load(),
+		"decompiled":  s.decompiled.Load(),
+		"errors":      s.errors.Load(),
+	}
+}
+
+func (s *Stats) Reset() {
+	s.requests.Store(0)
+	s.cacheHits.Store(0)
+	s.cacheMisses.Store(0)
+	s.decompiled.Store(0)
+	s.errors.Store(0)
+}
+
+// requireAdmin only lets requests through that carry the ADMIN_TOKEN as a
+// bearer token. Admin endpoints are disabled when no token is configured.
+func requireAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := os.Getenv("ADMIN_TOKEN")
+		if token == "" {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Admin endpoints are disabled"})
+			return
+		}
+		provided, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Missing admin token"})
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid admin token"})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatsConcurrentUpdatesAndReset(t *testing.T) {
+	stats := &Stats{}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			stats.requests.Add(1)
+			stats.cacheHits.Add(1)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int64(100), stats.Snapshot()["requests"])
+	assert.Equal(t, int64(100), stats.Snapshot()["cacheHits"])
+
+	stats.Reset()
+	assert.Equal(t, int64(0), stats.Snapshot()["requests"])
+	assert.Equal(t, int64(0), stats.Snapshot()["cacheHits"])
+}
+
+func TestStatsResetRequiresAdmin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/stats/reset", requireAdmin(), resetStats)
+
+	tests := []struct {
+		name          string
+		adminToken    string
+		authorization string
+		expectedCode  int
+	}{
+		{name: "disabled without token", adminToken: "", authorization: "Bearer anything", expectedCode: http.StatusForbidden},
+		{name: "missing header", adminToken: "secret", authorization: "", expectedCode: http.StatusUnauthorized},
+		{name: "wrong token", adminToken: "secret", authorization: "Bearer wrong", expectedCode: http.StatusUnauthorized},
+		{name: "token without scheme", adminToken: "secret", authorization: "secret", expectedCode: http.StatusUnauthorized},
+		{name: "valid token", adminToken: "secret", authorization: "Bearer secret", expectedCode: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("ADMIN_TOKEN", tt.adminToken)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/stats/reset", nil)
+			req.Header.Set("Authorization", tt.authorization)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedCode, w.Code)
+		})
+	}
+}
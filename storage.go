@@ -1,34 +1,244 @@
 package main
 
-import "sync"
+import (
+	"container/list"
+	"strings"
+	"sync"
+	"time"
+)
 
+// StorageBackend persists fetched ABIs keyed by chain and address.
+type StorageBackend interface {
+	Get(key string) (StorageItem, bool)
+	Set(key string, item StorageItem)
+	// Delete removes key and reports whether it was present.
+	Delete(key string) bool
+	// DeletePrefix removes every key starting with prefix and returns how
+	// many were present.
+	DeletePrefix(prefix string) int
+}
+
+// ABIStorage is an in-memory cache of fetched ABIs. Entries expire after a
+// TTL, and once more than maxEntries are stored the least recently used entry
+// is evicted.
 type ABIStorage struct {
-	mu    sync.RWMutex
-	cache map[string]StorageItem
+	mu         sync.RWMutex
+	cache      map[string]*list.Element
+	lru        *list.List
+	ttl        time.Duration
+	maxEntries int
+	now        func() time.Time
+
+	sweepMu   sync.Mutex
+	stopSweep chan struct{}
+	sweepDone chan struct{}
 }
 
 type StorageItem struct {
-	ABI            string
-	Implementation interface{}
-	IsProxy        bool
-	IsDecompiled   bool
+	ABI            string      `json:"abi"`
+	Implementation interface{} `json:"implementation"`
+	IsProxy        bool        `json:"isProxy"`
+	IsDecompiled   bool        `json:"isDecompiled"`
+	Admin          string      `json:"admin,omitempty"`
+	AdminOwner     string      `json:"adminOwner,omitempty"`
+	// PartialDelegation is set when the proxy handles some calls itself and
+	// ABI holds the merged proxy and implementation ABIs.
+	PartialDelegation bool `json:"partialDelegation,omitempty"`
+	// RawABI holds the decompiled ABI exactly as Heimdall returned it when it
+	// differs from the processed ABI.
+	RawABI string `json:"rawAbi,omitempty"`
+	// ProxyType is how the proxy was detected, e.g. Eip1967Direct.
+	ProxyType string `json:"proxyType,omitempty"`
+	// Immutable is set for proxies whose target cannot change, such as
+	// EIP-1167 minimal proxies.
+	Immutable bool `json:"immutable,omitempty"`
+	// ProxyABI holds the proxy contract's own ABI once it was fetched for
+	// mergeProxyAbi requests.
+	ProxyABI string `json:"proxyAbi,omitempty"`
+	// ResolutionPath lists the addresses from the proxy through any nested
+	// proxies to the final implementation.
+	ResolutionPath []string `json:"resolutionPath,omitempty"`
+	// Facets lists the facet addresses of a diamond proxy, whose ABI merges
+	// all of them.
+	Facets []string `json:"facets,omitempty"`
+	// ExplorerError explains why the explorer's verified ABI was not used.
+	ExplorerError string `json:"explorerError,omitempty"`
+	// Creation holds who deployed the contract once it was fetched for
+	// includeCreation requests. It is empty when the explorer has no record.
+	Creation *ContractCreation `json:"creation,omitempty"`
+	ContractMetadata
+	// Error marks a negative entry: no source could provide an ABI for the
+	// contract, for the reason given.
+	Error string `json:"error,omitempty"`
+	// TTL overrides the storage's TTL for this item when it is non-zero.
+	TTL time.Duration `json:"ttl,omitempty"`
+	// StoredAt is when the item was cached. Set fills it in when it is zero.
+	StoredAt time.Time `json:"storedAt"`
+}
+
+// ContractMetadata describes the verified source an ABI was taken from. It
+// is empty for decompiled ABIs.
+type ContractMetadata struct {
+	ContractName    string `json:"contractName,omitempty"`
+	CompilerVersion string `json:"compilerVersion,omitempty"`
+	// VerificationStatus is "verified" for contracts verified on the explorer,
+	// or Sourcify's "full_match" or "partial_match".
+	VerificationStatus string `json:"verificationStatus,omitempty"`
+}
+
+// lruEntry is the value held by each element of the LRU list.
+type lruEntry struct {
+	key  string
+	item StorageItem
 }
 
-func NewABIStorage() *ABIStorage {
+// NewABIStorage creates a storage whose entries expire ttl after they were
+// stored and which holds at most maxEntries entries. Zero disables either
+// limit.
+func NewABIStorage(ttl time.Duration, maxEntries int) *ABIStorage {
 	return &ABIStorage{
-		cache: make(map[string]StorageItem),
+		cache:      make(map[string]*list.Element),
+		lru:        list.New(),
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		now:        time.Now,
 	}
 }
 
 func (s *ABIStorage) Set(key string, item StorageItem) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	s.cache[key] = item
+	if item.StoredAt.IsZero() {
+		item.StoredAt = s.now()
+	}
+
+	if element, ok := s.cache[key]; ok {
+		element.Value.(*lruEntry).item = item
+		s.lru.MoveToFront(element)
+		return
+	}
+	s.cache[key] = s.lru.PushFront(&lruEntry{key: key, item: item})
+
+	if s.maxEntries > 0 && s.lru.Len() > s.maxEntries {
+		s.removeElement(s.lru.Back())
+	}
 }
 
+// Get returns the item stored under key and marks it as recently used.
 func (s *ABIStorage) Get(key string) (StorageItem, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	element, ok := s.cache[key]
+	if !ok {
+		return StorageItem{}, false
+	}
+	entry := element.Value.(*lruEntry)
+	if s.expired(entry.item) {
+		s.removeElement(element)
+		return StorageItem{}, false
+	}
+	s.lru.MoveToFront(element)
+	return entry.item, true
+}
+
+func (s *ABIStorage) Delete(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	element, ok := s.cache[key]
+	if ok {
+		s.removeElement(element)
+	}
+	return ok
+}
+
+func (s *ABIStorage) DeletePrefix(prefix string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	deleted := 0
+	for key, element := range s.cache {
+		if strings.HasPrefix(key, prefix) {
+			s.removeElement(element)
+			deleted++
+		}
+	}
+	return deleted
+}
+
+// Len returns the number of stored entries, including expired ones that have
+// not been evicted yet.
+func (s *ABIStorage) Len() int {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
-	item, ok := s.cache[key]
-	return item, ok
+	return s.lru.Len()
+}
+
+func (s *ABIStorage) removeElement(element *list.Element) {
+	s.lru.Remove(element)
+	delete(s.cache, element.Value.(*lruEntry).key)
+}
+
+func (s *ABIStorage) expired(item StorageItem) bool {
+	ttl := s.ttl
+	if item.TTL > 0 {
+		ttl = item.TTL
+	}
+	return ttl > 0 && s.now().Sub(item.StoredAt) >= ttl
+}
+
+// Sweep evicts all expired entries.
+func (s *ABIStorage) Sweep() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for element := s.lru.Front(); element != nil; {
+		next := element.Next()
+		if s.expired(element.Value.(*lruEntry).item) {
+			s.removeElement(element)
+		}
+		element = next
+	}
+}
+
+// StartSweeper runs Sweep every interval in the background until StopSweeper
+// is called. Starting an already running sweeper has no effect.
+func (s *ABIStorage) StartSweeper(interval time.Duration) {
+	s.sweepMu.Lock()
+	defer s.sweepMu.Unlock()
+	if s.stopSweep != nil || s.ttl <= 0 {
+		return
+	}
+	s.stopSweep = make(chan struct{})
+	s.sweepDone = make(chan struct{})
+
+	go func(stop <-chan struct{}, done chan<- struct{}) {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				s.Sweep()
+			case <-stop:
+				return
+			}
+		}
+	}(s.stopSweep, s.sweepDone)
+}
+
+// StopSweeper stops the background sweeper and waits for it to exit.
+func (s *ABIStorage) StopSweeper() {
+	s.sweepMu.Lock()
+	defer s.sweepMu.Unlock()
+	if s.stopSweep == nil {
+		return
+	}
+	close(s.stopSweep)
+	<-s.sweepDone
+	s.stopSweep = nil
+	s.sweepDone = nil
+}
+
+// Close stops the background sweeper. The cached entries stay readable.
+func (s *ABIStorage) Close() error {
+	s.StopSweeper()
+	return nil
 }
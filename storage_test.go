@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestABIStorageExpiry(t *testing.T) {
+	storage := NewABIStorage(time.Hour, 0)
+	now := time.Date(2024, 8, 8, 12, 0, 0, 0, time.UTC)
+	storage.now = func() time.Time { return now }
+
+	storage.Set("key", StorageItem{ABI: "abi"})
+	assert.Equal(t, now, storage.cache["key"].Value.(*lruEntry).item.StoredAt, "Set stamps the storage time")
+
+	now = now.Add(time.Hour - time.Nanosecond)
+	item, ok := storage.Get("key")
+	assert.True(t, ok, "entry is valid until the TTL elapses")
+	assert.Equal(t, "abi", item.ABI)
+
+	now = now.Add(time.Nanosecond)
+	item, ok = storage.Get("key")
+	assert.False(t, ok, "entry expires exactly at the TTL")
+	assert.Equal(t, StorageItem{}, item)
+}
+
+func TestABIStorageItemTTL(t *testing.T) {
+	storage := NewABIStorage(time.Hour, 0)
+	now := time.Date(2024, 8, 8, 12, 0, 0, 0, time.UTC)
+	storage.now = func() time.Time { return now }
+
+	storage.Set("negative", StorageItem{Error: "not decompilable", TTL: time.Minute})
+	storage.Set("positive", StorageItem{ABI: "abi"})
+
+	now = now.Add(time.Minute)
+	_, ok := storage.Get("negative")
+	assert.False(t, ok, "the item's TTL overrides the storage's")
+	_, ok = storage.Get("positive")
+	assert.True(t, ok)
+}
+
+func TestABIStorageWithoutTTL(t *testing.T) {
+	storage := NewABIStorage(0, 0)
+	storage.Set("key", StorageItem{ABI: "abi", StoredAt: time.Now().Add(-24 * 365 * time.Hour)})
+
+	_, ok := storage.Get("key")
+	assert.True(t, ok)
+}
+
+func TestABIStorageSweep(t *testing.T) {
+	storage := NewABIStorage(time.Hour, 0)
+	storage.Set("old", StorageItem{StoredAt: time.Now().Add(-2 * time.Hour)})
+	storage.Set("fresh", StorageItem{})
+
+	storage.Sweep()
+
+	assert.NotContains(t, storage.cache, "old")
+	assert.Contains(t, storage.cache, "fresh")
+}
+
+func TestABIStorageConcurrentAccessDuringSweep(t *testing.T) {
+	storage := NewABIStorage(5*time.Millisecond, 0)
+	storage.StartSweeper(time.Millisecond)
+	storage.StartSweeper(time.Millisecond)
+	defer storage.StopSweeper()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for j := 0; j < 500; j++ {
+				key := fmt.Sprintf("key-%d", j%20)
+				storage.Set(key, StorageItem{ABI: fmt.Sprintf("abi-%d", worker)})
+				if item, ok := storage.Get(key); ok {
+					assert.NotEmpty(t, item.ABI)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	time.Sleep(20 * time.Millisecond)
+	assert.Zero(t, storage.Len(), "the sweeper evicts expired entries")
+}
+
+func TestABIStorageStopSweeper(t *testing.T) {
+	storage := NewABIStorage(time.Hour, 0)
+	storage.StopSweeper()
+
+	storage.StartSweeper(time.Millisecond)
+	storage.StopSweeper()
+	storage.StopSweeper()
+	assert.Nil(t, storage.stopSweep)
+}
+
+func TestABIStorageLRUEviction(t *testing.T) {
+	storage := NewABIStorage(0, 3)
+	storage.Set("a", StorageItem{ABI: "a"})
+	storage.Set("b", StorageItem{ABI: "b"})
+	storage.Set("c", StorageItem{ABI: "c"})
+
+	_, ok := storage.Get("a")
+	assert.True(t, ok, "reading a makes b the least recently used entry")
+
+	storage.Set("d", StorageItem{ABI: "d"})
+	assert.Equal(t, 3, storage.Len())
+	_, ok = storage.Get("b")
+	assert.False(t, ok, "b was evicted")
+	for _, key := range []string{"a", "c", "d"} {
+		_, ok := storage.Get(key)
+		assert.True(t, ok, key)
+	}
+
+	storage.Set("c", StorageItem{ABI: "c2"})
+	assert.Equal(t, 3, storage.Len(), "overwriting does not grow the cache")
+	item, _ := storage.Get("c")
+	assert.Equal(t, "c2", item.ABI)
+}
+
+func TestABIStorageUnbounded(t *testing.T) {
+	storage := NewABIStorage(0, 0)
+	for i := 0; i < 100; i++ {
+		storage.Set(fmt.Sprintf("key-%d", i), StorageItem{})
+	}
+	assert.Equal(t, 100, storage.Len())
+}
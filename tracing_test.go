@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	spanRecorder    = tracetest.NewSpanRecorder()
+	installRecorder sync.Once
+)
+
+// recordSpans routes the spans of the fetch pipeline to spanRecorder. The
+// provider is installed globally once, as spans may still end in goroutines
+// of earlier tests; tests tell their spans apart by trace ID.
+func recordSpans() *tracetest.SpanRecorder {
+	installRecorder.Do(func() {
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spanRecorder)))
+		otel.SetTextMapPropagator(propagation.TraceContext{})
+	})
+	return spanRecorder
+}
+
+func spansByName(recorder *tracetest.SpanRecorder, traceID trace.TraceID) map[string]sdktrace.ReadOnlySpan {
+	spans := make(map[string]sdktrace.ReadOnlySpan)
+	for _, span := range recorder.Ended() {
+		if span.SpanContext().TraceID() == traceID {
+			spans[span.Name()] = span
+		}
+	}
+	return spans
+}
+
+func spanAttributes(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	attributes := make(map[attribute.Key]attribute.Value)
+	for _, kv := range span.Attributes() {
+		attributes[kv.Key] = kv.Value
+	}
+	return attributes
+}
+
+func TestTracingFetchABI(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	recorder := recordSpans()
+	previous := abiFetcher
+	defer func() { abiFetcher = previous }()
+	storage := NewABIStorage(time.Hour, 0)
+	abiFetcher = NewABIFetcher(storage, map[int]ChainAPI{}, DefaultFetcherConfig())
+
+	router := gin.New()
+	router.Use(tracing())
+	router.GET("/abi/:chainId/:address/*rpcUrl", getABI)
+
+	address := "0x1000000000000000000000000000000000000001"
+	storage.Set("1-"+address, StorageItem{ABI: "[]", IsProxy: true, ProxyType: "Eip1967Direct"})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/abi/1/"+address+"/rpc.example", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spans := spansByName(recorder, traceID)
+	server := spans["GET /abi/:chainId/:address/*rpcUrl"]
+	if assert.NotNil(t, server) {
+		assert.True(t, server.Parent().IsRemote(), "the caller's trace is continued")
+		assert.Equal(t, int64(http.StatusOK), spanAttributes(server)["http.response.status_code"].AsInt64())
+	}
+	fetch := spans["FetchABI"]
+	if assert.NotNil(t, fetch) {
+		assert.Equal(t, server.SpanContext().SpanID(), fetch.Parent().SpanID())
+		attributes := spanAttributes(fetch)
+		assert.Equal(t, "1", attributes["chainId"].AsString())
+		assert.Equal(t, address, attributes["address"].AsString())
+		assert.Equal(t, SourceCache, attributes["source"].AsString())
+		assert.Equal(t, "Eip1967Direct", attributes["proxyType"].AsString())
+	}
+	if get := spans["cache.get"]; assert.NotNil(t, get) {
+		assert.Equal(t, fetch.SpanContext().SpanID(), get.Parent().SpanID())
+		assert.True(t, spanAttributes(get)["hit"].AsBool())
+	}
+}
+
+func TestTracingDetectProxyTarget(t *testing.T) {
+	recorder := recordSpans()
+	ctx, root := tracer.Start(context.Background(), "test")
+	defer root.End()
+	proxy := common.HexToAddress("0x1000000000000000000000000000000000000001")
+	implementation := common.HexToAddress("0x2000000000000000000000000000000000000002")
+	client := newMockContractReader()
+	client.setStorage(proxy, EIP1967LogicSlot, implementation)
+
+	_, err := DetectProxyTarget(ctx, client, proxy)
+	assert.NoError(t, err)
+
+	spans := spansByName(recorder, root.SpanContext().TraceID())
+	detect := spans["DetectProxyTarget"]
+	if assert.NotNil(t, detect) {
+		assert.Equal(t, "Eip1967Direct", spanAttributes(detect)["proxyType"].AsString())
+	}
+	if method := spans["detect.eip1967Logic"]; assert.NotNil(t, method, "each detection method has its own span") {
+		assert.Equal(t, detect.SpanContext().SpanID(), method.Parent().SpanID())
+		assert.True(t, spanAttributes(method)["detected"].AsBool())
+	}
+}
+
+func TestSetupTracingWithoutEndpointIsNoop(t *testing.T) {
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+	shutdown, err := setupTracing(context.Background())
+	assert.NoError(t, err)
+	assert.NoError(t, shutdown(context.Background()))
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+// TransactionReader is the subset of the ethclient API used to find the
+// contract a transaction called.
+type TransactionReader interface {
+	TransactionByHash(ctx context.Context, hash common.Hash) (*types.Transaction, bool, error)
+	TransactionReceipt(ctx context.Context, hash common.Hash) (*types.Receipt, error)
+}
+
+// validateTxHash checks that txHash is 0x followed by 64 hexadecimal
+// characters.
+func validateTxHash(txHash string) (common.Hash, error) {
+	if len(txHash) != 66 || !strings.HasPrefix(txHash, "0x") {
+		return common.Hash{}, &InvalidInputError{message: "Invalid txHash: must be 0x followed by 64 hexadecimal characters", code: CodeInvalidTxHash}
+	}
+	hash, err := hexutil.Decode(txHash)
+	if err != nil {
+		return common.Hash{}, &InvalidInputError{message: "Invalid txHash: must be 0x followed by 64 hexadecimal characters", code: CodeInvalidTxHash}
+	}
+	return common.BytesToHash(hash), nil
+}
+
+// calledContract returns the contract the transaction hash called and its
+// calldata. Contract creations fail with *ContractCreationTxError naming the
+// created contract when the receipt is available.
+func calledContract(ctx context.Context, client TransactionReader, hash common.Hash) (common.Address, []byte, error) {
+	tx, _, err := client.TransactionByHash(ctx, hash)
+	if errors.Is(err, ethereum.NotFound) {
+		return common.Address{}, nil, &TransactionNotFoundError{hash: hash.Hex()}
+	}
+	if err != nil {
+		return common.Address{}, nil, err
+	}
+	if tx.To() == nil {
+		creationErr := &ContractCreationTxError{hash: hash.Hex()}
+		// Pending transactions have no receipt yet.
+		if receipt, err := client.TransactionReceipt(ctx, hash); err == nil && receipt.ContractAddress != (common.Address{}) {
+			creationErr.contractAddress = receipt.ContractAddress.Hex()
+		}
+		return common.Address{}, nil, creationErr
+	}
+	return *tx.To(), tx.Data(), nil
+}
+
+// ResolveTransaction returns the contract the transaction txHash called and
+// its calldata, so that the contract's ABI can be fetched to decode the call.
+func (af *ABIFetcher) ResolveTransaction(ctx context.Context, chainId string, txHash string, rpcURL string) (common.Address, []byte, error) {
+	if _, err := strconv.Atoi(chainId); err != nil {
+		return common.Address{}, nil, &InvalidInputError{message: "Invalid chainId: must be a number", code: CodeInvalidChainID}
+	}
+	if err := af.checkChainServed(chainId); err != nil {
+		return common.Address{}, nil, err
+	}
+	hash, err := validateTxHash(txHash)
+	if err != nil {
+		return common.Address{}, nil, err
+	}
+	if rpcURL == "" {
+		rpcURL = af.defaultRPCURL(chainId)
+	}
+	if rpcURL == "" {
+		return common.Address{}, nil, &InvalidInputError{message: "Invalid rpcURL: cannot be empty", code: CodeEmptyRPCURL}
+	}
+
+	client, err := af.dial(ctx, rpcURL)
+	if err != nil {
+		return common.Address{}, nil, err
+	}
+	defer client.Close()
+	return calledContract(ctx, client, hash)
+}
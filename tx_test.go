@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// signedTx returns a signed legacy transaction to to, or a contract creation
+// when to is nil.
+func signedTx(t *testing.T, to *common.Address, data []byte) *types.Transaction {
+	key, err := crypto.GenerateKey()
+	assert.NoError(t, err)
+	tx, err := types.SignTx(types.NewTx(&types.LegacyTx{Nonce: 1, To: to, Gas: 21000, GasPrice: big.NewInt(1), Data: data}), types.HomesteadSigner{}, key)
+	assert.NoError(t, err)
+	return tx
+}
+
+// mockTransactionReader serves fixed transactions and receipts.
+type mockTransactionReader struct {
+	txs      map[common.Hash]*types.Transaction
+	receipts map[common.Hash]*types.Receipt
+}
+
+func (m *mockTransactionReader) TransactionByHash(ctx context.Context, hash common.Hash) (*types.Transaction, bool, error) {
+	tx, ok := m.txs[hash]
+	if !ok {
+		return nil, false, ethereum.NotFound
+	}
+	return tx, false, nil
+}
+
+func (m *mockTransactionReader) TransactionReceipt(ctx context.Context, hash common.Hash) (*types.Receipt, error) {
+	receipt, ok := m.receipts[hash]
+	if !ok {
+		return nil, ethereum.NotFound
+	}
+	return receipt, nil
+}
+
+func TestValidateTxHash(t *testing.T) {
+	valid := "0x" + common.Bytes2Hex(crypto.Keccak256([]byte("tx")))
+	hash, err := validateTxHash(valid)
+	assert.NoError(t, err)
+	assert.Equal(t, valid, hash.Hex())
+
+	for _, invalid := range []string{"", "0x1234", valid[2:] + "00", valid[:65] + "g", "0X" + valid[2:]} {
+		_, err := validateTxHash(invalid)
+		var inputErr *InvalidInputError
+		if assert.ErrorAs(t, err, &inputErr, invalid) {
+			assert.Equal(t, CodeInvalidTxHash, inputErr.code)
+		}
+	}
+}
+
+func TestCalledContract(t *testing.T) {
+	contract := common.HexToAddress("0x1000000000000000000000000000000000000001")
+	call := signedTx(t, &contract, []byte{0xa9, 0x05, 0x9c, 0xbb})
+	creation := signedTx(t, nil, []byte{0x60, 0x80})
+	pendingCreation := signedTx(t, nil, []byte{0x60, 0x81})
+	created := common.HexToAddress("0x2000000000000000000000000000000000000002")
+	client := &mockTransactionReader{
+		txs: map[common.Hash]*types.Transaction{call.Hash(): call, creation.Hash(): creation, pendingCreation.Hash(): pendingCreation},
+		receipts: map[common.Hash]*types.Receipt{
+			creation.Hash(): {ContractAddress: created},
+		},
+	}
+
+	to, calldata, err := calledContract(context.Background(), client, call.Hash())
+	assert.NoError(t, err)
+	assert.Equal(t, contract, to)
+	assert.Equal(t, []byte{0xa9, 0x05, 0x9c, 0xbb}, calldata)
+
+	_, _, err = calledContract(context.Background(), client, creation.Hash())
+	var creationErr *ContractCreationTxError
+	if assert.ErrorAs(t, err, &creationErr) {
+		assert.Equal(t, created.Hex(), creationErr.contractAddress)
+		status, body := errorResponse(err)
+		assert.Equal(t, http.StatusUnprocessableEntity, status)
+		assert.Equal(t, created.Hex(), body["contractAddress"])
+	}
+
+	_, _, err = calledContract(context.Background(), client, pendingCreation.Hash())
+	if assert.ErrorAs(t, err, &creationErr) {
+		assert.Empty(t, creationErr.contractAddress, "a pending creation has no receipt yet")
+	}
+
+	_, _, err = calledContract(context.Background(), client, common.HexToHash("0x01"))
+	assert.IsType(t, &TransactionNotFoundError{}, err)
+	status, _ := errorResponse(err)
+	assert.Equal(t, http.StatusNotFound, status)
+}
+
+func TestGetABIByTx(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	contract := common.HexToAddress("0x1000000000000000000000000000000000000001")
+	call := signedTx(t, &contract, []byte{0xa9, 0x05, 0x9c, 0xbb})
+	node := newFakeNode(t, map[string]rpcHandler{
+		"eth_getTransactionByHash": func(params []json.RawMessage) (interface{}, error) {
+			if rpcParam[common.Hash](params, 0) == call.Hash() {
+				return call, nil
+			}
+			return nil, nil
+		},
+	})
+
+	previous := abiFetcher
+	defer func() { abiFetcher = previous }()
+	config := DefaultFetcherConfig()
+	config.AllowPrivateRPC = true
+	storage := NewABIStorage(time.Hour, 0)
+	abiFetcher = NewABIFetcher(storage, map[int]ChainAPI{}, config)
+	storage.Set(abiCacheKey("1", contract.Hex(), nil), StorageItem{ABI: `[{"type":"function","name":"transfer","inputs":[],"outputs":[],"stateMutability":"nonpayable"}]`})
+
+	router := gin.New()
+	router.GET("/abi/tx/:chainId/:txHash/*rpcUrl", getABIByTx)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/abi/tx/1/"+call.Hash().Hex()+"/"+node.URL, nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response map[string]interface{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Contains(t, response["abi"], "transfer")
+	assert.Equal(t, call.Hash().Hex(), response["txHash"])
+	assert.Equal(t, contract.Hex(), response["to"])
+	assert.Equal(t, "0xa9059cbb", response["input"])
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/abi/tx/1/"+common.HexToHash("0x01").Hex()+"/"+node.URL, nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/abi/tx/1/0x1234/"+node.URL, nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), CodeInvalidTxHash)
+}